@@ -1,17 +1,32 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	stdLog "log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"qc_api/internal/ReportGenerator"
+	"qc_api/internal/attachments"
 	"qc_api/internal/auth"
 	"qc_api/internal/calibration"
 	"qc_api/internal/config"
 	"qc_api/internal/employees"
+	inspectionproperties "qc_api/internal/inspectionProperties"
 	"qc_api/internal/inspections"
 	"qc_api/internal/jobqueue"
+	"qc_api/internal/llm"
+	"qc_api/internal/search"
+	"qc_api/internal/storage"
+	"qc_api/internal/tasks"
+	"qc_api/internal/transcription"
+	"qc_api/internal/transcripts"
+	"qc_api/internal/uploads"
 	"qc_api/internal/utils"
 
 	"github.com/joho/godotenv"
@@ -46,11 +61,6 @@ import (
 // @name Authorization
 // @description Type "Bearer" followed by a space and JWT token.
 
-type UploadResponse struct {
-	Status   string `json:"status"`
-	Filename string `json:"filename"`
-}
-
 func InitDB(ConnectionString string) *gorm.DB {
 	// Create logger
 	newLogger := logger.New(
@@ -76,15 +86,90 @@ func InitDB(ConnectionString string) *gorm.DB {
 	models = append(models, auth.Models()...)
 	models = append(models, employees.Models()...)
 	models = append(models, inspections.Models()...)
+	models = append(models, inspectionproperties.Models()...)
 	models = append(models, calibration.Models()...)
+	models = append(models, tasks.Models()...)
+	models = append(models, attachments.Models()...)
+	models = append(models, uploads.Models()...)
+	models = append(models, transcripts.Models()...)
+	models = append(models, jobqueue.Models()...)
+	models = append(models, ReportGenerator.Models()...)
 
 	// Migrate all
 	if err := db.AutoMigrate(models...); err != nil {
 		log.Fatalf("migration failed: %v", err)
 	}
+	if err := inspections.Migrate(db); err != nil {
+		log.Fatalf("inspection data migration failed: %v", err)
+	}
+	if err := tasks.Migrate(db); err != nil {
+		log.Fatalf("task data migration failed: %v", err)
+	}
+	if err := ReportGenerator.Migrate(db); err != nil {
+		log.Fatalf("prompt template migration failed: %v", err)
+	}
+	if err := search.Migrate(db); err != nil {
+		log.Fatalf("search index migration failed: %v", err)
+	}
 	return db
 }
 
+// InitStorage opens the configured storage backend
+func InitStorage(cfg *config.Config) storage.Storage {
+	switch cfg.StorageBackend {
+	case "local":
+		store, err := storage.NewLocal(cfg.StorageDir)
+		if err != nil {
+			log.Fatalf("failed to open local storage: %v", err)
+		}
+		return store
+	case "s3":
+		store, err := storage.NewS3(storage.S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Bucket:    cfg.S3Bucket,
+			Region:    cfg.S3Region,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+		})
+		if err != nil {
+			log.Fatalf("failed to configure S3 storage: %v", err)
+		}
+		return store
+	}
+	log.Fatalf("unknown STORAGE_BACKEND %q", cfg.StorageBackend)
+	return nil
+}
+
+// InitTranscriber builds the configured transcription engine
+func InitTranscriber(cfg *config.Config) transcription.Transcriber {
+	transcriber, err := transcription.New(transcription.Config{
+		Engine:  cfg.Transcriber,
+		Command: cfg.TranscribeCommand,
+		URL:     cfg.WhisperURL,
+		Timeout: cfg.TranscribeTimeout,
+	})
+	if err != nil {
+		log.Fatalf("failed to configure transcription: %v", err)
+	}
+	return transcriber
+}
+
+// InitReports connects the report generator to the configured LLM provider
+func InitReports(cfg *config.Config, db *gorm.DB, store storage.Storage) *ReportGenerator.ReportService {
+	provider, err := llm.New(llm.Config{
+		Provider: cfg.LLMProvider,
+		URL:      cfg.LLMURL,
+		APIKey:   cfg.LLMAPIKey,
+		Model:    cfg.LLMModel,
+	})
+	if err != nil {
+		log.Fatalf("failed to configure LLM provider: %v", err)
+	}
+	reports := ReportGenerator.NewReportService(db, provider, store)
+	reports.Timeout = cfg.ReportTimeout
+	return reports
+}
+
 // DelayMiddleware delays each request and logs the path
 func DelayMiddleware(delay time.Duration) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
@@ -113,13 +198,30 @@ func main() {
 		dbPath = "data/db/qc_api.db"
 	}
 	db := InitDB(dbPath)
+	store := InitStorage(cfg)
 
 	authService := auth.NewAuthService(db, cfg.JWTSecret, time.Duration(cfg.AuthTimeout)*time.Millisecond)
 	employeeService := employees.NewEmployeeService(db)
 	inspectionService := inspections.NewInspectionService(db)
+	propertyService := inspectionproperties.NewPropertyService(db)
 	calibrationService := calibration.NewCalibrationService(db)
-
-	go jobqueue.Worker()
+	taskService := tasks.NewTaskService(db)
+	inspectionService.AddObserver(taskService)
+	attachmentService := attachments.NewAttachmentService(db, store, cfg.MaxAttachmentSize)
+	jobQueue := jobqueue.NewQueue(db)
+	transcriptService := transcripts.NewTranscriptService(db)
+	reportService := InitReports(cfg, db, store)
+	uploadService := uploads.NewUploadService(db, store, InitTranscriber(cfg), transcriptService, reportService, inspectionService, jobQueue)
+	searchService := search.NewSearchService(db)
+
+	if err := jobQueue.Resume(); err != nil {
+		log.Fatalf("failed to resume jobs: %v", err)
+	}
+	concurrency := map[jobqueue.JobType]int{}
+	for jobType, n := range cfg.JobConcurrency {
+		concurrency[jobqueue.JobType(jobType)] = n
+	}
+	jobQueue.Start(concurrency)
 
 	// Get delay from environment or use default
 	delayMs := os.Getenv("DELAY_MS")
@@ -158,20 +260,46 @@ func main() {
 	e.GET("/swagger/*", echoSwagger.WrapHandler)
 
 	// Register module routes
-	auth.RegisterRoutes(e, authService)
+	auth.RegisterRoutes(e, protected, authService)
 	employees.RegisterRoutes(protected, employeeService)
 	inspections.RegisterRoutes(protected, inspectionService)
+	inspectionproperties.RegisterRoutes(protected, propertyService)
 	calibration.RegisterRoutes(protected, calibrationService)
-
-	// e.POST("/upload", authService.AuthMiddleware(uploadHandler))
-	// e.GET("/uploads", authService.AuthMiddleware(updloadsHandler))
-	// http.Handle("/api/uploads", http.StripPrefix("/api/uploads", http.FileServer(http.Dir("uploads"))))
+	tasks.RegisterRoutes(protected, taskService)
+	attachments.RegisterRoutes(protected, attachmentService)
+	uploads.RegisterRoutes(protected, uploadService)
+	transcripts.RegisterRoutes(protected, transcriptService)
+	search.RegisterRoutes(protected, searchService)
+	jobqueue.RegisterRoutes(e, protected, jobQueue, authService.AdminMiddleware, authService.StreamAuthMiddleware)
+	ReportGenerator.RegisterRoutes(protected, reportService, authService.AdminMiddleware)
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "2847"
 	}
 
+	// Shutdown waits for open requests, so end job streams instead of draining them
+	e.Server.RegisterOnShutdown(jobQueue.CloseStreams)
+
 	fmt.Printf("API server running at http://0.0.0.0:%s\n", port)
-	e.Logger.Fatal(e.Start(":" + port))
+	go func() {
+		if err := e.Start(":" + port); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			e.Logger.Fatal(err)
+		}
+	}()
+
+	// Stop taking requests on SIGINT/SIGTERM, then let running jobs finish
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	log.Info("Shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.JobDrainTimeout)
+	defer cancel()
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		log.Errorf("server shutdown: %v", err)
+	}
+	if err := jobQueue.Shutdown(shutdownCtx); err != nil {
+		log.Warnf("running jobs were interrupted and will resume on the next start: %v", err)
+	}
 }
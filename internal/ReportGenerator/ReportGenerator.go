@@ -1,60 +1,192 @@
 package ReportGenerator
 
 import (
-	// "bytes"
+	"bytes"
 	"context"
-	// "fmt"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
-	"os"
-	// "text/template"
+	"strings"
+	"time"
 
-	"github.com/ollama/ollama/api"
+	"qc_api/internal/inspections"
+	"qc_api/internal/llm"
+	"qc_api/internal/storage"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
-func GenerateReport(transcriptPath string, outputPath string) {
-	ctx := context.Background()
+var ErrEmptyTranscript = errors.New("transcript is empty")
+var ErrEmptyReport = errors.New("model returned an empty report")
 
-	client, err := api.ClientFromEnvironment()
-	if err != nil {
-		log.Fatalf("failed to create Ollama client: %v", err)
+const DefaultTimeout = 10 * time.Minute
+
+// systemPrompt is the default template's system prompt. The shape of the
+// answer is enforced separately through the request format.
+const systemPrompt = `You fill in lawn care quality control inspections from transcripts of an inspector's voice notes.
+Reply with a single JSON object matching the schema and nothing else.
+Use true or false only when the inspector clearly says so, otherwise null.
+Put everything else worth keeping in "report" as short plain sentences.`
+
+// ReportService turns stored transcripts into draft inspections using an LLM,
+// prompted through versioned templates kept in the database.
+type ReportService struct {
+	DB             *gorm.DB
+	LLM            llm.Provider
+	Store          storage.Storage
+	Timeout        time.Duration // bounds a single extraction; zero means no limit
+	RepairAttempts int           // times the model is asked to fix invalid output
+}
+
+func NewReportService(db *gorm.DB, provider llm.Provider, store storage.Storage) *ReportService {
+	return &ReportService{DB: db, LLM: provider, Store: store, Timeout: DefaultTimeout, RepairAttempts: 1}
+}
+
+// Output receives the model's reply as it is generated. Reset is called
+// before the model is asked to repair an invalid reply.
+type Output interface {
+	io.Writer
+	Reset()
+}
+
+// ReportRequest describes one report. Employee and LawnService are passed to
+// the prompt template as they are.
+type ReportRequest struct {
+	TranscriptKey string
+	OutputKey     string
+	TemplateID    *uuid.UUID // nil uses the default template
+	Employee      string
+	LawnService   string
+	Output        Output // optional
+}
+
+// Report is an extracted inspection and the template version that produced
+// it. The inspection has no EmployeeID; the caller knows who was inspected.
+type Report struct {
+	Inspection *inspections.InspectionDTO
+	Template   *PromptTemplate
+}
+
+// ExtractInspection reads the transcript stored under req.TranscriptKey and
+// asks the model for the inspection it describes. Output that does not match
+// the schema is repaired where possible, then sent back to the model with the
+// validation error. The validated inspection is stored as JSON under
+// req.OutputKey; nothing is stored if extraction fails.
+func (s *ReportService) ExtractInspection(ctx context.Context, req ReportRequest) (*Report, error) {
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
 	}
 
-	// read transcription from `transcript.txt`
-	transcript, err := os.ReadFile(transcriptPath)
+	prompt, err := s.resolvePromptTemplate(req.TemplateID)
+	if err != nil {
+		return nil, err
+	}
+	transcript, err := s.readTranscript(ctx, req.TranscriptKey)
 	if err != nil {
-		log.Fatalf("Failed to read transcript file: %v", err)
+		return nil, err
+	}
+	userPrompt, err := renderPrompt(prompt.UserTemplate, PromptData{
+		Transcript:  transcript,
+		Employee:    req.Employee,
+		LawnService: req.LawnService,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	model := "lawnqc"
-	prompt := string(transcript)
+	reply, err := s.generate(ctx, prompt, userPrompt, req.Output)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := parseFields(reply)
+	for attempt := 0; err != nil && attempt < s.RepairAttempts; attempt++ {
+		log.Printf("model output for %s failed validation: %v", req.TranscriptKey, err)
+		if req.Output != nil {
+			req.Output.Reset()
+		}
+		reply, err = s.generate(ctx, prompt, repairPrompt(userPrompt, reply, err), req.Output)
+		if err != nil {
+			return nil, err
+		}
+		fields, err = parseFields(reply)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// A model that found nothing else to say still leaves the inspector the
+	// transcript to review
+	if fields["report"] == "" {
+		fields["report"] = strings.TrimSpace(transcript)
+	}
+	extraction, err := fieldsToDTO(fields)
+	if err != nil {
+		return nil, err
+	}
 
-	// Open output file
-	outputFile, err := os.Create(outputPath)
+	data, err := json.MarshalIndent(fields, "", "  ")
 	if err != nil {
-		log.Fatalf("failed to create output file: %v", err)
+		return nil, err
+	}
+	if err := s.Store.Put(ctx, req.OutputKey, bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("failed to store report: %w", err)
 	}
-	defer func() {
-		if err := outputFile.Close(); err != nil {
-			log.Printf("Error closing output file: %v", err)
+	return &Report{Inspection: extraction, Template: prompt}, nil
+}
+
+// generate sends one prompt to the template's model, or the provider's when
+// the template names none, constrained to the
+// inspection schema, and returns the whole reply. Chunks are copied to output
+// as they arrive.
+func (s *ReportService) generate(ctx context.Context, template *PromptTemplate, prompt string, output Output) (string, error) {
+	var reply strings.Builder
+	err := s.LLM.Generate(ctx, llm.Request{
+		Model:   template.Model,
+		System:  template.SystemPrompt,
+		Prompt:  prompt,
+		Format:  InspectionSchema,
+		Options: template.Options,
+	}, func(chunk string) error {
+		reply.WriteString(chunk)
+		if output != nil {
+			if _, err := io.WriteString(output, chunk); err != nil {
+				return err
+			}
 		}
-	}()
-
-	// Stream to both stdout and file
-	// writer := io.MultiWriter(os.Stdout, outputFile)
-	writer := io.MultiWriter(outputFile)
-
-	// Stream and print each chunk as it arrives
-	err = client.Generate(ctx, &api.GenerateRequest{
-		Model:     model,
-		Prompt:    prompt,
-		KeepAlive: &api.Duration{Duration: 0},
-	}, func(resp api.GenerateResponse) error {
-		_, err := writer.Write([]byte(resp.Response))
-		return err
+		return nil
 	})
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", fmt.Errorf("report generation stopped: %w", ctxErr)
+		}
+		return "", fmt.Errorf("report generation failed: %w", err)
+	}
+	if strings.TrimSpace(reply.String()) == "" {
+		return "", ErrEmptyReport
+	}
+	return reply.String(), nil
+}
 
+func repairPrompt(prompt, reply string, err error) string {
+	return fmt.Sprintf("%s\n\nYour previous answer was invalid (%v):\n%s\n\nReply with the corrected JSON object only.", prompt, err, reply)
+}
+
+func (s *ReportService) readTranscript(ctx context.Context, key string) (string, error) {
+	r, err := s.Store.Get(ctx, key)
 	if err != nil {
-		log.Fatalf("failed to stream response: %v", err)
+		return "", fmt.Errorf("failed to read transcript: %w", err)
+	}
+	defer r.Close()
+	transcript, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to read transcript: %w", err)
+	}
+	if strings.TrimSpace(string(transcript)) == "" {
+		return "", ErrEmptyTranscript
 	}
+	return string(transcript), nil
 }
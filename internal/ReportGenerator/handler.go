@@ -0,0 +1,130 @@
+package ReportGenerator
+
+import (
+	"errors"
+	"net/http"
+
+	"qc_api/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// GetPromptTemplatesHandler godoc
+// @Summary Get prompt templates
+// @Description Retrieve the versions of the report prompt templates, by default by name and newest version first
+// @Tags prompt-templates
+// @Produce json
+// @Param name query string false "Filter by template name"
+// @Param is_default query bool false "Only the default template"
+// @Param limit query int false "Page size (default 50, at most 200)"
+// @Param offset query int false "Rows to skip"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort keys, - for descending (e.g. name,-version)"
+// @Param fields query string false "Comma separated fields to return (e.g. id,name,version)"
+// @Success 200 {object} utils.Page[PromptTemplate]
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /prompt-templates [get]
+func (s *ReportService) GetPromptTemplatesHandler(c echo.Context) error {
+	var filter PromptTemplateFilter
+	if err := c.Bind(&filter); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	templates, err := s.GetPromptTemplates(filter)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidQuery) {
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: "failed to retrieve prompt templates"})
+	}
+	return c.JSON(http.StatusOK, templates)
+}
+
+// GetPromptTemplateHandler godoc
+// @Summary Get prompt template by ID
+// @Description Retrieve one version of a report prompt template
+// @Tags prompt-templates
+// @Produce json
+// @Param id path string true "Prompt template ID"
+// @Success 200 {object} PromptTemplate
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /prompt-templates/{id} [get]
+func (s *ReportService) GetPromptTemplateHandler(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid prompt template id"})
+	}
+	prompt, err := s.GetPromptTemplateByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: "prompt template not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: "failed to retrieve prompt template"})
+	}
+	return c.JSON(http.StatusOK, prompt)
+}
+
+// PostPromptTemplateHandler godoc
+// @Summary Create a prompt template version
+// @Description Save a report prompt template. Using the name of an existing template adds its next version; earlier versions are kept. The user template is a Go text/template with {{.Transcript}}, {{.Employee}} and {{.LawnService}}. Admin only.
+// @Tags prompt-templates
+// @Accept json
+// @Produce json
+// @Param template body PromptTemplateDTO true "Prompt template"
+// @Success 201 {object} PromptTemplate
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 403 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /prompt-templates [post]
+func (s *ReportService) PostPromptTemplateHandler(c echo.Context) error {
+	var dto PromptTemplateDTO
+	if err := c.Bind(&dto); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid JSON"})
+	}
+	if err := c.Validate(&dto); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	prompt, err := s.CreatePromptTemplate(dto)
+	if errors.Is(err, ErrInvalidTemplate) {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: "failed to create prompt template"})
+	}
+	return c.JSON(http.StatusCreated, prompt)
+}
+
+// SetDefaultPromptTemplateHandler godoc
+// @Summary Make a prompt template version the default
+// @Description Use this template version for reports that do not select one. Choosing an older version rolls back a change. Admin only.
+// @Tags prompt-templates
+// @Produce json
+// @Param id path string true "Prompt template ID"
+// @Success 200 {object} PromptTemplate
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 403 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /prompt-templates/{id}/default [post]
+func (s *ReportService) SetDefaultPromptTemplateHandler(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid prompt template id"})
+	}
+	prompt, err := s.SetDefaultPromptTemplate(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: "prompt template not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: "failed to update prompt template"})
+	}
+	return c.JSON(http.StatusOK, prompt)
+}
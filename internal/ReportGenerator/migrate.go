@@ -0,0 +1,35 @@
+package ReportGenerator
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+const DefaultTemplateName = "default"
+
+// defaultUserTemplate is the prompt used before templates were configurable,
+// with the context that is now available to templates.
+const defaultUserTemplate = `{{if .Employee}}Technician: {{.Employee}}
+{{end}}{{if .LawnService}}Lawn service: {{.LawnService}}
+{{end}}Transcript:
+{{.Transcript}}`
+
+// Migrate makes sure there is a default prompt template. It must be called
+// after AutoMigrate and is safe to run on every startup.
+func Migrate(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var template PromptTemplate
+		err := tx.Where("is_default = ?", true).Take(&template).Error
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		return createVersion(tx, &PromptTemplate{
+			Name:         DefaultTemplateName,
+			SystemPrompt: systemPrompt,
+			UserTemplate: defaultUserTemplate,
+			Options:      map[string]any{"temperature": 0},
+			IsDefault:    true,
+		})
+	})
+}
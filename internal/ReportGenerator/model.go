@@ -0,0 +1,63 @@
+package ReportGenerator
+
+import (
+	"qc_api/internal/db"
+	"qc_api/internal/utils"
+)
+
+func Models() []any {
+	return []any{
+		&PromptTemplate{},
+	}
+}
+
+// PromptTemplate is one version of the instructions given to the report model.
+// Versions are never edited; saving a template with an existing name adds the
+// next version, so every report can be traced to the exact prompt behind it.
+type PromptTemplate struct {
+	db.BaseModel
+	Name         string         `gorm:"not null;uniqueIndex:idx_prompt_template_version" json:"name"`
+	Version      int            `gorm:"not null;uniqueIndex:idx_prompt_template_version" json:"version"`
+	SystemPrompt string         `json:"system_prompt"`
+	UserTemplate string         `gorm:"not null" json:"user_template"`            //text/template over PromptData
+	Model        string         `json:"model"`                                    //empty uses the provider's configured model
+	Options      map[string]any `gorm:"serializer:json" json:"options,omitempty"` //sampling options, e.g. temperature
+	IsDefault    bool           `gorm:"default:false;index" json:"is_default"`
+}
+
+// PromptData holds the values a user template can refer to, e.g.
+// {{.Transcript}}. Employee and LawnService are empty when unknown.
+type PromptData struct {
+	Transcript  string
+	Employee    string
+	LawnService string
+}
+
+// PromptTemplateDTO creates a template, or a new version of an existing one.
+type PromptTemplateDTO struct {
+	Name         string         `json:"name" validate:"required"`
+	SystemPrompt string         `json:"system_prompt"`
+	UserTemplate string         `json:"user_template" validate:"required"`
+	Model        string         `json:"model"`
+	Options      map[string]any `json:"options,omitempty"`
+	IsDefault    bool           `json:"is_default"`
+}
+
+type PromptTemplateFilter struct {
+	Name      *string `json:"name,omitempty" query:"name"`
+	IsDefault *bool   `json:"is_default,omitempty" query:"is_default"`
+	utils.PageParams
+}
+
+// promptTemplateSorting lists what prompt templates can be sorted by.
+var promptTemplateSorting = utils.Sorting{
+	Columns: map[string]string{
+		"created_at": "created_at",
+		"updated_at": "updated_at",
+		"name":       "name",
+		"version":    "version",
+		"model":      "model",
+		"is_default": "is_default",
+	},
+	Default: "name,-version",
+}
@@ -0,0 +1,350 @@
+package ReportGenerator_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"qc_api/internal/ReportGenerator"
+	"qc_api/internal/llm"
+	"qc_api/internal/storage"
+	"qc_api/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/ollama/ollama/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// fakeOllama serves /api/generate like Ollama does, streaming the reply as
+// newline delimited JSON chunks. Each request gets the next of replies; the
+// last one is repeated.
+type fakeOllama struct {
+	replies  [][]string
+	status   int
+	delay    time.Duration
+	requests []api.GenerateRequest
+}
+
+func (f *fakeOllama) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/api/generate" {
+		http.NotFound(w, r)
+		return
+	}
+	var req api.GenerateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.requests = append(f.requests, req)
+	if f.status != 0 {
+		w.WriteHeader(f.status)
+		json.NewEncoder(w).Encode(map[string]string{"error": "model \"" + req.Model + "\" not found"})
+		return
+	}
+
+	var chunks []string
+	if len(f.replies) > 0 {
+		chunks = f.replies[min(len(f.requests), len(f.replies))-1]
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	for _, chunk := range chunks {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(f.delay):
+		}
+		enc.Encode(api.GenerateResponse{Model: req.Model, Response: chunk})
+		w.(http.Flusher).Flush()
+	}
+	enc.Encode(api.GenerateResponse{Model: req.Model, Done: true, DoneReason: "stop"})
+}
+
+func setupTestDB() *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		panic("failed to connect database")
+	}
+	if err := db.AutoMigrate(ReportGenerator.Models()...); err != nil {
+		panic("failed to migrate database")
+	}
+	if err := ReportGenerator.Migrate(db); err != nil {
+		panic("failed to run prompt template migrations: " + err.Error())
+	}
+	return db
+}
+
+func setupService(t *testing.T, fake *fakeOllama) (*ReportGenerator.ReportService, storage.Storage) {
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	provider, err := llm.NewOllama(server.URL, llm.DefaultOllamaModel)
+	require.NoError(t, err)
+	store, err := storage.NewLocal(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, store.Put(context.Background(), "transcriptions/a.txt", strings.NewReader("PPE worn, spill kit present")))
+	require.NoError(t, store.Put(context.Background(), "transcriptions/empty.txt", strings.NewReader(" \n")))
+	return ReportGenerator.NewReportService(setupTestDB(), provider, store), store
+}
+
+const validReply = `{"uniform_ppe_good": true, "pic_present": null, "motive_logged_in": true,
+	"podium_logged_in": null, "spill_adsorbtion_present": true, "calibration": 3.2, "report": "Spreader calibrated"}`
+
+func TestExtractInspection(t *testing.T) {
+	// Setup
+	fake := &fakeOllama{replies: [][]string{{validReply[:40], validReply[40:]}}}
+	service, store := setupService(t, fake)
+
+	// Execute
+	report, err := service.ExtractInspection(context.Background(), ReportGenerator.ReportRequest{TranscriptKey: "transcriptions/a.txt", OutputKey: "reports/a.json"})
+
+	// Assertions
+	require.NoError(t, err)
+	assert.Equal(t, ReportGenerator.DefaultTemplateName, report.Template.Name)
+	assert.Equal(t, 1, report.Template.Version)
+	extraction := report.Inspection
+	assert.Equal(t, "Spreader calibrated", extraction.Report)
+	assert.Equal(t, float32(3.2), extraction.Calibration)
+	require.NotNil(t, extraction.UniformPPEGood)
+	assert.True(t, *extraction.UniformPPEGood)
+	assert.Nil(t, extraction.PICPresent)
+	assert.Len(t, extraction.ChecklistAnswers(), 3)
+
+	r, err := store.Get(context.Background(), "reports/a.json")
+	require.NoError(t, err)
+	defer r.Close()
+	stored, _ := io.ReadAll(r)
+	assert.JSONEq(t, validReply, string(stored))
+
+	require.Len(t, fake.requests, 1)
+	assert.Equal(t, "lawnqc", fake.requests[0].Model)
+	assert.Equal(t, "Transcript:\nPPE worn, spill kit present", fake.requests[0].Prompt)
+	assert.NotEmpty(t, fake.requests[0].System)
+	assert.Equal(t, map[string]any{"temperature": float64(0)}, fake.requests[0].Options)
+	assert.JSONEq(t, string(ReportGenerator.InspectionSchema), string(fake.requests[0].Format))
+}
+
+func TestExtractInspectionRepair(t *testing.T) {
+	// Setup
+	fake := &fakeOllama{replies: [][]string{{`{"calibration": "about three"}`}, {validReply}}}
+	service, _ := setupService(t, fake)
+
+	output := &recordingOutput{}
+
+	// Execute
+	report, err := service.ExtractInspection(context.Background(), ReportGenerator.ReportRequest{TranscriptKey: "transcriptions/a.txt", OutputKey: "reports/a.json", Output: output})
+
+	// Assertions
+	require.NoError(t, err)
+	assert.Equal(t, float32(3.2), report.Inspection.Calibration)
+	assert.Equal(t, []string{`{"calibration": "about three"}`, "<reset>", validReply}, output.writes)
+	require.Len(t, fake.requests, 2)
+	assert.Contains(t, fake.requests[1].Prompt, `calibration must be a number, got "about three"`)
+	assert.Contains(t, fake.requests[1].Prompt, `{"calibration": "about three"}`)
+}
+
+// recordingOutput records what is streamed to it.
+type recordingOutput struct {
+	writes []string
+}
+
+func (o *recordingOutput) Write(p []byte) (int, error) {
+	o.writes = append(o.writes, string(p))
+	return len(p), nil
+}
+
+func (o *recordingOutput) Reset() {
+	o.writes = append(o.writes, "<reset>")
+}
+
+func TestExtractInspectionEmptyNotes(t *testing.T) {
+	fake := &fakeOllama{replies: [][]string{{`{"calibration": 1, "report": ""}`}}}
+	service, _ := setupService(t, fake)
+
+	report, err := service.ExtractInspection(context.Background(), ReportGenerator.ReportRequest{TranscriptKey: "transcriptions/a.txt", OutputKey: "reports/a.json"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "PPE worn, spill kit present", report.Inspection.Report)
+}
+
+func TestExtractInspectionErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		fake          *fakeOllama
+		transcriptKey string
+		timeout       time.Duration
+		expectedErr   error
+		errContains   string
+	}{
+		{"missing transcript", &fakeOllama{}, "transcriptions/missing.txt", 0, storage.ErrNotFound, ""},
+		{"empty transcript", &fakeOllama{}, "transcriptions/empty.txt", 0, ReportGenerator.ErrEmptyTranscript, ""},
+		{"model error", &fakeOllama{status: http.StatusNotFound}, "transcriptions/a.txt", 0, nil, "not found"},
+		{"empty report", &fakeOllama{replies: [][]string{{"", "  "}}}, "transcriptions/a.txt", 0, ReportGenerator.ErrEmptyReport, ""},
+		{"still invalid after repair", &fakeOllama{replies: [][]string{{"PPE: yes"}}}, "transcriptions/a.txt", 0, ReportGenerator.ErrInvalidExtraction, ""},
+		{"timeout", &fakeOllama{replies: [][]string{{"slow"}}, delay: time.Second}, "transcriptions/a.txt", 50 * time.Millisecond, context.DeadlineExceeded, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, store := setupService(t, tt.fake)
+			if tt.timeout > 0 {
+				service.Timeout = tt.timeout
+			}
+
+			_, err := service.ExtractInspection(context.Background(), ReportGenerator.ReportRequest{TranscriptKey: tt.transcriptKey, OutputKey: "reports/a.json"})
+
+			require.Error(t, err)
+			if tt.expectedErr != nil {
+				assert.True(t, errors.Is(err, tt.expectedErr), "%v", err)
+			}
+			assert.Contains(t, err.Error(), tt.errContains)
+			exists, err := store.Exists(context.Background(), "reports/a.json")
+			require.NoError(t, err)
+			assert.False(t, exists, "no partial report is stored")
+		})
+	}
+}
+
+func TestExtractInspectionCancelled(t *testing.T) {
+	fake := &fakeOllama{replies: [][]string{{"one", "two"}}, delay: time.Second}
+	service, _ := setupService(t, fake)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := service.ExtractInspection(ctx, ReportGenerator.ReportRequest{TranscriptKey: "transcriptions/a.txt", OutputKey: "reports/a.json"})
+
+	assert.True(t, errors.Is(err, context.Canceled), "%v", err)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestExtractInspectionWithTemplate(t *testing.T) {
+	// Setup
+	fake := &fakeOllama{replies: [][]string{{validReply}}}
+	service, _ := setupService(t, fake)
+	prompt, err := service.CreatePromptTemplate(ReportGenerator.PromptTemplateDTO{
+		Name:         "with context",
+		SystemPrompt: "Be brief.",
+		UserTemplate: "{{.Employee}} applied {{.LawnService}}.\n{{.Transcript}}",
+		Model:        "qwen3:8b",
+		Options:      map[string]any{"temperature": 0.3},
+	})
+	require.NoError(t, err)
+
+	// Execute
+	report, err := service.ExtractInspection(context.Background(), ReportGenerator.ReportRequest{
+		TranscriptKey: "transcriptions/a.txt",
+		OutputKey:     "reports/a.json",
+		TemplateID:    &prompt.ID,
+		Employee:      "Sam Lee",
+		LawnService:   "LS01 Spring Fertilizer",
+	})
+
+	// Assertions
+	require.NoError(t, err)
+	assert.Equal(t, prompt.ID, report.Template.ID)
+	require.Len(t, fake.requests, 1)
+	assert.Equal(t, "qwen3:8b", fake.requests[0].Model)
+	assert.Equal(t, "Be brief.", fake.requests[0].System)
+	assert.Equal(t, "Sam Lee applied LS01 Spring Fertilizer.\nPPE worn, spill kit present", fake.requests[0].Prompt)
+	assert.Equal(t, map[string]any{"temperature": 0.3}, fake.requests[0].Options)
+
+	// Unknown templates are not silently replaced by the default
+	missing := uuid.New()
+	_, err = service.ExtractInspection(context.Background(), ReportGenerator.ReportRequest{TranscriptKey: "transcriptions/a.txt", OutputKey: "reports/b.json", TemplateID: &missing})
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound), "%v", err)
+}
+
+func TestPromptTemplateVersions(t *testing.T) {
+	// Setup
+	service, _ := setupService(t, &fakeOllama{})
+	dto := ReportGenerator.PromptTemplateDTO{Name: "site visit", UserTemplate: "{{.Transcript}}", Model: "lawnqc"}
+
+	// Execute
+	v1, err := service.CreatePromptTemplate(dto)
+	require.NoError(t, err)
+	dto.UserTemplate = "Technician {{.Employee}}:\n{{.Transcript}}"
+	dto.IsDefault = true
+	v2, err := service.CreatePromptTemplate(dto)
+	require.NoError(t, err)
+
+	// Assertions
+	assert.Equal(t, 1, v1.Version)
+	assert.Equal(t, 2, v2.Version)
+	name := "site visit"
+	versions, err := service.GetPromptTemplates(ReportGenerator.PromptTemplateFilter{Name: &name})
+	require.NoError(t, err)
+	require.Len(t, versions.Data, 2)
+	assert.Equal(t, v2.ID, versions.Data[0].ID)
+	assert.Equal(t, "{{.Transcript}}", versions.Data[1].UserTemplate, "earlier versions are kept")
+	_, err = service.GetPromptTemplates(ReportGenerator.PromptTemplateFilter{PageParams: utils.PageParams{Sort: "user_template"}})
+	assert.ErrorIs(t, err, utils.ErrInvalidQuery)
+
+	current, err := service.GetDefaultPromptTemplate()
+	require.NoError(t, err)
+	assert.Equal(t, v2.ID, current.ID)
+
+	// Rolling back to the first version
+	_, err = service.SetDefaultPromptTemplate(v1.ID)
+	require.NoError(t, err)
+	isDefault := true
+	defaults, err := service.GetPromptTemplates(ReportGenerator.PromptTemplateFilter{IsDefault: &isDefault})
+	require.NoError(t, err)
+	require.Len(t, defaults.Data, 1)
+	assert.Equal(t, v1.ID, defaults.Data[0].ID)
+
+	// Templates that cannot render are rejected up front
+	for _, text := range []string{"{{.Transcript", "{{.Customer}}"} {
+		dto.UserTemplate = text
+		_, err = service.CreatePromptTemplate(dto)
+		assert.True(t, errors.Is(err, ReportGenerator.ErrInvalidTemplate), "%q: %v", text, err)
+	}
+}
+
+func TestPromptTemplateHandlers(t *testing.T) {
+	// Setup
+	service, _ := setupService(t, &fakeOllama{})
+	e := echo.New()
+	e.Validator = utils.NewValidator()
+
+	tests := []struct {
+		name         string
+		body         string
+		expectedCode int
+	}{
+		{"valid", `{"name": "short", "user_template": "{{.Transcript}}", "model": "lawnqc"}`, http.StatusCreated},
+		{"provider model", `{"name": "short", "user_template": "{{.Transcript}}"}`, http.StatusCreated},
+		{"missing template", `{"name": "short", "model": "lawnqc"}`, http.StatusBadRequest},
+		{"bad template", `{"name": "short", "user_template": "{{if .Transcript}}", "model": "lawnqc"}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/prompt-templates", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+
+			err := service.PostPromptTemplateHandler(e.NewContext(req, rec))
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedCode, rec.Code, rec.Body.String())
+		})
+	}
+
+	// Setting an unknown template as default
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec)
+	c.SetParamNames("id")
+	c.SetParamValues(uuid.New().String())
+	require.NoError(t, service.SetDefaultPromptTemplateHandler(c))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,12 @@
+package ReportGenerator
+
+import "github.com/labstack/echo/v4"
+
+// RegisterRoutes registers the prompt template routes; adminOnly guards
+// changes, since they affect every report.
+func RegisterRoutes(g *echo.Group, reportService *ReportService, adminOnly echo.MiddlewareFunc) {
+	g.GET("/prompt-templates", reportService.GetPromptTemplatesHandler)
+	g.GET("/prompt-templates/:id", reportService.GetPromptTemplateHandler)
+	g.POST("/prompt-templates", reportService.PostPromptTemplateHandler, adminOnly)
+	g.POST("/prompt-templates/:id/default", reportService.SetDefaultPromptTemplateHandler, adminOnly)
+}
@@ -0,0 +1,219 @@
+package ReportGenerator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"qc_api/internal/inspections"
+)
+
+var ErrInvalidExtraction = errors.New("model output does not match the inspection schema")
+
+// extractedFields are the InspectionDTO fields, by JSON name, that the model
+// fills in from a transcript, with the description it is given for each.
+var extractedFields = []struct{ name, description string }{
+	{inspections.KeyUniformPPEGood, "Technician's uniform and PPE are in good condition"},
+	{inspections.KeyPICPresent, "The person in charge was present"},
+	{inspections.KeyMotiveLoggedIn, "Technician was logged in to Motive"},
+	{inspections.KeyPodiumLoggedIn, "Technician was logged in to Podium"},
+	{inspections.KeySpillAdsorbtionPresent, "A spill kit or absorbent was on the truck"},
+	{"calibration", "Spreader calibration reading, 0 if none was taken"},
+	{"report", "Notes: anything else the inspector observed, as short sentences"},
+}
+
+// schemaField is an extracted field with the JSON type of its DTO field.
+type schemaField struct {
+	name     string
+	kind     string // boolean, number or string
+	nullable bool
+}
+
+// InspectionSchema is the JSON schema the model's answer must match, built
+// from the types of the InspectionDTO fields so the two cannot drift apart.
+var schemaFields, InspectionSchema = buildSchema()
+
+func buildSchema() ([]schemaField, json.RawMessage) {
+	dto := reflect.TypeOf(inspections.InspectionDTO{})
+	types := make(map[string]reflect.Type, dto.NumField())
+	for i := 0; i < dto.NumField(); i++ {
+		name, _, _ := strings.Cut(dto.Field(i).Tag.Get("json"), ",")
+		types[name] = dto.Field(i).Type
+	}
+
+	fields := make([]schemaField, 0, len(extractedFields))
+	properties := make(map[string]any, len(extractedFields))
+	required := make([]string, 0, len(extractedFields))
+	for _, f := range extractedFields {
+		t, ok := types[f.name]
+		if !ok {
+			panic("InspectionDTO has no field " + f.name)
+		}
+		field := schemaField{name: f.name, nullable: t.Kind() == reflect.Pointer}
+		if field.nullable {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Bool:
+			field.kind = "boolean"
+		case reflect.Float32, reflect.Float64:
+			field.kind = "number"
+		case reflect.String:
+			field.kind = "string"
+		default:
+			panic(fmt.Sprintf("InspectionDTO field %s has unsupported type %s", f.name, t))
+		}
+
+		property := map[string]any{"type": field.kind, "description": f.description}
+		if field.nullable {
+			property["type"] = []string{field.kind, "null"}
+		}
+		if field.kind == "number" {
+			property["minimum"] = 0
+		}
+		fields = append(fields, field)
+		properties[f.name] = property
+		required = append(required, f.name)
+	}
+
+	schema, err := json.Marshal(map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return fields, schema
+}
+
+// ParseExtraction validates a model reply against the inspection schema and
+// returns the inspection it describes. Small mistakes are repaired: code
+// fences and text around the object, trailing commas, "yes"/"no" for
+// booleans, numbers written as strings and missing fields. Unknown fields
+// are dropped.
+func ParseExtraction(reply string) (*inspections.InspectionDTO, error) {
+	fields, err := parseFields(reply)
+	if err != nil {
+		return nil, err
+	}
+	return fieldsToDTO(fields)
+}
+
+func parseFields(reply string) (map[string]any, error) {
+	var raw map[string]any
+	if err := json.Unmarshal([]byte(reply), &raw); err != nil {
+		if err := json.Unmarshal([]byte(repairJSON(reply)), &raw); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidExtraction, err)
+		}
+	}
+
+	fields := make(map[string]any, len(schemaFields))
+	for _, f := range schemaFields {
+		value, err := f.coerce(raw[f.name])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s %v", ErrInvalidExtraction, f.name, err)
+		}
+		fields[f.name] = value
+	}
+	return fields, nil
+}
+
+func fieldsToDTO(fields map[string]any) (*inspections.InspectionDTO, error) {
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	var dto inspections.InspectionDTO
+	if err := json.Unmarshal(data, &dto); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidExtraction, err)
+	}
+	return &dto, nil
+}
+
+var trailingComma = regexp.MustCompile(`,\s*([}\]])`)
+
+// repairJSON cuts the outermost object out of a reply and drops trailing
+// commas, the usual ways a model breaks otherwise valid JSON.
+func repairJSON(reply string) string {
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return reply
+	}
+	return trailingComma.ReplaceAllString(reply[start:end+1], "$1")
+}
+
+func (f schemaField) coerce(value any) (any, error) {
+	if value == nil {
+		switch {
+		case f.nullable:
+			return nil, nil
+		case f.kind == "number":
+			return 0.0, nil
+		default:
+			return "", nil
+		}
+	}
+
+	switch f.kind {
+	case "boolean":
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			switch strings.ToLower(strings.TrimSpace(v)) {
+			case "true", "yes", "y":
+				return true, nil
+			case "false", "no", "n":
+				return false, nil
+			case "", "null", "unknown", "n/a":
+				if f.nullable {
+					return nil, nil
+				}
+			}
+		}
+		return nil, fmt.Errorf("must be true, false or null, got %v", value)
+
+	case "number":
+		var n float64
+		switch v := value.(type) {
+		case float64:
+			n = v
+		case string:
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, fmt.Errorf("must be a number, got %q", v)
+			}
+			n = parsed
+		default:
+			return nil, fmt.Errorf("must be a number, got %v", value)
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("must not be negative, got %v", n)
+		}
+		return n, nil
+
+	default:
+		switch v := value.(type) {
+		case string:
+			return strings.TrimSpace(v), nil
+		case []any:
+			// Notes are sometimes returned as a list of sentences
+			lines := make([]string, 0, len(v))
+			for _, item := range v {
+				s, ok := item.(string)
+				if !ok {
+					return nil, fmt.Errorf("must be a string, got %v", value)
+				}
+				lines = append(lines, strings.TrimSpace(s))
+			}
+			return strings.Join(lines, "\n"), nil
+		}
+		return nil, fmt.Errorf("must be a string, got %v", value)
+	}
+}
@@ -0,0 +1,72 @@
+package ReportGenerator_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"qc_api/internal/ReportGenerator"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspectionSchema(t *testing.T) {
+	var schema struct {
+		Type       string `json:"type"`
+		Properties map[string]struct {
+			Type any `json:"type"`
+		} `json:"properties"`
+		Required []string `json:"required"`
+	}
+	require.NoError(t, json.Unmarshal(ReportGenerator.InspectionSchema, &schema))
+
+	assert.Equal(t, "object", schema.Type)
+	assert.Len(t, schema.Required, 7)
+	assert.Equal(t, []any{"boolean", "null"}, schema.Properties["uniform_ppe_good"].Type)
+	assert.Equal(t, "number", schema.Properties["calibration"].Type)
+	assert.Equal(t, "string", schema.Properties["report"].Type)
+	assert.NotContains(t, schema.Properties, "employee_id")
+}
+
+func TestParseExtraction(t *testing.T) {
+	tests := []struct {
+		name        string
+		reply       string
+		report      string
+		calibration float32
+		ppe         *bool
+		expectedErr bool
+	}{
+		{"valid", `{"uniform_ppe_good": false, "calibration": 2, "report": "ok"}`, "ok", 2, ptr(false), false},
+		{"code fence and prose", "Here you go:\n```json\n{\"report\": \"ok\"}\n```", "ok", 0, nil, false},
+		{"trailing commas", `{"report": "ok", "calibration": 1.5,}`, "ok", 1.5, nil, false},
+		{"yes and no", `{"uniform_ppe_good": "Yes", "pic_present": "unknown"}`, "", 0, ptr(true), false},
+		{"number as string", `{"calibration": " 4.25 "}`, "", 4.25, nil, false},
+		{"notes as a list", `{"report": ["Truck clean.", "Gate left open."]}`, "Truck clean.\nGate left open.", 0, nil, false},
+		{"unknown fields dropped", `{"report": "ok", "employee_id": "someone", "status": "submitted"}`, "ok", 0, nil, false},
+		{"negative calibration", `{"calibration": -1}`, "", 0, nil, true},
+		{"not a boolean", `{"uniform_ppe_good": "mostly"}`, "", 0, nil, true},
+		{"not json", `PPE: yes`, "", 0, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dto, err := ReportGenerator.ParseExtraction(tt.reply)
+
+			if tt.expectedErr {
+				assert.True(t, errors.Is(err, ReportGenerator.ErrInvalidExtraction), "%v", err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.report, dto.Report)
+			assert.Equal(t, tt.calibration, dto.Calibration)
+			assert.Equal(t, tt.ppe, dto.UniformPPEGood)
+			assert.Empty(t, dto.Status)
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
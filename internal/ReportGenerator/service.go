@@ -0,0 +1,139 @@
+package ReportGenerator
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"qc_api/internal/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidTemplate = errors.New("invalid prompt template")
+var ErrNoDefaultTemplate = errors.New("no default prompt template")
+
+// === Prompt Templates ===
+
+// CreatePromptTemplate saves the template as the next version of its name.
+func (s *ReportService) CreatePromptTemplate(dto PromptTemplateDTO) (*PromptTemplate, error) {
+	// Catch syntax errors and references to fields PromptData does not have before anyone
+	// relies on the template
+	sample := PromptData{Transcript: "transcript", Employee: "employee", LawnService: "lawn service"}
+	if _, err := renderPrompt(dto.UserTemplate, sample); err != nil {
+		return nil, err
+	}
+
+	prompt := &PromptTemplate{
+		Name:         strings.TrimSpace(dto.Name),
+		SystemPrompt: dto.SystemPrompt,
+		UserTemplate: dto.UserTemplate,
+		Model:        dto.Model,
+		Options:      dto.Options,
+		IsDefault:    dto.IsDefault,
+	}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		return createVersion(tx, prompt)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return prompt, nil
+}
+
+// createVersion numbers prompt after the latest version of its name and makes
+// it the only default if it is marked as one.
+func createVersion(tx *gorm.DB, prompt *PromptTemplate) error {
+	var latest int
+	if err := tx.Model(&PromptTemplate{}).Where("name = ?", prompt.Name).Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+		return err
+	}
+	prompt.Version = latest + 1
+	if prompt.IsDefault {
+		if err := clearDefault(tx); err != nil {
+			return err
+		}
+	}
+	return tx.Create(prompt).Error
+}
+
+func clearDefault(tx *gorm.DB) error {
+	return tx.Model(&PromptTemplate{}).Where("is_default = ?", true).Update("is_default", false).Error
+}
+
+func (s *ReportService) GetPromptTemplates(filter PromptTemplateFilter) (*utils.Page[PromptTemplate], error) {
+	query := utils.ApplyFilter(s.DB.Model(&PromptTemplate{}), filter)
+	return utils.Paginate[PromptTemplate](query, filter.PageParams, promptTemplateSorting)
+}
+
+func (s *ReportService) GetPromptTemplateByID(id uuid.UUID) (*PromptTemplate, error) {
+	var prompt PromptTemplate
+	if err := s.DB.Where("id = ?", id).First(&prompt).Error; err != nil {
+		return nil, err
+	}
+	return &prompt, nil
+}
+
+func (s *ReportService) GetDefaultPromptTemplate() (*PromptTemplate, error) {
+	var prompt PromptTemplate
+	err := s.DB.Where("is_default = ?", true).Take(&prompt).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNoDefaultTemplate
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &prompt, nil
+}
+
+// SetDefaultPromptTemplate makes a template version the one used by reports
+// that do not ask for a specific template. Any version can be chosen, which is
+// how a bad change is rolled back.
+func (s *ReportService) SetDefaultPromptTemplate(id uuid.UUID) (*PromptTemplate, error) {
+	var prompt PromptTemplate
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", id).First(&prompt).Error; err != nil {
+			return err
+		}
+		if err := clearDefault(tx); err != nil {
+			return err
+		}
+		prompt.IsDefault = true
+		return tx.Model(&prompt).Update("is_default", true).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &prompt, nil
+}
+
+// resolvePromptTemplate returns the template with id, or the default template
+// when id is nil.
+func (s *ReportService) resolvePromptTemplate(id *uuid.UUID) (*PromptTemplate, error) {
+	if id == nil {
+		return s.GetDefaultPromptTemplate()
+	}
+	return s.GetPromptTemplateByID(*id)
+}
+
+func parseUserTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("prompt").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+	return tmpl, nil
+}
+
+func renderPrompt(text string, data PromptData) (string, error) {
+	tmpl, err := parseUserTemplate(text)
+	if err != nil {
+		return "", err
+	}
+	var prompt strings.Builder
+	if err := tmpl.Execute(&prompt, data); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+	return prompt.String(), nil
+}
@@ -0,0 +1,208 @@
+package attachments_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"qc_api/internal/attachments"
+	"qc_api/internal/calibration"
+	inspectionproperties "qc_api/internal/inspectionProperties"
+	"qc_api/internal/inspections"
+	"qc_api/internal/storage"
+	"qc_api/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB() *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		panic("failed to connect database")
+	}
+	var models []any
+	models = append(models, inspections.Models()...)
+	models = append(models, inspectionproperties.Models()...)
+	models = append(models, calibration.Models()...)
+	models = append(models, attachments.Models()...)
+	if err := db.AutoMigrate(models...); err != nil {
+		panic("failed to migrate database")
+	}
+	if err := inspections.Migrate(db); err != nil {
+		panic("failed to run inspection migrations: " + err.Error())
+	}
+	return db
+}
+
+func uploadContext(t *testing.T, id, filename string, content []byte) (echo.Context, *httptest.ResponseRecorder) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile("file", filename)
+	require.NoError(t, err)
+	_, err = part.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/", &body)
+	req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(id)
+	c.Set("user_id", uuid.New())
+	return c, rec
+}
+
+func idContext(id string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+	c.SetParamNames("id")
+	c.SetParamValues(id)
+	return c, rec
+}
+
+func pngImage(t *testing.T, w, h int, fill color.Color) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			img.Set(x, y, fill)
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestUploadAttachment(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	dir := t.TempDir()
+	store, err := storage.NewLocal(dir)
+	require.NoError(t, err)
+	service := attachments.NewAttachmentService(db, store, 64<<10)
+	inspection, err := inspections.NewInspectionService(db).CreateInspection(&inspections.Inspection{Report: "ok", EmployeeID: uuid.New()})
+	require.NoError(t, err)
+	photo := pngImage(t, 800, 400, color.RGBA{R: 200, A: 255})
+
+	tests := []struct {
+		name         string
+		ownerID      string
+		filename     string
+		content      []byte
+		expectedCode int
+	}{
+		{"photo", inspection.ID.String(), "../../etc/ppe.png", photo, http.StatusCreated},
+		{"same photo again", inspection.ID.String(), "copy.png", photo, http.StatusOK},
+		{"pdf", inspection.ID.String(), "report.pdf", []byte("%PDF-1.4\n%fake pdf body\n"), http.StatusCreated},
+		{"type is sniffed, not taken from the name", inspection.ID.String(), "photo.jpg", []byte{0x7f, 'E', 'L', 'F', 2, 1, 1, 0, 0, 0}, http.StatusUnsupportedMediaType},
+		{"corrupt image", inspection.ID.String(), "broken.png", append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...), http.StatusBadRequest},
+		{"too large", inspection.ID.String(), "big.txt", bytes.Repeat([]byte("a"), 65<<10), http.StatusRequestEntityTooLarge},
+		{"unknown inspection", uuid.New().String(), "ppe.png", photo, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, rec := uploadContext(t, tt.ownerID, tt.filename, tt.content)
+
+			err := service.PostInspectionAttachmentHandler(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedCode, rec.Code, rec.Body.String())
+		})
+	}
+
+	// Assertions
+	page, err := service.ReadAttachments(attachments.OwnerInspection, inspection.ID, utils.PageParams{})
+	require.NoError(t, err)
+	list := page.Data
+	require.Len(t, list, 2)
+	assert.Equal(t, "ppe.png", list[0].Filename)
+	assert.Equal(t, "image/png", list[0].ContentType)
+	assert.True(t, list[0].HasThumbnail)
+	assert.Len(t, list[0].SHA256, 64)
+	assert.Equal(t, "application/pdf", list[1].ContentType)
+	assert.False(t, list[1].HasThumbnail)
+	page, err = service.ReadAttachments(attachments.OwnerInspection, inspection.ID, utils.PageParams{Limit: 1, Sort: "-created_at"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), page.Total)
+	require.Len(t, page.Data, 1)
+	assert.Equal(t, list[1].ID, page.Data[0].ID)
+	_, err = service.ReadAttachments(attachments.OwnerInspection, inspection.ID, utils.PageParams{Sort: "sha256"})
+	assert.ErrorIs(t, err, utils.ErrInvalidQuery)
+
+	// Only the two accepted files were kept on disk, plus one thumbnail
+	var stored []string
+	require.NoError(t, filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			stored = append(stored, filepath.Base(path))
+		}
+		return err
+	}))
+	assert.Len(t, stored, 3)
+}
+
+func TestDownloadAttachment(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	dir := t.TempDir()
+	store, err := storage.NewLocal(dir)
+	require.NoError(t, err)
+	service := attachments.NewAttachmentService(db, store, 1<<20)
+	log := &calibration.CalibrationLog{}
+	require.NoError(t, db.Create(log).Error)
+	photo := pngImage(t, 640, 960, color.RGBA{G: 200, A: 255})
+	attachment, created, err := service.CreateAttachment(attachments.OwnerCalibrationLog, log.ID, "spreader.png", bytes.NewReader(photo), uuid.New())
+	require.NoError(t, err)
+	require.True(t, created)
+
+	// Original
+	c, rec := idContext(attachment.ID.String())
+	require.NoError(t, service.DownloadAttachmentHandler(c))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, photo, rec.Body.Bytes())
+	assert.Equal(t, "image/png", rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, `attachment; filename=spreader.png`, rec.Header().Get(echo.HeaderContentDisposition))
+	assert.Equal(t, "nosniff", rec.Header().Get(echo.HeaderXContentTypeOptions))
+
+	// Thumbnail keeps the aspect ratio within the size limit
+	c, rec = idContext(attachment.ID.String())
+	require.NoError(t, service.GetAttachmentThumbnailHandler(c))
+	require.Equal(t, http.StatusOK, rec.Code)
+	thumbnail, err := jpeg.Decode(rec.Body)
+	require.NoError(t, err)
+	assert.Equal(t, image.Pt(attachments.ThumbnailSize*2/3, attachments.ThumbnailSize), thumbnail.Bounds().Size())
+
+	// Listing through the calibration log route
+	c, rec = idContext(log.ID.String())
+	require.NoError(t, service.GetCalibrationLogAttachmentsHandler(c))
+	var listed utils.Page[attachments.Attachment]
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed))
+	assert.Len(t, listed.Data, 1)
+
+	// Deleting the last reference removes the stored content
+	c, rec = idContext(attachment.ID.String())
+	require.NoError(t, service.DeleteAttachmentHandler(c))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	_, err = os.Stat(filepath.Join(dir, "attachments", attachment.SHA256[:2], attachment.SHA256))
+	assert.True(t, os.IsNotExist(err))
+
+	c, rec = idContext(attachment.ID.String())
+	require.NoError(t, service.DownloadAttachmentHandler(c))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
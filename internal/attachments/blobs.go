@@ -0,0 +1,96 @@
+package attachments
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+
+	"qc_api/internal/storage"
+)
+
+var sha256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// blobStore keeps file contents in storage addressed by their SHA-256, so the
+// same bytes uploaded twice are only stored once.
+type blobStore struct {
+	store storage.Storage
+}
+
+func (b blobStore) key(hash, suffix string) (string, error) {
+	if !sha256Pattern.MatchString(hash) {
+		return "", fmt.Errorf("invalid blob hash %q", hash)
+	}
+	return "attachments/" + hash[:2] + "/" + hash + suffix, nil
+}
+
+// put hashes r into a temporary file before storing it, returning the hash
+// and size. Existing blobs with the same hash are left untouched. Nothing is
+// stored if r holds more than limit bytes.
+func (b blobStore) put(ctx context.Context, r io.Reader, limit int64) (string, int64, error) {
+	tmp, err := os.CreateTemp("", "attachment-*")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), io.LimitReader(r, limit+1))
+	if err != nil {
+		return "", 0, err
+	}
+	if size > limit {
+		return "", 0, ErrFileTooLarge
+	}
+
+	hash := hex.EncodeToString(h.Sum(nil))
+	key, err := b.key(hash, "")
+	if err != nil {
+		return "", 0, err
+	}
+	if exists, err := b.store.Exists(ctx, key); err != nil || exists {
+		return hash, size, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return "", 0, err
+	}
+	return hash, size, b.store.Put(ctx, key, tmp)
+}
+
+// putAt stores data alongside the blob with the given hash.
+func (b blobStore) putAt(ctx context.Context, hash, suffix string, data []byte) error {
+	key, err := b.key(hash, suffix)
+	if err != nil {
+		return err
+	}
+	return b.store.Put(ctx, key, bytes.NewReader(data))
+}
+
+func (b blobStore) exists(ctx context.Context, hash, suffix string) (bool, error) {
+	key, err := b.key(hash, suffix)
+	if err != nil {
+		return false, err
+	}
+	return b.store.Exists(ctx, key)
+}
+
+func (b blobStore) open(ctx context.Context, hash, suffix string) (io.ReadCloser, error) {
+	key, err := b.key(hash, suffix)
+	if err != nil {
+		return nil, err
+	}
+	return b.store.Get(ctx, key)
+}
+
+func (b blobStore) remove(ctx context.Context, hash, suffix string) error {
+	key, err := b.key(hash, suffix)
+	if err != nil {
+		return err
+	}
+	return b.store.Delete(ctx, key)
+}
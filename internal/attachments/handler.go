@@ -0,0 +1,318 @@
+package attachments
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"qc_api/internal/storage"
+	"qc_api/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+func attachmentErrorResponse(c echo.Context, err error, notFound string) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, storage.ErrNotFound):
+		return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: notFound})
+	case errors.Is(err, ErrFileTooLarge):
+		return c.JSON(http.StatusRequestEntityTooLarge, utils.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, ErrUnsupportedType):
+		return c.JSON(http.StatusUnsupportedMediaType, utils.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, ErrInvalidImage), errors.Is(err, ErrImageTooLarge):
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+}
+
+// upload reads the multipart "file" field and attaches it to the owner in the
+// :id path parameter.
+func (s *AttachmentService) upload(c echo.Context, ownerType OwnerType, notFound string) error {
+	ownerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid id"})
+	}
+	// Leave headroom for the multipart framing around the file itself.
+	req := c.Request()
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, s.MaxSize+1<<20)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return attachmentErrorResponse(c, ErrFileTooLarge, notFound)
+		}
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "No file uploaded"})
+	}
+	if fileHeader.Size > s.MaxSize {
+		return attachmentErrorResponse(c, ErrFileTooLarge, notFound)
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "failed to read upload"})
+	}
+	defer file.Close()
+	uploadedBy, _ := c.Get("user_id").(uuid.UUID)
+
+	attachment, created, err := s.CreateAttachment(ownerType, ownerID, fileHeader.Filename, file, uploadedBy)
+	if err != nil {
+		return attachmentErrorResponse(c, err, notFound)
+	}
+	if !created {
+		return c.JSON(http.StatusOK, attachment)
+	}
+	return c.JSON(http.StatusCreated, attachment)
+}
+
+func (s *AttachmentService) list(c echo.Context, ownerType OwnerType) error {
+	ownerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid id"})
+	}
+	var params utils.PageParams
+	if err := c.Bind(&params); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	attachments, err := s.ReadAttachments(ownerType, ownerID, params)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidQuery) {
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: "failed to retrieve attachments"})
+	}
+	return c.JSON(http.StatusOK, attachments)
+}
+
+// === Inspection Attachments ===
+
+// PostInspectionAttachmentHandler godoc
+// @Summary Attach a file to an inspection
+// @Description Upload a photo (JPEG, PNG, GIF, WebP), PDF or plain text file as multipart field "file". The type is sniffed from the content. Uploading identical content to the same inspection again returns the existing attachment with 200.
+// @Tags attachments
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path string true "Inspection ID"
+// @Param file formData file true "File to attach"
+// @Success 201 {object} Attachment
+// @Success 200 {object} Attachment
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 413 {object} utils.ErrorResponse
+// @Failure 415 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /inspections/{id}/attachments [post]
+func (s *AttachmentService) PostInspectionAttachmentHandler(c echo.Context) error {
+	return s.upload(c, OwnerInspection, "inspection not found")
+}
+
+// GetInspectionAttachmentsHandler godoc
+// @Summary Get attachments of an inspection
+// @Description Retrieve the files attached to an inspection, by default oldest first
+// @Tags attachments
+// @Produce json
+// @Param id path string true "Inspection ID"
+// @Param limit query int false "Page size (default 50, at most 200)"
+// @Param offset query int false "Rows to skip"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort keys, - for descending (e.g. -created_at,filename)"
+// @Param fields query string false "Comma separated fields to return (e.g. id,filename)"
+// @Success 200 {object} utils.Page[Attachment]
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /inspections/{id}/attachments [get]
+func (s *AttachmentService) GetInspectionAttachmentsHandler(c echo.Context) error {
+	return s.list(c, OwnerInspection)
+}
+
+// === Property Attachments ===
+
+// PostPropertyAttachmentHandler godoc
+// @Summary Attach a file to an inspection property
+// @Description Upload a photo (JPEG, PNG, GIF, WebP), PDF or plain text file as multipart field "file" against a property visited during an inspection
+// @Tags attachments
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path string true "Property ID"
+// @Param file formData file true "File to attach"
+// @Success 201 {object} Attachment
+// @Success 200 {object} Attachment
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 413 {object} utils.ErrorResponse
+// @Failure 415 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /properties/{id}/attachments [post]
+func (s *AttachmentService) PostPropertyAttachmentHandler(c echo.Context) error {
+	return s.upload(c, OwnerProperty, "property not found")
+}
+
+// GetPropertyAttachmentsHandler godoc
+// @Summary Get attachments of an inspection property
+// @Description Retrieve the files attached to a property, by default oldest first
+// @Tags attachments
+// @Produce json
+// @Param id path string true "Property ID"
+// @Param limit query int false "Page size (default 50, at most 200)"
+// @Param offset query int false "Rows to skip"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort keys, - for descending (e.g. -created_at,filename)"
+// @Param fields query string false "Comma separated fields to return (e.g. id,filename)"
+// @Success 200 {object} utils.Page[Attachment]
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /properties/{id}/attachments [get]
+func (s *AttachmentService) GetPropertyAttachmentsHandler(c echo.Context) error {
+	return s.list(c, OwnerProperty)
+}
+
+// === Calibration Log Attachments ===
+
+// PostCalibrationLogAttachmentHandler godoc
+// @Summary Attach a file to a calibration log
+// @Description Upload a photo (JPEG, PNG, GIF, WebP), PDF or plain text file as multipart field "file" against a calibration log
+// @Tags attachments
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path string true "Calibration Log ID"
+// @Param file formData file true "File to attach"
+// @Success 201 {object} Attachment
+// @Success 200 {object} Attachment
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 413 {object} utils.ErrorResponse
+// @Failure 415 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /calibrationlogs/{id}/attachments [post]
+func (s *AttachmentService) PostCalibrationLogAttachmentHandler(c echo.Context) error {
+	return s.upload(c, OwnerCalibrationLog, "calibration log not found")
+}
+
+// GetCalibrationLogAttachmentsHandler godoc
+// @Summary Get attachments of a calibration log
+// @Description Retrieve the files attached to a calibration log, by default oldest first
+// @Tags attachments
+// @Produce json
+// @Param id path string true "Calibration Log ID"
+// @Param limit query int false "Page size (default 50, at most 200)"
+// @Param offset query int false "Rows to skip"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort keys, - for descending (e.g. -created_at,filename)"
+// @Param fields query string false "Comma separated fields to return (e.g. id,filename)"
+// @Success 200 {object} utils.Page[Attachment]
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /calibrationlogs/{id}/attachments [get]
+func (s *AttachmentService) GetCalibrationLogAttachmentsHandler(c echo.Context) error {
+	return s.list(c, OwnerCalibrationLog)
+}
+
+// === Attachments ===
+
+// GetAttachmentHandler godoc
+// @Summary Get attachment metadata
+// @Description Retrieve an attachment's metadata by its ID
+// @Tags attachments
+// @Produce json
+// @Param id path string true "Attachment ID"
+// @Success 200 {object} Attachment
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /attachments/{id} [get]
+func (s *AttachmentService) GetAttachmentHandler(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid attachment id"})
+	}
+	attachment, err := s.ReadAttachment(id)
+	if err != nil {
+		return attachmentErrorResponse(c, err, "attachment not found")
+	}
+	return c.JSON(http.StatusOK, attachment)
+}
+
+func (s *AttachmentService) serve(c echo.Context, thumbnail bool) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid attachment id"})
+	}
+	attachment, err := s.ReadAttachment(id)
+	if err != nil {
+		return attachmentErrorResponse(c, err, "attachment not found")
+	}
+	content, err := s.OpenAttachment(attachment, thumbnail)
+	if err != nil {
+		return attachmentErrorResponse(c, err, "thumbnail not found")
+	}
+	defer content.Close()
+
+	contentType, disposition := attachment.ContentType, "attachment"
+	if thumbnail {
+		contentType, disposition = "image/jpeg", "inline"
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename}))
+	c.Response().Header().Set(echo.HeaderXContentTypeOptions, "nosniff")
+	c.Response().Header().Set("ETag", `"`+attachment.SHA256+`"`)
+	return c.Stream(http.StatusOK, contentType, content)
+}
+
+// DownloadAttachmentHandler godoc
+// @Summary Download an attachment
+// @Description Download the original file of an attachment
+// @Tags attachments
+// @Produce octet-stream
+// @Param id path string true "Attachment ID"
+// @Success 200 {file} file
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /attachments/{id}/download [get]
+func (s *AttachmentService) DownloadAttachmentHandler(c echo.Context) error {
+	return s.serve(c, false)
+}
+
+// GetAttachmentThumbnailHandler godoc
+// @Summary Get an attachment thumbnail
+// @Description Download a JPEG thumbnail of an image attachment
+// @Tags attachments
+// @Produce jpeg
+// @Param id path string true "Attachment ID"
+// @Success 200 {file} file
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /attachments/{id}/thumbnail [get]
+func (s *AttachmentService) GetAttachmentThumbnailHandler(c echo.Context) error {
+	return s.serve(c, true)
+}
+
+// DeleteAttachmentHandler godoc
+// @Summary Delete an attachment
+// @Description Delete an attachment. Its content is removed once no other attachment shares it.
+// @Tags attachments
+// @Param id path string true "Attachment ID"
+// @Success 204
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /attachments/{id} [delete]
+func (s *AttachmentService) DeleteAttachmentHandler(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid attachment id"})
+	}
+	if err := s.DeleteAttachment(id); err != nil {
+		return attachmentErrorResponse(c, err, "attachment not found")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,56 @@
+package attachments
+
+import (
+	"qc_api/internal/db"
+	"qc_api/internal/utils"
+
+	"github.com/google/uuid"
+)
+
+func Models() []any {
+	return []any{
+		&Attachment{},
+	}
+}
+
+type OwnerType string
+
+const (
+	OwnerInspection     OwnerType = "inspection"
+	OwnerProperty       OwnerType = "inspection_property"
+	OwnerCalibrationLog OwnerType = "calibration_log"
+)
+
+// ownerTables maps each owner type to the table its IDs refer to.
+var ownerTables = map[OwnerType]string{
+	OwnerInspection:     "inspections",
+	OwnerProperty:       "inspection_properties",
+	OwnerCalibrationLog: "calibration_logs",
+}
+
+// Attachment is a photo or document uploaded against an inspection, an
+// inspection property or a calibration log. Files are stored once per
+// SHA-256, so identical uploads share storage.
+type Attachment struct {
+	db.BaseModel
+	OwnerType    OwnerType `gorm:"index:idx_attachment_owner;not null" json:"owner_type"`
+	OwnerID      uuid.UUID `gorm:"type:string;index:idx_attachment_owner;not null" json:"owner_id"`
+	Filename     string    `gorm:"not null" json:"filename"`     //sanitized client filename, for display and downloads
+	ContentType  string    `gorm:"not null" json:"content_type"` //sniffed from the content, not the client header
+	Size         int64     `json:"size"`
+	SHA256       string    `gorm:"index;not null" json:"sha256"`
+	HasThumbnail bool      `json:"has_thumbnail"`
+	UploadedBy   uuid.UUID `gorm:"type:string" json:"uploaded_by"`
+}
+
+// attachmentSorting lists what attachments can be sorted by.
+var attachmentSorting = utils.Sorting{
+	Columns: map[string]string{
+		"created_at":   "created_at",
+		"updated_at":   "updated_at",
+		"filename":     "filename",
+		"content_type": "content_type",
+		"size":         "size",
+	},
+	Default: "created_at",
+}
@@ -0,0 +1,16 @@
+package attachments
+
+import "github.com/labstack/echo/v4"
+
+func RegisterRoutes(g *echo.Group, attachmentService *AttachmentService) {
+	g.POST("/inspections/:id/attachments", attachmentService.PostInspectionAttachmentHandler)
+	g.GET("/inspections/:id/attachments", attachmentService.GetInspectionAttachmentsHandler)
+	g.POST("/properties/:id/attachments", attachmentService.PostPropertyAttachmentHandler)
+	g.GET("/properties/:id/attachments", attachmentService.GetPropertyAttachmentsHandler)
+	g.POST("/calibrationlogs/:id/attachments", attachmentService.PostCalibrationLogAttachmentHandler)
+	g.GET("/calibrationlogs/:id/attachments", attachmentService.GetCalibrationLogAttachmentsHandler)
+	g.GET("/attachments/:id", attachmentService.GetAttachmentHandler)
+	g.GET("/attachments/:id/download", attachmentService.DownloadAttachmentHandler)
+	g.GET("/attachments/:id/thumbnail", attachmentService.GetAttachmentThumbnailHandler)
+	g.DELETE("/attachments/:id", attachmentService.DeleteAttachmentHandler)
+}
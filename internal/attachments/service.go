@@ -0,0 +1,222 @@
+package attachments
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"qc_api/internal/storage"
+	"qc_api/internal/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrFileTooLarge = errors.New("file exceeds the attachment size limit")
+var ErrUnsupportedType = errors.New("unsupported attachment type")
+var ErrInvalidImage = errors.New("image could not be decoded")
+
+const thumbnailSuffix = ".thumb.jpg"
+
+// allowedContentTypes are the sniffed types accepted as attachments.
+var allowedContentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+	"text/plain":      true,
+}
+
+type AttachmentService struct {
+	DB      *gorm.DB
+	MaxSize int64
+	blobs   blobStore
+}
+
+func NewAttachmentService(db *gorm.DB, store storage.Storage, maxSize int64) *AttachmentService {
+	return &AttachmentService{DB: db, MaxSize: maxSize, blobs: blobStore{store: store}}
+}
+
+// sniffContentType detects the type of content from its first bytes, ignoring
+// whatever the client claimed.
+func sniffContentType(head []byte) string {
+	contentType := http.DetectContentType(head)
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	return contentType
+}
+
+// sanitizeFilename keeps only the base name of a client supplied filename,
+// dropping control characters and quotes so it is safe in headers.
+func sanitizeFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '"' || r == '/' {
+			return -1
+		}
+		return r
+	}, name)
+	if len(name) > 255 {
+		name = name[len(name)-255:]
+	}
+	if name == "" || name == "." || name == ".." {
+		return "attachment"
+	}
+	return name
+}
+
+func (s *AttachmentService) checkOwner(ownerType OwnerType, ownerID uuid.UUID) error {
+	table, ok := ownerTables[ownerType]
+	if !ok {
+		return fmt.Errorf("unknown attachment owner type %q", ownerType)
+	}
+	var count int64
+	if err := s.DB.Table(table).Where("id = ? AND deleted_at IS NULL", ownerID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// CreateAttachment stores r against its owner. Uploading the same content to
+// the same owner again returns the existing attachment with created false.
+func (s *AttachmentService) CreateAttachment(ownerType OwnerType, ownerID uuid.UUID, filename string, r io.Reader, uploadedBy uuid.UUID) (attachment *Attachment, created bool, err error) {
+	if err := s.checkOwner(ownerType, ownerID); err != nil {
+		return nil, false, err
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, false, err
+	}
+	head = head[:n]
+	contentType := sniffContentType(head)
+	if n == 0 || !allowedContentTypes[contentType] {
+		return nil, false, fmt.Errorf("%w: %s", ErrUnsupportedType, contentType)
+	}
+
+	hash, size, err := s.blobs.put(context.Background(), io.MultiReader(bytes.NewReader(head), r), s.MaxSize)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var existing Attachment
+	err = s.DB.Where("owner_type = ? AND owner_id = ? AND sha256 = ?", ownerType, ownerID, hash).First(&existing).Error
+	if err == nil {
+		return &existing, false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+
+	attachment = &Attachment{
+		OwnerType:   ownerType,
+		OwnerID:     ownerID,
+		Filename:    sanitizeFilename(filename),
+		ContentType: contentType,
+		Size:        size,
+		SHA256:      hash,
+		UploadedBy:  uploadedBy,
+	}
+	if strings.HasPrefix(contentType, "image/") {
+		if err := s.storeThumbnail(hash); err != nil {
+			s.removeUnreferenced(hash)
+			if errors.Is(err, ErrImageTooLarge) {
+				return nil, false, err
+			}
+			return nil, false, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+		}
+		attachment.HasThumbnail = true
+	}
+	if err := s.DB.Create(attachment).Error; err != nil {
+		return nil, false, err
+	}
+	return attachment, true, nil
+}
+
+func (s *AttachmentService) storeThumbnail(hash string) error {
+	ctx := context.Background()
+	if exists, err := s.blobs.exists(ctx, hash, thumbnailSuffix); err != nil || exists {
+		return err
+	}
+	f, err := s.blobs.open(ctx, hash, "")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// Images are bounded by MaxSize, so decoding from memory is fine and
+	// works the same for every storage backend
+	data, err := io.ReadAll(io.LimitReader(f, s.MaxSize))
+	if err != nil {
+		return err
+	}
+	thumbnail, err := makeThumbnail(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	return s.blobs.putAt(ctx, hash, thumbnailSuffix, thumbnail)
+}
+
+func (s *AttachmentService) ReadAttachments(ownerType OwnerType, ownerID uuid.UUID, params utils.PageParams) (*utils.Page[Attachment], error) {
+	query := s.DB.Model(&Attachment{}).Where("owner_type = ? AND owner_id = ?", ownerType, ownerID)
+	return utils.Paginate[Attachment](query, params, attachmentSorting)
+}
+
+func (s *AttachmentService) ReadAttachment(id uuid.UUID) (*Attachment, error) {
+	var attachment Attachment
+	result := s.DB.Where("id = ?", id).First(&attachment)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &attachment, nil
+}
+
+// OpenAttachment returns the attachment's content, or its thumbnail.
+func (s *AttachmentService) OpenAttachment(attachment *Attachment, thumbnail bool) (io.ReadCloser, error) {
+	if thumbnail {
+		if !attachment.HasThumbnail {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return s.blobs.open(context.Background(), attachment.SHA256, thumbnailSuffix)
+	}
+	return s.blobs.open(context.Background(), attachment.SHA256, "")
+}
+
+// DeleteAttachment removes the attachment, and its stored content once no
+// other attachment shares it.
+func (s *AttachmentService) DeleteAttachment(id uuid.UUID) error {
+	attachment, err := s.ReadAttachment(id)
+	if err != nil {
+		return err
+	}
+	if err := s.DB.Delete(attachment).Error; err != nil {
+		return err
+	}
+	s.removeUnreferenced(attachment.SHA256)
+	return nil
+}
+
+// removeUnreferenced deletes stored content that no attachment refers to.
+func (s *AttachmentService) removeUnreferenced(hash string) {
+	var remaining int64
+	if err := s.DB.Model(&Attachment{}).Where("sha256 = ?", hash).Count(&remaining).Error; err != nil || remaining > 0 {
+		return
+	}
+	for _, suffix := range []string{"", thumbnailSuffix} {
+		if err := s.blobs.remove(context.Background(), hash, suffix); err != nil {
+			log.Printf("failed to remove attachment blob %s%s: %v", hash, suffix, err)
+		}
+	}
+}
@@ -1,17 +1,21 @@
 package auth_test
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"qc_api/internal/auth"
 	"qc_api/internal/config"
 	"qc_api/internal/utils"
 
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
@@ -31,7 +35,7 @@ func TestRegister(t *testing.T) {
 	// Setup
 	cfg := config.NewConfig()
 	db := setupTestDB()
-	authService := auth.NewAuthService(db, cfg.JWTSecret)
+	authService := auth.NewAuthService(db, cfg.JWTSecret, time.Duration(cfg.AuthTimeout)*time.Millisecond)
 	e := echo.New()
 	e.Validator = utils.NewValidator()
 
@@ -60,7 +64,7 @@ func TestAuthMiddleware(t *testing.T) {
 	// Setup
 	cfg := config.NewConfig()
 	db := setupTestDB()
-	authService := auth.NewAuthService(db, cfg.JWTSecret)
+	authService := auth.NewAuthService(db, cfg.JWTSecret, time.Duration(cfg.AuthTimeout)*time.Millisecond)
 	e := echo.New()
 	e.Validator = utils.NewValidator()
 
@@ -88,3 +92,67 @@ func TestAuthMiddleware(t *testing.T) {
 	assert.NoError(t, h(c))
 	assert.Equal(t, http.StatusOK, rec.Code)
 }
+
+func TestStreamToken(t *testing.T) {
+	// Setup
+	cfg := config.NewConfig()
+	db := setupTestDB()
+	authService := auth.NewAuthService(db, cfg.JWTSecret, time.Duration(cfg.AuthTimeout)*time.Millisecond)
+	e := echo.New()
+	e.Validator = utils.NewValidator()
+	protected := e.Group("", authService.AuthMiddleware)
+	auth.RegisterRoutes(e, protected, authService)
+	protected.GET("/protected", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+	e.GET("/jobs/:id/stream", func(c echo.Context) error {
+		return c.String(http.StatusOK, c.Get("user_id").(uuid.UUID).String())
+	}, authService.StreamAuthMiddleware)
+
+	user, _ := auth.NewUser("testuser4", "password123")
+	require.NoError(t, authService.CreateUser(user))
+	login, _ := authService.GenerateJWT(user)
+	serve := func(method, target, bearer, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if bearer != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+bearer)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Issuing a token needs the login token
+	assert.Equal(t, http.StatusUnauthorized, serve(http.MethodPost, "/stream-tokens", "", `{"path": "/jobs/1/stream"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/stream-tokens", login, `{"path": "jobs/1/stream"}`).Code)
+	rec := serve(http.MethodPost, "/stream-tokens", login, `{"path": "/jobs/1/stream"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var issued auth.StreamTokenResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &issued))
+	assert.WithinDuration(t, time.Now().Add(auth.StreamTokenTimeout), issued.ExpiresAt, 5*time.Second)
+
+	tests := []struct {
+		name         string
+		method       string
+		target       string
+		bearer       string
+		expectedCode int
+	}{
+		{"stream token", http.MethodGet, "/jobs/1/stream?token=" + issued.Token, "", http.StatusOK},
+		{"login token", http.MethodGet, "/jobs/1/stream", login, http.StatusOK},
+		{"no token", http.MethodGet, "/jobs/1/stream", "", http.StatusUnauthorized},
+		{"other stream", http.MethodGet, "/jobs/2/stream?token=" + issued.Token, "", http.StatusUnauthorized},
+		{"login token as stream token", http.MethodGet, "/jobs/1/stream?token=" + login, "", http.StatusUnauthorized},
+		{"stream token as login token", http.MethodGet, "/protected", issued.Token, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(tt.method, tt.target, tt.bearer, "")
+
+			assert.Equal(t, tt.expectedCode, rec.Code, rec.Body.String())
+			if tt.expectedCode == http.StatusOK {
+				assert.Equal(t, user.ID.String(), rec.Body.String())
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 	"qc_api/internal/utils"
 
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
@@ -76,3 +77,37 @@ func (s *AuthService) RegisterHandler(c echo.Context) error {
 	}
 	return c.JSON(http.StatusOK, user)
 }
+
+// StreamTokenHandler godoc
+// @Summary Get a stream token
+// @Description Issue a token that opens one event stream, such as /jobs/{id}/stream, for a minute. Browser EventSource cannot send the Authorization header, so pass it as the token query parameter of the stream instead. The token is not accepted on any other route.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body StreamTokenDTO true "Stream to open"
+// @Success 200 {object} StreamTokenResponse
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 401 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /stream-tokens [post]
+func (s *AuthService) StreamTokenHandler(c echo.Context) error {
+	var dto StreamTokenDTO
+	if err := c.Bind(&dto); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid JSON"})
+	}
+	if err := c.Validate(&dto); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	userID, ok := c.Get("user_id").(uuid.UUID)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, utils.ErrorResponse{Error: "Missing token"})
+	}
+
+	token, expires, err := s.GenerateStreamToken(userID, dto.Path)
+	if err != nil {
+		log.Printf("Tried to create stream token: %v", err)
+		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: "Token generation failed"})
+	}
+	return c.JSON(http.StatusOK, StreamTokenResponse{Token: token, ExpiresAt: expires})
+}
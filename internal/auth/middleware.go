@@ -39,3 +39,52 @@ func (s *AuthService) AuthMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 		return next(c)
 	}
 }
+
+// StreamAuthMiddleware authenticates like AuthMiddleware, or with a stream
+// token for the requested path in the token query parameter, as browser
+// EventSource cannot set the Authorization header.
+func (s *AuthService) StreamAuthMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	authenticated := s.AuthMiddleware(next)
+	return func(c echo.Context) error {
+		token := c.QueryParam("token")
+		if token == "" {
+			return authenticated(c)
+		}
+
+		userId, err := s.validateStreamToken(token, c.Request().URL.Path)
+		if err != nil {
+			if err := c.JSON(http.StatusUnauthorized, map[string]string{
+				"error": "Invalid token",
+			}); err != nil {
+				return err
+			}
+			return errors.New("invalid stream token")
+		}
+		parsedUserID, err := uuid.Parse(userId)
+		if err != nil {
+			return errors.New("failed to parse UserID")
+		}
+		c.Set("user_id", parsedUserID)
+		return next(c)
+	}
+}
+
+// AdminMiddleware only lets admin users through. It must run after
+// AuthMiddleware.
+func (s *AuthService) AdminMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		userID, ok := c.Get("user_id").(uuid.UUID)
+		if !ok {
+			return c.JSON(http.StatusUnauthorized, map[string]string{
+				"error": "Missing token",
+			})
+		}
+		user, err := s.getUserByID(userID.String())
+		if err != nil || !user.Admin {
+			return c.JSON(http.StatusForbidden, map[string]string{
+				"error": "Admin access required",
+			})
+		}
+		return next(c)
+	}
+}
@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"time"
+
 	"qc_api/internal/db"
 )
 
@@ -31,6 +33,16 @@ type LoginResponse struct {
 	Token string `json:"token"`
 }
 
+// StreamTokenDTO names the stream a token is requested for, e.g. /jobs/{id}/stream.
+type StreamTokenDTO struct {
+	Path string `json:"path" validate:"required,startswith=/"`
+}
+
+type StreamTokenResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
 func NewUser(username, password string) (*User, error) {
 	hashedPassword, err := hashPassword(password)
 	if err != nil {
@@ -2,7 +2,10 @@ package auth
 
 import "github.com/labstack/echo/v4"
 
-func RegisterRoutes(e *echo.Echo, authService *AuthService) {
+// RegisterRoutes registers logging in and registering on e, and issuing stream
+// tokens on the authenticated group g.
+func RegisterRoutes(e *echo.Echo, g *echo.Group, authService *AuthService) {
 	e.POST("/login", authService.LoginHandler)
 	e.POST("/register", authService.RegisterHandler)
+	g.POST("/stream-tokens", authService.StreamTokenHandler)
 }
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
@@ -15,6 +16,9 @@ import (
 var ErrUserAlreadyExists = errors.New("user already exists")
 var ErrInvalidCredentials = errors.New("invalid credentials")
 
+// StreamTokenTimeout is how long a stream token can be used to open its stream.
+const StreamTokenTimeout = time.Minute
+
 type AuthService struct {
 	DB           *gorm.DB
 	jwtSecret    []byte
@@ -111,22 +115,61 @@ func (s *AuthService) GenerateJWT(user *User) (string, error) {
 	return token.SignedString(s.jwtSecret)
 }
 
-func (s *AuthService) validateJWT(tokenStr string) (string, error) {
+// GenerateStreamToken returns a token that authenticates userID on the stream
+// at path only, for StreamTokenTimeout. It is meant for clients such as
+// browser EventSource that cannot send the Authorization header.
+func (s *AuthService) GenerateStreamToken(userID uuid.UUID, path string) (string, time.Time, error) {
+	expires := time.Now().Add(StreamTokenTimeout)
+	claims := jwt.MapClaims{
+		"user_id": userID.String(),
+		"path":    path,
+		"exp":     expires.Unix(),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.jwtSecret)
+	return token, expires, err
+}
+
+func (s *AuthService) parseJWT(tokenStr string, options ...jwt.ParserOption) (jwt.MapClaims, error) {
 	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (any, error) {
 		// We only use HS256, so we check that the signing method is what we expect.
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return s.jwtSecret, nil
-	})
+	}, options...)
 	if err != nil || !token.Valid {
-		return "", fmt.Errorf("invalid token")
+		return nil, fmt.Errorf("invalid token")
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok || claims["user_id"] == nil {
+	if !ok {
+		return nil, fmt.Errorf("invalid claims")
+	}
+	if _, ok := claims["user_id"].(string); !ok {
+		return nil, fmt.Errorf("invalid claims")
+	}
+	return claims, nil
+}
+
+func (s *AuthService) validateJWT(tokenStr string) (string, error) {
+	claims, err := s.parseJWT(tokenStr)
+	if err != nil {
+		return "", err
+	}
+	// Stream tokens only open the stream they were issued for
+	if _, ok := claims["path"]; ok {
 		return "", fmt.Errorf("invalid claims")
 	}
+	return claims["user_id"].(string), nil
+}
 
+func (s *AuthService) validateStreamToken(tokenStr, path string) (string, error) {
+	claims, err := s.parseJWT(tokenStr, jwt.WithExpirationRequired())
+	if err != nil {
+		return "", err
+	}
+	if claims["path"] != path {
+		return "", fmt.Errorf("token is not for %s", path)
+	}
 	return claims["user_id"].(string), nil
 }
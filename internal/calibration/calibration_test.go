@@ -1,6 +1,8 @@
 package calibration_test
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -123,6 +125,61 @@ func TestPostLawnServiceMissingCode(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 }
 
+func TestGetLawnServices(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	calibService := calibration.NewCalibrationService(db)
+	e := echo.New()
+	formulation := &calibration.Formulation{Name: "GRANULE"}
+	db.Create(formulation)
+	for _, code := range []string{"LS03", "LS01", "LS02"} {
+		db.Create(&calibration.LawnService{
+			Code:                   code,
+			Description:            "Spring Fertilizer",
+			FormulationID:          formulation.ID,
+			TargetCalibrationValue: 1.5,
+			TargetCalibrationUnit:  "kg",
+			MeasurementUnit:        "kg",
+			CalibrationFunction:    "current_amount / current_area",
+		})
+	}
+
+	tests := []struct {
+		name          string
+		query         string
+		expectedCode  int
+		expectedCodes []string
+	}{
+		{"by code", "", http.StatusOK, []string{"LS01", "LS02", "LS03"}},
+		{"paged", "limit=2&sort=-code", http.StatusOK, []string{"LS03", "LS02"}},
+		{"filtered", "code=LS02", http.StatusOK, []string{"LS02"}},
+		{"unknown sort", "sort=calibration_function", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/lawnservices?"+tt.query, nil)
+			rec := httptest.NewRecorder()
+
+			err := calibService.GetLawnServicesHandler(e.NewContext(req, rec))
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedCode, rec.Code, rec.Body.String())
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+			var page utils.Page[calibration.LawnService]
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+			codes := []string{}
+			for _, service := range page.Data {
+				codes = append(codes, service.Code)
+				assert.Equal(t, "GRANULE", service.Formulation.Name)
+			}
+			assert.Equal(t, tt.expectedCodes, codes)
+		})
+	}
+}
+
 func TestPostCalibrationLog(t *testing.T) {
 	// Setup
 	db := setupTestDB()
@@ -403,3 +460,139 @@ func TestGetCalibrationLogWithRecords(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rec.Code)
 	assert.Contains(t, rec.Body.String(), `"current_calibration":0.015`)
 }
+
+func TestGetCalibrationLogsByLawnService(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	calibService := calibration.NewCalibrationService(db)
+	e := echo.New()
+
+	// Two logs for a granular service, one for a liquid one
+	for i, name := range []string{"GRANULE", "LIQUID"} {
+		formulation := &calibration.Formulation{Name: name}
+		db.Create(formulation)
+		lawnService := &calibration.LawnService{
+			Code:                   fmt.Sprintf("LS0%d", i+1),
+			Description:            "Spring Fertilizer",
+			FormulationID:          formulation.ID,
+			TargetCalibrationValue: 1.5,
+			TargetCalibrationUnit:  "kg",
+			MeasurementUnit:        "kg",
+			CalibrationFunction:    "current_amount / current_area",
+		}
+		db.Create(lawnService)
+		for range 2 - i {
+			db.Create(&calibration.CalibrationLog{UserID: uuid.New(), LawnServiceID: lawnService.ID})
+		}
+	}
+
+	tests := []struct {
+		name          string
+		query         string
+		expectedCount int
+		expectedTotal int64
+	}{
+		{"formulation", "lawn_service.formulation.name=GRANULE", 2, 2},
+		{"formulation, paged", "lawn_service.formulation.name=GRANULE&limit=1&sort=-created_at", 1, 2},
+		{"lawn service code", "lawn_service.code=LS02", 1, 1},
+		{"code and formulation", "lawn_service.code=LS02&lawn_service.formulation.name=GRANULE", 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/calibrationlogs?"+tt.query, nil)
+			rec := httptest.NewRecorder()
+
+			err := calibService.GetCalibrationLogsHandler(e.NewContext(req, rec))
+
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			var page utils.Page[calibration.CalibrationLog]
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+			assert.Len(t, page.Data, tt.expectedCount)
+			assert.Equal(t, tt.expectedTotal, page.Total)
+		})
+	}
+}
+
+func TestGetCalibrationCertificate(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	calibService := calibration.NewCalibrationService(db)
+	e := echo.New()
+	e.Validator = utils.NewValidator()
+
+	// Create a formulation and lawn service
+	formulation := &calibration.Formulation{Name: "GRANULE"}
+	db.Create(formulation)
+	lawnService := &calibration.LawnService{
+		Code:                            "LS01",
+		Description:                     "Spring Fertilizer",
+		FormulationID:                   formulation.ID,
+		TargetCalibrationValue:          0.015,
+		TargetCalibrationUnit:           "kg/m2",
+		MeasurementUnit:                 "kg",
+		CalibrationFunction:             "current_amount / current_area",
+		DifferentialCalibrationFunction: "(current_amount - previous_amount) / (current_area - previous_area)",
+	}
+	db.Create(lawnService)
+
+	// Create a calibration log with a record
+	calibLog := &calibration.CalibrationLog{UserID: uuid.New(), LawnServiceID: lawnService.ID, Equipment: "Spreader 7"}
+	db.Create(calibLog)
+	record := &calibration.CalibrationRecord{
+		CalibrationLogID: calibLog.ID,
+		MeasurementValue: 1.5,
+		MeasurementUnit:  "kg",
+		MeasurementArea:  100,
+	}
+	db.Create(record)
+
+	// Request
+	req := httptest.NewRequest(http.MethodGet, "/calibrationlogs/"+calibLog.ID.String()+"/certificate", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(calibLog.ID.String())
+
+	// Handler
+	err := calibService.GetCalibrationCertificateHandler(c)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/pdf", rec.Header().Get(echo.HeaderContentType))
+	assert.True(t, strings.HasPrefix(rec.Body.String(), "%PDF-"))
+
+	// The hash must match the stored log so the certificate can be verified later
+	stored, err := calibService.ReadCalibrationLog(calibLog.ID)
+	assert.NoError(t, err)
+	assert.Len(t, rec.Header().Get("X-Certificate-Hash"), 64)
+	assert.Equal(t, calibration.CertificateHash(stored), rec.Header().Get("X-Certificate-Hash"))
+}
+
+func TestGetCalibrationCertificateIncomplete(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	calibService := calibration.NewCalibrationService(db)
+	e := echo.New()
+	e.Validator = utils.NewValidator()
+
+	// Create a calibration log without records
+	calibLog := &calibration.CalibrationLog{UserID: uuid.New(), LawnServiceID: uuid.New()}
+	db.Create(calibLog)
+
+	// Request
+	req := httptest.NewRequest(http.MethodGet, "/calibrationlogs/"+calibLog.ID.String()+"/certificate", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(calibLog.ID.String())
+
+	// Handler
+	err := calibService.GetCalibrationCertificateHandler(c)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+}
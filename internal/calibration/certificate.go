@@ -0,0 +1,153 @@
+package calibration
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"qc_api/internal/auth"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/google/uuid"
+)
+
+var ErrCalibrationLogIncomplete = errors.New("calibration log has no final calibration")
+
+// CalibrationCertificate holds everything printed on a calibration certificate.
+type CalibrationCertificate struct {
+	Log         CalibrationLog
+	Technician  string
+	CompletedAt time.Time
+	IssuedAt    time.Time
+	Hash        string
+}
+
+// BuildCalibrationCertificate loads a calibration log and prepares it for rendering.
+// A log is only certifiable once it has records and a final calibration.
+func (s *CalibrationService) BuildCalibrationCertificate(logID uuid.UUID) (*CalibrationCertificate, error) {
+	log, err := s.ReadCalibrationLog(logID)
+	if err != nil {
+		return nil, err
+	}
+	if len(log.Records) == 0 || log.CurrentCalibration == nil {
+		return nil, ErrCalibrationLogIncomplete
+	}
+
+	technician := log.UserID.String()
+	var user auth.User
+	if err := s.DB.Select("username").First(&user, "id = ?", log.UserID).Error; err == nil {
+		technician = user.Username
+	}
+
+	return &CalibrationCertificate{
+		Log:         log,
+		Technician:  technician,
+		CompletedAt: log.Records[len(log.Records)-1].CreatedAt,
+		IssuedAt:    time.Now().UTC(),
+		Hash:        CertificateHash(log),
+	}, nil
+}
+
+// CertificateHash returns a SHA-256 digest over the certified contents of a log.
+// The digest only changes if the log, its lawn service or its records change,
+// so a printed certificate can be checked against the database later.
+func CertificateHash(log CalibrationLog) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "log:%s\n", log.ID)
+	fmt.Fprintf(&b, "technician:%s\n", log.UserID)
+	fmt.Fprintf(&b, "equipment:%s\n", log.Equipment)
+	fmt.Fprintf(&b, "lawn_service:%s\n", log.LawnService.Code)
+	fmt.Fprintf(&b, "formulation:%s\n", log.LawnService.Formulation.Name)
+	fmt.Fprintf(&b, "target:%.4f %s\n", log.LawnService.TargetCalibrationValue, log.LawnService.TargetCalibrationUnit)
+	for _, r := range log.Records {
+		fmt.Fprintf(&b, "record:%s|%s|%.4f|%s|%d|%.6f\n",
+			r.ID, r.CreatedAt.UTC().Format(time.RFC3339Nano), r.MeasurementValue, r.MeasurementUnit, r.MeasurementArea, r.Calibration)
+	}
+	if log.CurrentCalibration != nil {
+		fmt.Fprintf(&b, "final:%.6f\n", *log.CurrentCalibration)
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// RenderCalibrationCertificate writes the certificate as a PDF to w.
+func RenderCalibrationCertificate(cert *CalibrationCertificate, w io.Writer) error {
+	log := cert.Log
+	service := log.LawnService
+
+	pdf := fpdf.New("P", "mm", "Letter", "")
+	pdf.SetTitle("Calibration Certificate "+log.ID.String(), false)
+	pdf.SetCreator("QC API", false)
+	pdf.SetCreationDate(cert.IssuedAt)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(0, 10, "Spreader Calibration Certificate", "", 1, "C", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	pdf.CellFormat(0, 5, "Certificate "+log.ID.String(), "", 1, "C", false, 0, "")
+	pdf.Ln(6)
+
+	row := func(label, value string) {
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(50, 7, label, "", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.CellFormat(0, 7, tr(value), "", 1, "L", false, 0, "")
+	}
+	row("Lawn service", fmt.Sprintf("%s - %s", service.Code, service.Description))
+	row("Formulation", service.Formulation.Name)
+	row("Technician", cert.Technician)
+	row("Equipment", orDash(log.Equipment))
+	row("Started", log.CreatedAt.UTC().Format("2006-01-02 15:04 MST"))
+	row("Completed", cert.CompletedAt.UTC().Format("2006-01-02 15:04 MST"))
+	row("Issued", cert.IssuedAt.UTC().Format("2006-01-02 15:04 MST"))
+	pdf.Ln(4)
+
+	// Records table
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.SetFillColor(230, 230, 230)
+	headers := []string{"#", "Recorded", "Amount", "Area", "Calibration"}
+	widths := []float64{10, 55, 40, 35, 55}
+	for i, h := range headers {
+		pdf.CellFormat(widths[i], 7, h, "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.SetFont("Helvetica", "", 10)
+	for i, r := range log.Records {
+		pdf.CellFormat(widths[0], 7, fmt.Sprintf("%d", i+1), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(widths[1], 7, r.CreatedAt.UTC().Format("2006-01-02 15:04:05"), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(widths[2], 7, fmt.Sprintf("%.3f %s", r.MeasurementValue, r.MeasurementUnit), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[3], 7, fmt.Sprintf("%d", r.MeasurementArea), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[4], 7, fmt.Sprintf("%.4f", r.Calibration), "1", 0, "R", false, 0, "")
+		pdf.Ln(-1)
+	}
+	pdf.Ln(6)
+
+	// Result
+	final := *log.CurrentCalibration
+	target := float64(service.TargetCalibrationValue)
+	row("Final calibration", fmt.Sprintf("%.4f %s", final, service.TargetCalibrationUnit))
+	row("Target calibration", fmt.Sprintf("%.4f %s", target, service.TargetCalibrationUnit))
+	if target != 0 {
+		row("Deviation", fmt.Sprintf("%+.2f%%", (final-target)/target*100))
+	}
+	pdf.Ln(10)
+
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.CellFormat(0, 5, "Verification hash (SHA-256)", "", 1, "L", false, 0, "")
+	pdf.SetFont("Courier", "", 9)
+	pdf.CellFormat(0, 5, cert.Hash, "", 1, "L", false, 0, "")
+
+	return pdf.Output(w)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -1,6 +1,7 @@
 package calibration
 
 import (
+	"bytes"
 	"errors"
 	"net/http"
 	"qc_api/internal/utils"
@@ -18,13 +19,28 @@ import (
 // @Tags calibration
 // @Accept json
 // @Produce json
-// @Success 200 {array} Formulation
+// @Param name query string false "Filter by name"
+// @Param limit query int false "Page size (default 50, at most 200)"
+// @Param offset query int false "Rows to skip"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort keys, - for descending (e.g. -created_at)"
+// @Param fields query string false "Comma separated fields to return (e.g. id,name)"
+// @Success 200 {object} utils.Page[Formulation]
+// @Failure 400 {object} utils.ErrorResponse
 // @Failure 500 {object} utils.ErrorResponse
 // @Security BearerAuth
 // @Router /formulations [get]
 func (s *CalibrationService) GetFormulationsHandler(c echo.Context) error {
-	formulations, err := s.ReadFormulations()
+	var filter FormulationFilter
+	if err := c.Bind(&filter); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+
+	formulations, err := s.ReadFormulations(filter)
 	if err != nil {
+		if errors.Is(err, utils.ErrInvalidQuery) {
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+		}
 		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
 	}
 	return c.JSON(http.StatusOK, formulations)
@@ -65,17 +81,33 @@ func (s *CalibrationService) PostFormulationHandler(c echo.Context) error {
 
 // GetLawnServicesHandler godoc
 // @Summary Get all lawn services
-// @Description Retrieve all lawn service configurations
+// @Description Retrieve lawn service configurations, by default ordered by code, with optional filtering
 // @Tags calibration
 // @Accept json
 // @Produce json
-// @Success 200 {array} LawnService
+// @Param code query string false "Filter by code"
+// @Param formulation_id query string false "Filter by formulation ID (UUID)"
+// @Param limit query int false "Page size (default 50, at most 200)"
+// @Param offset query int false "Rows to skip"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort keys, - for descending (e.g. description,-created_at)"
+// @Param fields query string false "Comma separated fields to return (e.g. id,code,description)"
+// @Success 200 {object} utils.Page[LawnService]
+// @Failure 400 {object} utils.ErrorResponse
 // @Failure 500 {object} utils.ErrorResponse
 // @Security BearerAuth
 // @Router /lawnservices [get]
 func (s *CalibrationService) GetLawnServicesHandler(c echo.Context) error {
-	services, err := s.ReadLawnServices()
+	var filter LawnServiceFilter
+	if err := c.Bind(&filter); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+
+	services, err := s.ReadLawnServices(filter)
 	if err != nil {
+		if errors.Is(err, utils.ErrInvalidQuery) {
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+		}
 		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
 	}
 	return c.JSON(http.StatusOK, services)
@@ -131,7 +163,15 @@ func (s *CalibrationService) PostLawnServiceHandler(c echo.Context) error {
 // @Param lawn_service_id query string false "Filter by lawn service ID (UUID)"
 // @Param date_from query string false "Filter by date from (YYYY-MM-DD)"
 // @Param date_to query string false "Filter by date to (YYYY-MM-DD)"
-// @Success 200 {array} CalibrationLog
+// @Param lawn_service.code query string false "Filter by lawn service code"
+// @Param lawn_service.formulation.name query string false "Filter by the lawn service's formulation name (e.g. GRANULE)"
+// @Param limit query int false "Page size (default 50, at most 200)"
+// @Param offset query int false "Rows to skip"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort keys, - for descending (e.g. -created_at,equipment)"
+// @Param fields query string false "Comma separated fields to return (e.g. id,current_calibration)"
+// @Success 200 {object} utils.Page[CalibrationLog]
+// @Failure 400 {object} utils.ErrorResponse
 // @Failure 500 {object} utils.ErrorResponse
 // @Security BearerAuth
 // @Router /calibrationlogs [get]
@@ -143,6 +183,9 @@ func (s *CalibrationService) GetCalibrationLogsHandler(c echo.Context) error {
 
 	logs, err := s.ReadCalibrationLogs(filter)
 	if err != nil {
+		if errors.Is(err, utils.ErrInvalidQuery) {
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+		}
 		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
 	}
 	return c.JSON(http.StatusOK, logs)
@@ -176,6 +219,45 @@ func (s *CalibrationService) GetCalibrationLogHandler(c echo.Context) error {
 	return c.JSON(http.StatusOK, log)
 }
 
+// GetCalibrationCertificateHandler godoc
+// @Summary Get calibration certificate
+// @Description Render a completed calibration log as a PDF certificate with a verification hash
+// @Tags calibration
+// @Produce application/pdf
+// @Param id path string true "Calibration Log ID"
+// @Success 200 {file} file
+// @Header 200 {string} X-Certificate-Hash "SHA-256 verification hash"
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /calibrationlogs/{id}/certificate [get]
+func (s *CalibrationService) GetCalibrationCertificateHandler(c echo.Context) error {
+	log_id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "Invalid calibration_log id"})
+	}
+	cert, err := s.BuildCalibrationCertificate(log_id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: "Calibration log not found"})
+		}
+		if errors.Is(err, ErrCalibrationLogIncomplete) {
+			return c.JSON(http.StatusUnprocessableEntity, utils.ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+	}
+
+	var buf bytes.Buffer
+	if err := RenderCalibrationCertificate(cert, &buf); err != nil {
+		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+	}
+	c.Response().Header().Set("X-Certificate-Hash", cert.Hash)
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="calibration-`+log_id.String()+`.pdf"`)
+	return c.Blob(http.StatusOK, "application/pdf", buf.Bytes())
+}
+
 // PostCalibrationLogHandler godoc
 // @Summary Create a new calibration log
 // @Description Create a new calibration log entry
@@ -29,6 +29,21 @@ type FormulationPatch struct {
 	Name *string `json:"name,omitempty"`
 }
 
+type FormulationFilter struct {
+	Name *string `json:"name,omitempty" query:"name"`
+	utils.PageParams
+}
+
+// formulationSorting lists what formulations can be sorted by.
+var formulationSorting = utils.Sorting{
+	Columns: map[string]string{
+		"created_at": "created_at",
+		"updated_at": "updated_at",
+		"name":       "name",
+	},
+	Default: "name",
+}
+
 type LawnService struct {
 	db.BaseModel
 	Code                            string      `gorm:"unique;not null" json:"code" validate:"required"` //e.g. "LS01"
@@ -42,6 +57,23 @@ type LawnService struct {
 	DifferentialCalibrationFunction string      `json:"differential_calibration_function"`
 }
 
+type LawnServiceFilter struct {
+	Code          *string    `json:"code,omitempty" query:"code"`
+	FormulationID *uuid.UUID `json:"formulation_id,omitempty" query:"formulation_id"`
+	utils.PageParams
+}
+
+// lawnServiceSorting lists what lawn services can be sorted by.
+var lawnServiceSorting = utils.Sorting{
+	Columns: map[string]string{
+		"created_at":  "created_at",
+		"updated_at":  "updated_at",
+		"code":        "code",
+		"description": "description",
+	},
+	Default: "code",
+}
+
 type LawnServiceDTO struct {
 	Code                            string    `json:"code" validate:"required"`
 	Description                     string    `json:"description" validate:"required"`
@@ -69,17 +101,20 @@ type CalibrationLog struct {
 	UserID             uuid.UUID           `json:"user_id"`
 	LawnServiceID      uuid.UUID           `json:"lawn_service_id"`
 	LawnService        LawnService         `gorm:"foreignKey:LawnServiceID" json:"-"`
+	Equipment          string              `json:"equipment"` //e.g. spreader make/model or unit number
 	CurrentCalibration *float64            `gorm:"-" json:"current_calibration,omitempty"`
 	Records            []CalibrationRecord `json:"records"`
 }
 
 type CalibrationLogDTO struct {
 	LawnServiceID uuid.UUID `json:"lawn_service_id"`
+	Equipment     string    `json:"equipment"`
 }
 
 type CalibrationLogPatch struct {
 	UserID        *uuid.UUID `json:"user_id,omitempty"`
 	LawnServiceID *uuid.UUID `json:"lawn_service_id,omitempty"`
+	Equipment     *string    `json:"equipment,omitempty"`
 }
 
 type CalibrationLogFilter struct {
@@ -87,6 +122,23 @@ type CalibrationLogFilter struct {
 	LawnServiceID *uuid.UUID        `json:"lawn_service_id,omitempty" query:"lawn_service_id"`
 	DateFrom      *utils.SimpleDate `json:"date_from,omitempty" query:"date_from" filter:"created_at"`
 	DateTo        *utils.SimpleDate `json:"date_to,omitempty" query:"date_to" filter:"created_at"`
+
+	// Filters on the lawn service calibrated for
+	LawnServiceCode *string `json:"lawn_service.code,omitempty" query:"lawn_service.code" filter:"lawn_service.code"`
+	FormulationName *string `json:"lawn_service.formulation.name,omitempty" query:"lawn_service.formulation.name" filter:"lawn_service.formulation.name"`
+	utils.PageParams
+}
+
+// calibrationLogSorting lists what calibration logs can be sorted by.
+var calibrationLogSorting = utils.Sorting{
+	Columns: map[string]string{
+		"created_at":      "created_at",
+		"updated_at":      "updated_at",
+		"user_id":         "user_id",
+		"lawn_service_id": "lawn_service_id",
+		"equipment":       "equipment",
+	},
+	Default: "created_at",
 }
 
 type CalibrationRecord struct {
@@ -12,6 +12,7 @@ func RegisterRoutes(g *echo.Group, calibrationService *CalibrationService) {
 	g.POST("/calibrationlogs", calibrationService.PostCalibrationLogHandler)
 	g.GET("/calibrationlogs", calibrationService.GetCalibrationLogsHandler)
 	g.GET("/calibrationlogs/:id", calibrationService.GetCalibrationLogHandler)
+	g.GET("/calibrationlogs/:id/certificate", calibrationService.GetCalibrationCertificateHandler)
 	g.DELETE("/calibrationlogs/:id", calibrationService.DeleteCalibrationLogHandler)
 	g.PATCH("/calibrationlogs/:id", calibrationService.PatchCalibrationLogHandler)
 	g.POST("/calibrationlogs/:id/records", calibrationService.PostCalibrationRecordHandler)
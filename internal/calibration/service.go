@@ -18,10 +18,9 @@ func NewCalibrationService(db *gorm.DB) *CalibrationService {
 }
 
 // === Formulations ===
-func (s *CalibrationService) ReadFormulations() ([]Formulation, error) {
-	var formulations []Formulation
-	result := s.DB.Find(&formulations)
-	return formulations, result.Error
+func (s *CalibrationService) ReadFormulations(filter FormulationFilter) (*utils.Page[Formulation], error) {
+	query := utils.ApplyFilter(s.DB.Model(&Formulation{}), filter)
+	return utils.Paginate[Formulation](query, filter.PageParams, formulationSorting)
 }
 
 func (s *CalibrationService) CreateFormulation(formulation *Formulation) error {
@@ -42,10 +41,11 @@ func (s *CalibrationService) UpdateFormulation(id uuid.UUID, patch FormulationPa
 }
 
 // === Lawn Services ===
-func (s *CalibrationService) ReadLawnServices() ([]LawnService, error) {
-	var services []LawnService
-	result := s.DB.Preload("Formulation").Find(&services)
-	return services, result.Error
+func (s *CalibrationService) ReadLawnServices(filter LawnServiceFilter) (*utils.Page[LawnService], error) {
+	query := utils.ApplyFilter(s.DB.Model(&LawnService{}), filter)
+	return utils.Paginate[LawnService](query, filter.PageParams, lawnServiceSorting, func(db *gorm.DB) *gorm.DB {
+		return db.Preload("Formulation")
+	})
 }
 
 func (s *CalibrationService) CreateLawnService(service *LawnService) error {
@@ -70,24 +70,25 @@ func (s *CalibrationService) UpdateLawnService(id uuid.UUID, patch LawnServicePa
 }
 
 // === Calibration Logs ===
-func (s *CalibrationService) ReadCalibrationLogs(filter CalibrationLogFilter) ([]CalibrationLog, error) {
-	var logs []CalibrationLog
+func (s *CalibrationService) ReadCalibrationLogs(filter CalibrationLogFilter) (*utils.Page[CalibrationLog], error) {
 	query := utils.ApplyFilter(s.DB.Model(&CalibrationLog{}), filter)
-	result := query.Preload("LawnService.Formulation").Preload("Records", func(db *gorm.DB) *gorm.DB {
-		return db.Order("created_at ASC")
-	}).Find(&logs)
-	if result.Error != nil {
-		return logs, result.Error
+	page, err := utils.Paginate[CalibrationLog](query, filter.PageParams, calibrationLogSorting, func(db *gorm.DB) *gorm.DB {
+		return db.Preload("LawnService.Formulation").Preload("Records", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at ASC")
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// Calculate calibration for each log
-	for i := range logs {
-		s.calculateCalibrationForLog(&logs[i])
+	for i := range page.Data {
+		s.calculateCalibrationForLog(&page.Data[i])
 		// Calculate calibrations for individual records
-		s.calculateCalibrationForRecords(&logs[i])
+		s.calculateCalibrationForRecords(&page.Data[i])
 	}
 
-	return logs, nil
+	return page, nil
 }
 
 func evaluateCalibrationFunction(expression string, parameters map[string]any) (float64, error) {
@@ -190,6 +191,7 @@ func (s *CalibrationService) CreateCalibrationLog(log *CalibrationLogDTO, userID
 	calibrationLog := &CalibrationLog{
 		LawnServiceID: log.LawnServiceID,
 		UserID:        userID,
+		Equipment:     log.Equipment,
 	}
 	result := s.DB.Create(calibrationLog)
 	if result.Error != nil {
@@ -6,13 +6,42 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Config holds the application configuration.
 type Config struct {
-	JWTSecret   []byte
-	MotiveKey   string
-	AuthTimeout int
+	JWTSecret         []byte
+	MotiveKey         string
+	AuthTimeout       int
+	MaxAttachmentSize int64
+
+	// Storage for uploads, transcripts, reports and attachments
+	StorageBackend string // "local" or "s3"
+	StorageDir     string
+	S3Endpoint     string
+	S3Bucket       string
+	S3Region       string
+	S3AccessKey    string
+	S3SecretKey    string
+
+	// Transcription of uploaded recordings
+	Transcriber       string // "command", "whisper" or "fake"
+	TranscribeCommand []string
+	WhisperURL        string
+	TranscribeTimeout time.Duration
+
+	// Report generation
+	LLMProvider   string // "ollama" or "openai"
+	LLMURL        string // empty uses OLLAMA_HOST for Ollama
+	LLMAPIKey     string
+	LLMModel      string // used by prompt templates that do not name a model
+	ReportTimeout time.Duration
+
+	// Background jobs
+	JobConcurrency  map[string]int // workers per job type
+	JobDrainTimeout time.Duration  // how long shutdown waits for running jobs
 }
 
 // NewConfig creates and returns a new configuration object.
@@ -41,10 +70,87 @@ func NewConfig() *Config {
 		log.Println("!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!")
 	}
 
+	storageBackend := os.Getenv("STORAGE_BACKEND")
+	if storageBackend == "" {
+		storageBackend = "local"
+	}
+	storageDir := os.Getenv("STORAGE_DIR")
+	if storageDir == "" {
+		storageDir = "data/storage"
+	}
+	s3Region := os.Getenv("S3_REGION")
+	if s3Region == "" {
+		s3Region = "us-east-1"
+	}
+
+	maxAttachmentSize, err := strconv.ParseInt(os.Getenv("MAX_ATTACHMENT_SIZE"), 10, 64)
+	if err != nil || maxAttachmentSize <= 0 {
+		maxAttachmentSize = 10 << 20
+	}
+
+	transcriber := os.Getenv("TRANSCRIBER")
+	if transcriber == "" {
+		transcriber = "whisper"
+	}
+	whisperURL := os.Getenv("WHISPER_URL")
+	if whisperURL == "" {
+		whisperURL = "http://localhost:8080"
+	}
+	transcribeTimeout, err := time.ParseDuration(os.Getenv("TRANSCRIBE_TIMEOUT"))
+	if err != nil || transcribeTimeout <= 0 {
+		transcribeTimeout = 30 * time.Minute
+	}
+
+	llmProvider := os.Getenv("LLM_PROVIDER")
+	if llmProvider == "" {
+		llmProvider = "ollama"
+	}
+	reportTimeout, err := time.ParseDuration(os.Getenv("REPORT_TIMEOUT"))
+	if err != nil || reportTimeout <= 0 {
+		reportTimeout = 10 * time.Minute
+	}
+
+	// e.g. JOB_CONCURRENCY="Transcription=1,ReportGeneration=2"
+	jobConcurrency := map[string]int{}
+	for entry := range strings.SplitSeq(os.Getenv("JOB_CONCURRENCY"), ",") {
+		jobType, count, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(count); err == nil && n > 0 {
+			jobConcurrency[jobType] = n
+		} else {
+			log.Printf("Ignoring invalid JOB_CONCURRENCY entry %q", entry)
+		}
+	}
+	jobDrainTimeout, err := time.ParseDuration(os.Getenv("JOB_DRAIN_TIMEOUT"))
+	if err != nil || jobDrainTimeout <= 0 {
+		jobDrainTimeout = 30 * time.Second
+	}
+
 	return &Config{
-		JWTSecret:   []byte(jwtSecret),
-		MotiveKey:   motiveKey,
-		AuthTimeout: authTokenTimeout,
+		JWTSecret:         []byte(jwtSecret),
+		MotiveKey:         motiveKey,
+		AuthTimeout:       authTokenTimeout,
+		MaxAttachmentSize: maxAttachmentSize,
+		StorageBackend:    storageBackend,
+		StorageDir:        storageDir,
+		S3Endpoint:        os.Getenv("S3_ENDPOINT"),
+		S3Bucket:          os.Getenv("S3_BUCKET"),
+		S3Region:          s3Region,
+		S3AccessKey:       os.Getenv("S3_ACCESS_KEY_ID"),
+		S3SecretKey:       os.Getenv("S3_SECRET_ACCESS_KEY"),
+		Transcriber:       transcriber,
+		TranscribeCommand: strings.Fields(os.Getenv("TRANSCRIBE_COMMAND")),
+		WhisperURL:        whisperURL,
+		TranscribeTimeout: transcribeTimeout,
+		LLMProvider:       llmProvider,
+		LLMURL:            os.Getenv("LLM_URL"),
+		LLMAPIKey:         os.Getenv("LLM_API_KEY"),
+		LLMModel:          os.Getenv("LLM_MODEL"),
+		ReportTimeout:     reportTimeout,
+		JobConcurrency:    jobConcurrency,
+		JobDrainTimeout:   jobDrainTimeout,
 	}
 }
 
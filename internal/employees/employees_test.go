@@ -263,7 +263,7 @@ func TestGetEmployees(t *testing.T) {
 		result, err := service.GetEmployees(filter)
 
 		assert.NoError(t, err)
-		assert.Len(t, result, 3)
+		assert.Len(t, result.Data, 3)
 	})
 
 	t.Run("Filter by active status - true", func(t *testing.T) {
@@ -272,8 +272,8 @@ func TestGetEmployees(t *testing.T) {
 		result, err := service.GetEmployees(filter)
 
 		assert.NoError(t, err)
-		assert.Len(t, result, 3)
-		for _, emp := range result {
+		assert.Len(t, result.Data, 3)
+		for _, emp := range result.Data {
 			assert.True(t, emp.Active)
 		}
 	})
@@ -284,8 +284,8 @@ func TestGetEmployees(t *testing.T) {
 		result, err := service.GetEmployees(filter)
 
 		assert.NoError(t, err)
-		assert.Len(t, result, 1)
-		assert.Equal(t, "EMP001", result[0].EmployeeNumber)
+		assert.Len(t, result.Data, 1)
+		assert.Equal(t, "EMP001", result.Data[0].EmployeeNumber)
 	})
 }
 
@@ -423,10 +423,11 @@ func TestGetEmployeesHandler(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, http.StatusOK, rec.Code)
 
-		var response []employees.Employee
+		var response utils.Page[employees.Employee]
 		err = json.Unmarshal(rec.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Len(t, response, 3)
+		assert.Len(t, response.Data, 3)
+		assert.Equal(t, int64(3), response.Total)
 	})
 
 	t.Run("Filter by employee number", func(t *testing.T) {
@@ -437,14 +438,43 @@ func TestGetEmployeesHandler(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, http.StatusOK, rec.Code)
 
-		var response []employees.Employee
+		var response utils.Page[employees.Employee]
 		err = json.Unmarshal(rec.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Len(t, response, 1)
-		if len(response) > 0 {
-			assert.Equal(t, "EMP001", response[0].EmployeeNumber)
+		assert.Len(t, response.Data, 1)
+		if len(response.Data) > 0 {
+			assert.Equal(t, "EMP001", response.Data[0].EmployeeNumber)
 		}
 	})
+
+	t.Run("Paged, sorted and trimmed to fields", func(t *testing.T) {
+		c, rec := setupEchoContext(http.MethodGet, "/employees?limit=2&sort=-employee_number&fields=employee_number", "")
+
+		err := service.GetEmployeesHandler(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response struct {
+			Data       []map[string]any `json:"data"`
+			Total      int64            `json:"total"`
+			NextCursor string           `json:"next_cursor"`
+		}
+		err = json.Unmarshal(rec.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), response.Total)
+		assert.Equal(t, []map[string]any{{"employee_number": "EMP003"}, {"employee_number": "EMP002"}}, response.Data)
+		assert.NotEmpty(t, response.NextCursor)
+	})
+
+	t.Run("Unknown sort key", func(t *testing.T) {
+		c, rec := setupEchoContext(http.MethodGet, "/employees?sort=password", "")
+
+		err := service.GetEmployeesHandler(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
 }
 
 func TestGetEmployeeByIDHandler(t *testing.T) {
@@ -602,10 +632,10 @@ func TestEmployeeWorkflow(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, http.StatusOK, rec.Code)
 
-		var allEmployees []employees.Employee
+		var allEmployees utils.Page[employees.Employee]
 		err = json.Unmarshal(rec.Body.Bytes(), &allEmployees)
 		assert.NoError(t, err)
-		assert.GreaterOrEqual(t, len(allEmployees), 1)
+		assert.GreaterOrEqual(t, len(allEmployees.Data), 1)
 	})
 }
 
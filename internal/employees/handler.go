@@ -1,6 +1,7 @@
 package employees
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"qc_api/internal/utils"
@@ -50,7 +51,13 @@ func (s *EmployeeService) PostEmployeeHandler(c echo.Context) error {
 // @Produce json
 // @Param active query bool false "Filter by active status"
 // @Param employee_number query string false "Filter by employee number"
-// @Success 200 {array} Employee
+// @Param limit query int false "Page size (default 50, at most 200)"
+// @Param offset query int false "Rows to skip"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort keys, - for descending (e.g. -created_at,last_name)"
+// @Param fields query string false "Comma separated fields to return (e.g. id,first_name)"
+// @Success 200 {object} utils.Page[Employee]
+// @Failure 400 {object} utils.ErrorResponse
 // @Failure 500 {object} utils.ErrorResponse
 // @Security BearerAuth
 // @Router /employees [get]
@@ -62,6 +69,9 @@ func (s *EmployeeService) GetEmployeesHandler(c echo.Context) error {
 
 	employees, err := s.GetEmployees(filter)
 	if err != nil {
+		if errors.Is(err, utils.ErrInvalidQuery) {
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+		}
 		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: "failed to retrieve employees"})
 	}
 	return c.JSON(http.StatusOK, employees)
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"qc_api/internal/db"
 	"qc_api/internal/inspections"
+	"qc_api/internal/utils"
 )
 
 func Models() []any {
@@ -41,6 +42,21 @@ type EmployeePatch struct {
 type EmployeeFilter struct {
 	Active         *bool   `json:"active,omitempty" query:"active"`
 	EmployeeNumber *string `json:"employee_number,omitempty" query:"employee_number"`
+	utils.PageParams
+}
+
+// employeeSorting lists what employees can be sorted by.
+var employeeSorting = utils.Sorting{
+	Columns: map[string]string{
+		"created_at":      "created_at",
+		"updated_at":      "updated_at",
+		"common_name":     "common_name",
+		"first_name":      "first_name",
+		"last_name":       "last_name",
+		"employee_number": "employee_number",
+		"active":          "active",
+	},
+	Default: "created_at",
 }
 
 func NewEmployee(CommonName, FirstName, LastName, EmployeeNumber string) (*Employee, error) {
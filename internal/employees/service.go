@@ -19,10 +19,9 @@ func (s *EmployeeService) CreateEmployee(employee *Employee) error {
 	return s.DB.Create(employee).Error
 }
 
-func (s *EmployeeService) GetEmployees(filter EmployeeFilter) ([]Employee, error) {
-	var employees []Employee
+func (s *EmployeeService) GetEmployees(filter EmployeeFilter) (*utils.Page[Employee], error) {
 	query := utils.ApplyFilter(s.DB.Model(&Employee{}), filter)
-	return employees, query.Preload("Inspections").Find(&employees).Error
+	return utils.Paginate[Employee](query, filter.PageParams, employeeSorting)
 }
 
 func (s *EmployeeService) GetEmployeeByID(id string) (Employee, error) {
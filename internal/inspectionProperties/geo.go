@@ -0,0 +1,139 @@
+package inspectionproperties
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"qc_api/internal/utils"
+)
+
+var ErrInvalidGeoQuery = errors.New("invalid geospatial query")
+
+const (
+	earthRadiusMeters = 6371008.8
+	metersPerDegree   = earthRadiusMeters * math.Pi / 180
+
+	DefaultCellSize = 0.01 //degrees, roughly 1km north-south
+	MaxCellSize     = 10
+	MaxRadius       = 500000 //meters
+)
+
+// haversineMeters returns the great-circle distance between two points.
+func haversineMeters(lat1, lng1, lat2, lng2 float64) float64 {
+	rlat1, rlat2 := lat1*math.Pi/180, lat2*math.Pi/180
+	dLat := rlat2 - rlat1
+	dLng := (lng2 - lng1) * math.Pi / 180
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(rlat1)*math.Cos(rlat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+func validLatitude(v *float64) bool  { return v == nil || (*v >= -90 && *v <= 90) }
+func validLongitude(v *float64) bool { return v == nil || (*v >= -180 && *v <= 180) }
+
+func (f InspectionPropertyFilter) validate() error {
+	if !validLatitude(f.MinLatitude) || !validLatitude(f.MaxLatitude) || !validLatitude(f.Latitude) {
+		return fmt.Errorf("%w: latitude must be between -90 and 90", ErrInvalidGeoQuery)
+	}
+	if !validLongitude(f.MinLongitude) || !validLongitude(f.MaxLongitude) || !validLongitude(f.Longitude) {
+		return fmt.Errorf("%w: longitude must be between -180 and 180", ErrInvalidGeoQuery)
+	}
+	radiusSet := 0
+	for _, v := range []*float64{f.Latitude, f.Longitude, f.Radius} {
+		if v != nil {
+			radiusSet++
+		}
+	}
+	if radiusSet != 0 && radiusSet != 3 {
+		return fmt.Errorf("%w: lat, lng and radius_m must be given together", ErrInvalidGeoQuery)
+	}
+	if f.Radius != nil && (*f.Radius <= 0 || *f.Radius > MaxRadius) {
+		return fmt.Errorf("%w: radius_m must be greater than 0 and at most 500000", ErrInvalidGeoQuery)
+	}
+	if f.PageParams != (utils.PageParams{}) {
+		return fmt.Errorf("%w: searches are not paged", ErrInvalidGeoQuery)
+	}
+	return nil
+}
+
+// radiusBounds returns a bounding box enclosing the search circle, used to
+// narrow the query before exact distances are computed.
+func (f InspectionPropertyFilter) radiusBounds() (minLat, maxLat, minLng, maxLng float64) {
+	dLat := *f.Radius / metersPerDegree
+	minLat, maxLat = math.Max(-90, *f.Latitude-dLat), math.Min(90, *f.Latitude+dLat)
+	minLng, maxLng = -180, 180
+	if cos := math.Cos(*f.Latitude * math.Pi / 180); maxLat < 90 && minLat > -90 && cos > 0 {
+		dLng := *f.Radius / (metersPerDegree * cos)
+		minLng, maxLng = math.Max(-180, *f.Longitude-dLng), math.Min(180, *f.Longitude+dLng)
+	}
+	return
+}
+
+// === GeoJSON ===
+
+type GeoJSONGeometry struct {
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"`
+}
+
+type GeoJSONFeature struct {
+	Type       string          `json:"type"`
+	ID         string          `json:"id,omitempty"`
+	Geometry   GeoJSONGeometry `json:"geometry"`
+	Properties map[string]any  `json:"properties"`
+}
+
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+}
+
+// PropertiesGeoJSON renders properties as Point features. GeoJSON positions
+// are [longitude, latitude].
+func PropertiesGeoJSON(properties []InspectionProperty) GeoJSONFeatureCollection {
+	collection := GeoJSONFeatureCollection{Type: "FeatureCollection", Features: []GeoJSONFeature{}}
+	for _, p := range properties {
+		props := map[string]any{
+			"inspection_id":          p.InspectionID,
+			"address":                p.Address,
+			"customer_engagement":    p.CustomerEngagement,
+			"signs_placed_correctly": p.SignsPlacedCorrectly,
+			"application_coverage":   p.ApplicationCoverage,
+			"created_at":             p.CreatedAt,
+		}
+		if p.Distance != nil {
+			props["distance_m"] = *p.Distance
+		}
+		collection.Features = append(collection.Features, GeoJSONFeature{
+			Type:       "Feature",
+			ID:         p.ID.String(),
+			Geometry:   GeoJSONGeometry{Type: "Point", Coordinates: [2]float64{p.Longitude, p.Latitude}},
+			Properties: props,
+		})
+	}
+	return collection
+}
+
+// CoverageGeoJSON renders grid cells as Polygon features.
+func CoverageGeoJSON(cells []CoverageCell) GeoJSONFeatureCollection {
+	collection := GeoJSONFeatureCollection{Type: "FeatureCollection", Features: []GeoJSONFeature{}}
+	for _, cell := range cells {
+		ring := [][2]float64{
+			{cell.MinLongitude, cell.MinLatitude},
+			{cell.MaxLongitude, cell.MinLatitude},
+			{cell.MaxLongitude, cell.MaxLatitude},
+			{cell.MinLongitude, cell.MaxLatitude},
+			{cell.MinLongitude, cell.MinLatitude},
+		}
+		collection.Features = append(collection.Features, GeoJSONFeature{
+			Type:     "Feature",
+			Geometry: GeoJSONGeometry{Type: "Polygon", Coordinates: [][][2]float64{ring}},
+			Properties: map[string]any{
+				"properties":          cell.Properties,
+				"average_coverage":    cell.AverageCoverage,
+				"sign_compliance":     cell.SignCompliance,
+				"customer_engagement": cell.CustomerEngagement,
+			},
+		})
+	}
+	return collection
+}
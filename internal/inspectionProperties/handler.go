@@ -1 +1,301 @@
 package inspectionproperties
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"qc_api/internal/utils"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+func propertyErrorResponse(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: "inspection or property not found"})
+	case errors.Is(err, ErrInspectionLocked):
+		return c.JSON(http.StatusConflict, utils.ErrorResponse{Error: err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+}
+
+// parseIDs reads the inspection and property IDs from a
+// /inspections/:inspectionId/properties/:id route.
+func parseIDs(c echo.Context) (uuid.UUID, uuid.UUID, error) {
+	inspectionID, err := uuid.Parse(c.Param("inspectionId"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, errors.New("invalid inspection id")
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, errors.New("invalid property id")
+	}
+	return inspectionID, id, nil
+}
+
+// GetPropertiesHandler godoc
+// @Summary Get properties for an inspection
+// @Description Retrieve the properties visited during an inspection, by default in the order they were recorded
+// @Tags inspections
+// @Accept json
+// @Produce json
+// @Param id path string true "Inspection ID"
+// @Param limit query int false "Page size (default 50, at most 200)"
+// @Param offset query int false "Rows to skip"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort keys, - for descending (e.g. -application_coverage,address)"
+// @Param fields query string false "Comma separated fields to return (e.g. id,address)"
+// @Success 200 {object} utils.Page[InspectionProperty]
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /inspections/{id}/properties [get]
+func (s *PropertyService) GetPropertiesHandler(c echo.Context) error {
+	inspectionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid inspection id"})
+	}
+	var params utils.PageParams
+	if err := c.Bind(&params); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	properties, err := s.ReadProperties(InspectionPropertyFilter{InspectionID: &inspectionID, PageParams: params})
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidQuery) {
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+	}
+	return c.JSON(http.StatusOK, properties)
+}
+
+// GetPropertyHandler godoc
+// @Summary Get an inspection property
+// @Description Retrieve a single property of an inspection by its ID
+// @Tags inspections
+// @Accept json
+// @Produce json
+// @Param inspectionId path string true "Inspection ID"
+// @Param id path string true "Property ID"
+// @Success 200 {object} InspectionProperty
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /inspections/{inspectionId}/properties/{id} [get]
+func (s *PropertyService) GetPropertyHandler(c echo.Context) error {
+	inspectionID, id, err := parseIDs(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	property, err := s.ReadProperty(inspectionID, id)
+	if err != nil {
+		return propertyErrorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, property)
+}
+
+// PostPropertyHandler godoc
+// @Summary Add a property to an inspection
+// @Description Record a property visited during an inspection. Latitude must be within -90..90, longitude within -180..180 and coverage within 0..100 percent.
+// @Tags inspections
+// @Accept json
+// @Produce json
+// @Param id path string true "Inspection ID"
+// @Param property body InspectionPropertyDTO true "Property data"
+// @Success 201 {object} InspectionProperty
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /inspections/{id}/properties [post]
+func (s *PropertyService) PostPropertyHandler(c echo.Context) error {
+	inspectionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid inspection id"})
+	}
+	var propertyDTO InspectionPropertyDTO
+	if err := c.Bind(&propertyDTO); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "Invalid request body"})
+	}
+	if err := c.Validate(&propertyDTO); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+
+	property := &InspectionProperty{
+		InspectionID:         inspectionID,
+		Address:              propertyDTO.Address,
+		Latitude:             *propertyDTO.Latitude,
+		Longitude:            *propertyDTO.Longitude,
+		CustomerEngagement:   propertyDTO.CustomerEngagement,
+		SignsPlacedCorrectly: propertyDTO.SignsPlacedCorrectly,
+		ApplicationCoverage:  *propertyDTO.ApplicationCoverage,
+	}
+
+	if err := s.CreateProperty(property); err != nil {
+		return propertyErrorResponse(c, err)
+	}
+	return c.JSON(http.StatusCreated, property)
+}
+
+// PatchPropertyHandler godoc
+// @Summary Update an inspection property
+// @Description Update specific fields of a property recorded on an inspection
+// @Tags inspections
+// @Accept json
+// @Produce json
+// @Param inspectionId path string true "Inspection ID"
+// @Param id path string true "Property ID"
+// @Param property body InspectionPropertyPatch true "Property update data"
+// @Success 200 {object} InspectionProperty
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /inspections/{inspectionId}/properties/{id} [patch]
+func (s *PropertyService) PatchPropertyHandler(c echo.Context) error {
+	inspectionID, id, err := parseIDs(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	var patch InspectionPropertyPatch
+	if err := c.Bind(&patch); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	if err := c.Validate(&patch); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	property, err := s.UpdateProperty(inspectionID, id, patch)
+	if err != nil {
+		return propertyErrorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, property)
+}
+
+// DeletePropertyHandler godoc
+// @Summary Delete an inspection property
+// @Description Remove a property from an inspection
+// @Tags inspections
+// @Param inspectionId path string true "Inspection ID"
+// @Param id path string true "Property ID"
+// @Success 204
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /inspections/{inspectionId}/properties/{id} [delete]
+func (s *PropertyService) DeletePropertyHandler(c echo.Context) error {
+	inspectionID, id, err := parseIDs(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	if err := s.DeleteProperty(inspectionID, id); err != nil {
+		return propertyErrorResponse(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// === Geospatial ===
+
+const geoJSONMediaType = "application/geo+json"
+
+func wantsGeoJSON(c echo.Context) bool {
+	return c.QueryParam("format") == "geojson" || strings.Contains(c.Request().Header.Get(echo.HeaderAccept), geoJSONMediaType)
+}
+
+func geoJSONResponse(c echo.Context, collection GeoJSONFeatureCollection) error {
+	body, err := json.Marshal(collection)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+	}
+	return c.Blob(http.StatusOK, geoJSONMediaType, body)
+}
+
+// SearchPropertiesHandler godoc
+// @Summary Search inspected properties by location
+// @Description Find properties inside a bounding box and/or within radius_m meters of lat/lng. Radius results are sorted nearest first. Use format=geojson (or Accept: application/geo+json) for a GeoJSON FeatureCollection.
+// @Tags inspections
+// @Accept json
+// @Produce json
+// @Param inspection_id query string false "Filter by inspection ID (UUID)"
+// @Param min_lat query number false "Bounding box south edge"
+// @Param max_lat query number false "Bounding box north edge"
+// @Param min_lng query number false "Bounding box west edge"
+// @Param max_lng query number false "Bounding box east edge"
+// @Param lat query number false "Radius search center latitude"
+// @Param lng query number false "Radius search center longitude"
+// @Param radius_m query number false "Radius search distance in meters"
+// @Param format query string false "Response format: json (default) or geojson"
+// @Success 200 {array} InspectionProperty
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /properties [get]
+func (s *PropertyService) SearchPropertiesHandler(c echo.Context) error {
+	var filter InspectionPropertyFilter
+	if err := c.Bind(&filter); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	properties, err := s.SearchProperties(filter)
+	if err != nil {
+		if errors.Is(err, ErrInvalidGeoQuery) {
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: "failed to retrieve properties"})
+	}
+	if wantsGeoJSON(c) {
+		return geoJSONResponse(c, PropertiesGeoJSON(properties))
+	}
+	return c.JSON(http.StatusOK, properties)
+}
+
+// GetCoverageGridHandler godoc
+// @Summary Aggregate property compliance by grid cell
+// @Description Group properties into square cells of cell_size degrees and report average application coverage, sign placement compliance and customer engagement per cell, lowest coverage first. Use format=geojson for cell polygons.
+// @Tags inspections
+// @Accept json
+// @Produce json
+// @Param inspection_id query string false "Filter by inspection ID (UUID)"
+// @Param min_lat query number false "Bounding box south edge"
+// @Param max_lat query number false "Bounding box north edge"
+// @Param min_lng query number false "Bounding box west edge"
+// @Param max_lng query number false "Bounding box east edge"
+// @Param cell_size query number false "Cell size in degrees (default 0.01)"
+// @Param format query string false "Response format: json (default) or geojson"
+// @Success 200 {array} CoverageCell
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /properties/coverage [get]
+func (s *PropertyService) GetCoverageGridHandler(c echo.Context) error {
+	var filter InspectionPropertyFilter
+	if err := c.Bind(&filter); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	cellSize := DefaultCellSize
+	if param := c.QueryParam("cell_size"); param != "" {
+		size, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid cell_size"})
+		}
+		cellSize = size
+	}
+	cells, err := s.CoverageGrid(filter, cellSize)
+	if err != nil {
+		if errors.Is(err, ErrInvalidGeoQuery) {
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: "failed to aggregate coverage"})
+	}
+	if wantsGeoJSON(c) {
+		return geoJSONResponse(c, CoverageGeoJSON(cells))
+	}
+	return c.JSON(http.StatusOK, cells)
+}
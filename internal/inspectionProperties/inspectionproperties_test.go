@@ -0,0 +1,310 @@
+package inspectionproperties_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	inspectionproperties "qc_api/internal/inspectionProperties"
+	"qc_api/internal/inspections"
+	"qc_api/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB() *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		panic("failed to connect database")
+	}
+	models := append(inspections.Models(), inspectionproperties.Models()...)
+	if err := db.AutoMigrate(models...); err != nil {
+		panic("failed to migrate database")
+	}
+	if err := inspections.Migrate(db); err != nil {
+		panic("failed to run inspection migrations: " + err.Error())
+	}
+	return db
+}
+
+func newContext(method, url, body string, params ...string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	e.Validator = utils.NewValidator()
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	var names, values []string
+	for i := 0; i+1 < len(params); i += 2 {
+		names = append(names, params[i])
+		values = append(values, params[i+1])
+	}
+	c.SetParamNames(names...)
+	c.SetParamValues(values...)
+	return c, rec
+}
+
+func createInspection(t *testing.T, db *gorm.DB) *inspections.Inspection {
+	inspection, err := inspections.NewInspectionService(db).CreateInspection(&inspections.Inspection{Report: "ok", EmployeeID: uuid.New()})
+	require.NoError(t, err)
+	return inspection
+}
+
+func TestPostProperty(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	service := inspectionproperties.NewPropertyService(db)
+	inspection := createInspection(t, db)
+
+	tests := []struct {
+		name         string
+		inspectionID string
+		body         string
+		expectedCode int
+	}{
+		{"valid", inspection.ID.String(), `{"address": "1 Main St", "latitude": 43.65, "longitude": -79.38, "application_coverage": 95}`, http.StatusCreated},
+		{"equator and zero coverage", inspection.ID.String(), `{"address": "2 Main St", "latitude": 0, "longitude": 0, "application_coverage": 0}`, http.StatusCreated},
+		{"latitude out of range", inspection.ID.String(), `{"address": "1 Main St", "latitude": 91, "longitude": -79.38, "application_coverage": 95}`, http.StatusBadRequest},
+		{"longitude out of range", inspection.ID.String(), `{"address": "1 Main St", "latitude": 43.65, "longitude": -181, "application_coverage": 95}`, http.StatusBadRequest},
+		{"coverage out of range", inspection.ID.String(), `{"address": "1 Main St", "latitude": 43.65, "longitude": -79.38, "application_coverage": 101}`, http.StatusBadRequest},
+		{"missing coordinates", inspection.ID.String(), `{"address": "1 Main St", "application_coverage": 95}`, http.StatusBadRequest},
+		{"unknown inspection", uuid.New().String(), `{"address": "1 Main St", "latitude": 43.65, "longitude": -79.38, "application_coverage": 95}`, http.StatusNotFound},
+		{"invalid inspection id", "nope", `{}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, rec := newContext(http.MethodPost, "/inspections/"+tt.inspectionID+"/properties", tt.body, "id", tt.inspectionID)
+
+			err := service.PostPropertyHandler(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedCode, rec.Code, rec.Body.String())
+		})
+	}
+}
+
+func TestPropertyCRUD(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	service := inspectionproperties.NewPropertyService(db)
+	inspection := createInspection(t, db)
+	other := createInspection(t, db)
+	property := &inspectionproperties.InspectionProperty{InspectionID: inspection.ID, Address: "1 Main St", Latitude: 43.65, Longitude: -79.38, ApplicationCoverage: 80}
+	require.NoError(t, service.CreateProperty(property))
+
+	// List
+	c, rec := newContext(http.MethodGet, "/inspections/"+inspection.ID.String()+"/properties", "", "id", inspection.ID.String())
+	require.NoError(t, service.GetPropertiesHandler(c))
+	var properties utils.Page[inspectionproperties.InspectionProperty]
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &properties))
+	assert.Len(t, properties.Data, 1)
+
+	c, rec = newContext(http.MethodGet, "/inspections/"+inspection.ID.String()+"/properties?sort=customer_engagement", "", "id", inspection.ID.String())
+	require.NoError(t, service.GetPropertiesHandler(c))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// Patch
+	c, rec = newContext(http.MethodPatch, "/", `{"application_coverage": 100, "signs_placed_correctly": true}`, "inspectionId", inspection.ID.String(), "id", property.ID.String())
+	require.NoError(t, service.PatchPropertyHandler(c))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var patched inspectionproperties.InspectionProperty
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &patched))
+	assert.Equal(t, int8(100), patched.ApplicationCoverage)
+	assert.True(t, patched.SignsPlacedCorrectly)
+
+	c, rec = newContext(http.MethodPatch, "/", `{"latitude": -95}`, "inspectionId", inspection.ID.String(), "id", property.ID.String())
+	require.NoError(t, service.PatchPropertyHandler(c))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// Properties are scoped to their inspection
+	c, rec = newContext(http.MethodGet, "/", "", "inspectionId", other.ID.String(), "id", property.ID.String())
+	require.NoError(t, service.GetPropertyHandler(c))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// Included in the inspection detail
+	detail, err := inspections.NewInspectionService(db).GetInspectionByID(inspection.ID)
+	require.NoError(t, err)
+	require.Len(t, detail.Properties, 1)
+	assert.Equal(t, "1 Main St", detail.Properties[0].Address)
+
+	// Delete
+	c, rec = newContext(http.MethodDelete, "/", "", "inspectionId", inspection.ID.String(), "id", property.ID.String())
+	require.NoError(t, service.DeletePropertyHandler(c))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	c, rec = newContext(http.MethodDelete, "/", "", "inspectionId", inspection.ID.String(), "id", property.ID.String())
+	require.NoError(t, service.DeletePropertyHandler(c))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestPropertiesLockedWithInspection(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	service := inspectionproperties.NewPropertyService(db)
+	inspection := createInspection(t, db)
+	require.NoError(t, db.Model(inspection).Update("status", inspections.StatusClosed).Error)
+
+	c, rec := newContext(http.MethodPost, "/", `{"address": "1 Main St", "latitude": 43.65, "longitude": -79.38, "application_coverage": 95}`, "id", inspection.ID.String())
+	require.NoError(t, service.PostPropertyHandler(c))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func seedProperties(t *testing.T, db *gorm.DB) (*inspectionproperties.PropertyService, *inspections.Inspection) {
+	service := inspectionproperties.NewPropertyService(db)
+	inspection := createInspection(t, db)
+	stops := []inspectionproperties.InspectionProperty{
+		// Two stops in the same 0.01 degree cell downtown, one a few km east, one in another city
+		{Address: "downtown a", Latitude: 43.6512, Longitude: -79.3832, ApplicationCoverage: 90, SignsPlacedCorrectly: true},
+		{Address: "downtown b", Latitude: 43.6518, Longitude: -79.3838, ApplicationCoverage: 50, SignsPlacedCorrectly: false},
+		{Address: "east", Latitude: 43.6705, Longitude: -79.3005, ApplicationCoverage: 100, SignsPlacedCorrectly: true, CustomerEngagement: true},
+		{Address: "ottawa", Latitude: 45.4215, Longitude: -75.6972, ApplicationCoverage: 20},
+	}
+	for _, stop := range stops {
+		stop.InspectionID = inspection.ID
+		require.NoError(t, service.CreateProperty(&stop))
+	}
+	return service, inspection
+}
+
+func addresses(properties []inspectionproperties.InspectionProperty) []string {
+	var result []string
+	for _, p := range properties {
+		result = append(result, p.Address)
+	}
+	return result
+}
+
+func TestSearchProperties(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	service, _ := seedProperties(t, db)
+
+	tests := []struct {
+		name         string
+		query        string
+		expectedCode int
+		expected     []string
+	}{
+		{"bounding box", "min_lat=43&max_lat=44&min_lng=-80&max_lng=-79.35", http.StatusOK, []string{"downtown a", "downtown b"}},
+		{"radius sorted nearest first", "lat=43.6517&lng=-79.3837&radius_m=10000", http.StatusOK, []string{"downtown b", "downtown a", "east"}},
+		{"small radius", "lat=43.6517&lng=-79.3837&radius_m=50", http.StatusOK, []string{"downtown b"}},
+		{"radius and box", "lat=43.6517&lng=-79.3837&radius_m=10000&min_lng=-79.35", http.StatusOK, []string{"east"}},
+		{"no filter", "", http.StatusOK, []string{"downtown a", "downtown b", "east", "ottawa"}},
+		{"latitude out of range", "min_lat=-91", http.StatusBadRequest, nil},
+		{"radius without center", "radius_m=100", http.StatusBadRequest, nil},
+		{"negative radius", "lat=43&lng=-79&radius_m=-1", http.StatusBadRequest, nil},
+		{"paged", "min_lat=43&limit=1", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, rec := newContext(http.MethodGet, "/properties?"+tt.query, "")
+
+			err := service.SearchPropertiesHandler(c)
+
+			assert.NoError(t, err)
+			require.Equal(t, tt.expectedCode, rec.Code, rec.Body.String())
+			if tt.expectedCode == http.StatusOK {
+				var results []inspectionproperties.InspectionProperty
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &results))
+				assert.Equal(t, tt.expected, addresses(results))
+			}
+		})
+	}
+
+	// Distances are reported for radius searches
+	results, err := service.SearchProperties(inspectionproperties.InspectionPropertyFilter{
+		Latitude: ptr(43.6512), Longitude: ptr(-79.3832), Radius: ptr(100.0),
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, results)
+	assert.InDelta(t, 0, *results[0].Distance, 0.001)
+}
+
+func TestSearchPropertiesGeoJSON(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	service, _ := seedProperties(t, db)
+	c, rec := newContext(http.MethodGet, "/properties?format=geojson&min_lat=45", "")
+
+	err := service.SearchPropertiesHandler(c)
+
+	assert.NoError(t, err)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/geo+json", rec.Header().Get(echo.HeaderContentType))
+	var collection struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Type     string `json:"type"`
+			Geometry struct {
+				Type        string     `json:"type"`
+				Coordinates [2]float64 `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]any `json:"properties"`
+		} `json:"features"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &collection))
+	assert.Equal(t, "FeatureCollection", collection.Type)
+	require.Len(t, collection.Features, 1)
+	assert.Equal(t, "Point", collection.Features[0].Geometry.Type)
+	assert.Equal(t, [2]float64{-75.6972, 45.4215}, collection.Features[0].Geometry.Coordinates)
+	assert.Equal(t, "ottawa", collection.Features[0].Properties["address"])
+}
+
+func TestCoverageGrid(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	service, _ := seedProperties(t, db)
+
+	cells, err := service.CoverageGrid(inspectionproperties.InspectionPropertyFilter{MaxLatitude: ptr(44.0)}, 0.01)
+	require.NoError(t, err)
+	require.Len(t, cells, 2)
+
+	// Lowest coverage first: the downtown cell averages 90 and 50
+	downtown := cells[0]
+	assert.Equal(t, int64(2), downtown.Properties)
+	assert.InDelta(t, 70, downtown.AverageCoverage, 0.001)
+	assert.InDelta(t, 50, downtown.SignCompliance, 0.001)
+	assert.InDelta(t, 0, downtown.CustomerEngagement, 0.001)
+	assert.LessOrEqual(t, downtown.MinLatitude, 43.6512)
+	assert.Greater(t, downtown.MaxLatitude, 43.6518)
+	assert.InDelta(t, 0.01, downtown.MaxLongitude-downtown.MinLongitude, 1e-9)
+
+	east := cells[1]
+	assert.Equal(t, int64(1), east.Properties)
+	assert.InDelta(t, 100, east.SignCompliance, 0.001)
+	assert.InDelta(t, 100, east.CustomerEngagement, 0.001)
+
+	tests := []struct {
+		name         string
+		query        string
+		expectedCode int
+	}{
+		{"default cell size", "", http.StatusOK},
+		{"geojson", "cell_size=1&format=geojson", http.StatusOK},
+		{"zero cell size", "cell_size=0", http.StatusBadRequest},
+		{"invalid cell size", "cell_size=big", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, rec := newContext(http.MethodGet, "/properties/coverage?"+tt.query, "")
+
+			err := service.GetCoverageGridHandler(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedCode, rec.Code, rec.Body.String())
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -2,17 +2,87 @@ package inspectionproperties
 
 import (
 	"qc_api/internal/db"
+	"qc_api/internal/utils"
 
 	"github.com/google/uuid"
 )
 
+func Models() []any {
+	return []any{
+		&InspectionProperty{},
+	}
+}
+
+// InspectionProperty is a single stop visited during an inspection.
 type InspectionProperty struct {
 	db.BaseModel
-	InspectionID         uuid.UUID `json:"inspection_id"`
+	InspectionID         uuid.UUID `gorm:"type:string;index;not null" json:"inspection_id"`
 	Address              string    `json:"address"`
 	Latitude             float64   `json:"latitude"`
 	Longitude            float64   `json:"longitude"`
 	CustomerEngagement   bool      `json:"customer_engagement"`
 	SignsPlacedCorrectly bool      `json:"signs_placed_correctly"`
-	ApplicationCoverage  int8      `json:"application_coverage"`
+	ApplicationCoverage  int8      `json:"application_coverage"`          //percent of the property treated, 0-100
+	Distance             *float64  `gorm:"-" json:"distance_m,omitempty"` //set by radius searches
+}
+
+type InspectionPropertyDTO struct {
+	Address              string   `json:"address" validate:"required"`
+	Latitude             *float64 `json:"latitude" validate:"required,latitude"`
+	Longitude            *float64 `json:"longitude" validate:"required,longitude"`
+	CustomerEngagement   bool     `json:"customer_engagement"`
+	SignsPlacedCorrectly bool     `json:"signs_placed_correctly"`
+	ApplicationCoverage  *int8    `json:"application_coverage" validate:"required,min=0,max=100"`
+}
+
+type InspectionPropertyPatch struct {
+	Address              *string  `json:"address,omitempty" validate:"omitempty,min=1"`
+	Latitude             *float64 `json:"latitude,omitempty" validate:"omitempty,latitude"`
+	Longitude            *float64 `json:"longitude,omitempty" validate:"omitempty,longitude"`
+	CustomerEngagement   *bool    `json:"customer_engagement,omitempty"`
+	SignsPlacedCorrectly *bool    `json:"signs_placed_correctly,omitempty"`
+	ApplicationCoverage  *int8    `json:"application_coverage,omitempty" validate:"omitempty,min=0,max=100"`
+}
+
+type InspectionPropertyFilter struct {
+	InspectionID *uuid.UUID `json:"inspection_id,omitempty" query:"inspection_id"`
+
+	// Bounding box, in degrees. Boxes crossing the antimeridian are not supported.
+	MinLatitude  *float64 `json:"min_lat,omitempty" query:"min_lat" filter:"latitude"`
+	MaxLatitude  *float64 `json:"max_lat,omitempty" query:"max_lat" filter:"latitude"`
+	MinLongitude *float64 `json:"min_lng,omitempty" query:"min_lng" filter:"longitude"`
+	MaxLongitude *float64 `json:"max_lng,omitempty" query:"max_lng" filter:"longitude"`
+
+	// Radius search around a point. All three must be set together.
+	Latitude  *float64 `json:"lat,omitempty" query:"lat" filter:"-"`
+	Longitude *float64 `json:"lng,omitempty" query:"lng" filter:"-"`
+	Radius    *float64 `json:"radius_m,omitempty" query:"radius_m" filter:"-"`
+
+	// Paging applies to an inspection's properties; searches return every match
+	utils.PageParams
+}
+
+// propertySorting lists what an inspection's properties can be sorted by.
+var propertySorting = utils.Sorting{
+	Columns: map[string]string{
+		"created_at":           "created_at",
+		"updated_at":           "updated_at",
+		"address":              "address",
+		"latitude":             "latitude",
+		"longitude":            "longitude",
+		"application_coverage": "application_coverage",
+	},
+	Default: "created_at",
+}
+
+// CoverageCell aggregates the properties that fall inside one grid cell.
+type CoverageCell struct {
+	MinLatitude        float64 `json:"min_lat"`
+	MinLongitude       float64 `json:"min_lng"`
+	MaxLatitude        float64 `json:"max_lat"`
+	MaxLongitude       float64 `json:"max_lng"`
+	Properties         int64   `json:"properties"`
+	AverageCoverage    float64 `json:"average_coverage"`    //mean application coverage, 0-100
+	SignCompliance     float64 `json:"sign_compliance"`     //percent of properties with signs placed correctly
+	CustomerEngagement float64 `json:"customer_engagement"` //percent of properties where the customer was engaged
 }
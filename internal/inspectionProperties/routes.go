@@ -0,0 +1,13 @@
+package inspectionproperties
+
+import "github.com/labstack/echo/v4"
+
+func RegisterRoutes(g *echo.Group, propertyService *PropertyService) {
+	g.GET("/properties", propertyService.SearchPropertiesHandler)
+	g.GET("/properties/coverage", propertyService.GetCoverageGridHandler)
+	g.POST("/inspections/:id/properties", propertyService.PostPropertyHandler)
+	g.GET("/inspections/:id/properties", propertyService.GetPropertiesHandler)
+	g.GET("/inspections/:inspectionId/properties/:id", propertyService.GetPropertyHandler)
+	g.PATCH("/inspections/:inspectionId/properties/:id", propertyService.PatchPropertyHandler)
+	g.DELETE("/inspections/:inspectionId/properties/:id", propertyService.DeletePropertyHandler)
+}
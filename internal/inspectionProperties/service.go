@@ -1 +1,183 @@
 package inspectionproperties
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"qc_api/internal/utils"
+	"sort"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrInspectionLocked = errors.New("inspection can no longer be edited")
+
+type PropertyService struct {
+	DB *gorm.DB
+}
+
+func NewPropertyService(db *gorm.DB) *PropertyService {
+	return &PropertyService{DB: db}
+}
+
+// checkInspection returns gorm.ErrRecordNotFound if the inspection does not
+// exist, and ErrInspectionLocked if it has been reviewed or closed. The
+// inspections table is queried directly since the inspections package
+// imports this one.
+func (s *PropertyService) checkInspection(inspectionID uuid.UUID) error {
+	var statuses []string
+	result := s.DB.Table("inspections").Where("id = ? AND deleted_at IS NULL", inspectionID).Pluck("status", &statuses)
+	if result.Error != nil {
+		return result.Error
+	}
+	if len(statuses) == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	if statuses[0] == "reviewed" || statuses[0] == "closed" {
+		return fmt.Errorf("%w: inspection is %s", ErrInspectionLocked, statuses[0])
+	}
+	return nil
+}
+
+func (s *PropertyService) ReadProperties(filter InspectionPropertyFilter) (*utils.Page[InspectionProperty], error) {
+	query := utils.ApplyFilter(s.DB.Model(&InspectionProperty{}), filter)
+	return utils.Paginate[InspectionProperty](query, filter.PageParams, propertySorting)
+}
+
+func (s *PropertyService) ReadProperty(inspectionID, id uuid.UUID) (*InspectionProperty, error) {
+	var property InspectionProperty
+	result := s.DB.Where("id = ? AND inspection_id = ?", id, inspectionID).First(&property)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &property, nil
+}
+
+func (s *PropertyService) CreateProperty(property *InspectionProperty) error {
+	if err := s.checkInspection(property.InspectionID); err != nil {
+		return err
+	}
+	return s.DB.Create(property).Error
+}
+
+func (s *PropertyService) UpdateProperty(inspectionID, id uuid.UUID, patch InspectionPropertyPatch) (*InspectionProperty, error) {
+	if err := s.checkInspection(inspectionID); err != nil {
+		return nil, err
+	}
+	result := s.DB.Model(&InspectionProperty{}).Where("id = ? AND inspection_id = ?", id, inspectionID).Updates(patch)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return s.ReadProperty(inspectionID, id)
+}
+
+func (s *PropertyService) DeleteProperty(inspectionID, id uuid.UUID) error {
+	if err := s.checkInspection(inspectionID); err != nil {
+		return err
+	}
+	result := s.DB.Where("inspection_id = ?", inspectionID).Delete(&InspectionProperty{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// === Geospatial ===
+
+// activeProperties scopes a query to properties whose inspection still exists.
+func (s *PropertyService) activeProperties(filter InspectionPropertyFilter) *gorm.DB {
+	query := utils.ApplyFilter(s.DB.Model(&InspectionProperty{}), filter)
+	return query.Where("inspection_id IN (?)", s.DB.Table("inspections").Select("id").Where("deleted_at IS NULL"))
+}
+
+// SearchProperties returns the properties inside the filter's bounding box
+// and/or radius. Radius results are sorted nearest first and carry their
+// distance from the search point.
+func (s *PropertyService) SearchProperties(filter InspectionPropertyFilter) ([]InspectionProperty, error) {
+	if err := filter.validate(); err != nil {
+		return nil, err
+	}
+	query := s.activeProperties(filter)
+	if filter.Radius == nil {
+		properties := []InspectionProperty{}
+		result := query.Order("created_at ASC").Find(&properties)
+		return properties, result.Error
+	}
+
+	minLat, maxLat, minLng, maxLng := filter.radiusBounds()
+	var candidates []InspectionProperty
+	result := query.Where("latitude BETWEEN ? AND ?", minLat, maxLat).
+		Where("longitude BETWEEN ? AND ?", minLng, maxLng).
+		Find(&candidates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	properties := []InspectionProperty{}
+	for _, p := range candidates {
+		distance := haversineMeters(*filter.Latitude, *filter.Longitude, p.Latitude, p.Longitude)
+		if distance <= *filter.Radius {
+			p.Distance = &distance
+			properties = append(properties, p)
+		}
+	}
+	sort.SliceStable(properties, func(i, j int) bool {
+		return *properties[i].Distance < *properties[j].Distance
+	})
+	return properties, nil
+}
+
+// CoverageGrid groups the properties matched by the filter's bounding box into
+// square cells of cellSize degrees, lowest average coverage first. Radius
+// fields are ignored.
+func (s *PropertyService) CoverageGrid(filter InspectionPropertyFilter, cellSize float64) ([]CoverageCell, error) {
+	if err := filter.validate(); err != nil {
+		return nil, err
+	}
+	if cellSize <= 0 || cellSize > MaxCellSize {
+		return nil, fmt.Errorf("%w: cell_size must be greater than 0 and at most %d degrees", ErrInvalidGeoQuery, MaxCellSize)
+	}
+
+	// Offsetting by 90/180 keeps the values positive so the integer cast floors.
+	var rows []struct {
+		CellRow            int64
+		CellCol            int64
+		Properties         int64
+		AverageCoverage    float64
+		SignCompliance     float64
+		CustomerEngagement float64
+	}
+	result := s.activeProperties(filter).
+		Select(`CAST((latitude + 90) / ? AS INTEGER) AS cell_row,
+			CAST((longitude + 180) / ? AS INTEGER) AS cell_col,
+			COUNT(*) AS properties,
+			AVG(application_coverage) AS average_coverage,
+			AVG(CASE WHEN signs_placed_correctly THEN 100.0 ELSE 0 END) AS sign_compliance,
+			AVG(CASE WHEN customer_engagement THEN 100.0 ELSE 0 END) AS customer_engagement`, cellSize, cellSize).
+		Group("cell_row, cell_col").
+		Order("average_coverage ASC, cell_row ASC, cell_col ASC").
+		Scan(&rows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	cells := make([]CoverageCell, 0, len(rows))
+	for _, r := range rows {
+		minLat := float64(r.CellRow)*cellSize - 90
+		minLng := float64(r.CellCol)*cellSize - 180
+		cells = append(cells, CoverageCell{
+			MinLatitude:        minLat,
+			MinLongitude:       minLng,
+			MaxLatitude:        math.Min(90, minLat+cellSize),
+			MaxLongitude:       math.Min(180, minLng+cellSize),
+			Properties:         r.Properties,
+			AverageCoverage:    r.AverageCoverage,
+			SignCompliance:     r.SignCompliance,
+			CustomerEngagement: r.CustomerEngagement,
+		})
+	}
+	return cells, nil
+}
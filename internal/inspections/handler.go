@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 	"qc_api/internal/utils"
+	"slices"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
@@ -32,20 +33,22 @@ func (s *InspectionService) PostInspectionHandler(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
 	}
 	inspection := &Inspection{
-		Report:                 inspectionDTO.Report,
-		UniformPPEGood:         inspectionDTO.UniformPPEGood,
-		PICPresent:             inspectionDTO.PICPresent,
-		MotiveLoggedIn:         inspectionDTO.MotiveLoggedIn,
-		PodiumLoggedIn:         inspectionDTO.PodiumLoggedIn,
-		SpillAdsorbtionPresent: inspectionDTO.SpillAdsorbtionPresent,
-		Calibration:            inspectionDTO.Calibration,
-		EmployeeID:             inspectionDTO.EmployeeID,
+		Report:      inspectionDTO.Report,
+		Calibration: inspectionDTO.Calibration,
+		EmployeeID:  inspectionDTO.EmployeeID,
+		Status:      inspectionDTO.Status,
+	}
+	if inspectionDTO.TemplateID != nil {
+		inspection.TemplateID = *inspectionDTO.TemplateID
+	}
+	if err := s.ApplyAnswers(inspection, inspectionDTO.ChecklistAnswers()); err != nil {
+		return answerErrorResponse(c, err)
 	}
 
 	inspection, err := s.CreateInspection(inspection)
 	if err != nil {
 		log.Print(err.Error())
-		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+		return answerErrorResponse(c, err)
 	}
 	return c.JSON(http.StatusOK, inspection)
 }
@@ -57,10 +60,21 @@ func (s *InspectionService) PostInspectionHandler(c echo.Context) error {
 // @Accept json
 // @Produce json
 // @Param employee_id query string false "Filter by employee ID (UUID)"
-// @Param status query string false "Filter by status"
+// @Param status query string false "Filter by status (draft, submitted, reviewed, closed)"
 // @Param date_from query string false "Filter by date from (YYYY-MM-DD)"
 // @Param date_to query string false "Filter by date to (YYYY-MM-DD)"
-// @Success 200 {array} Inspection
+// @Param min_score query number false "Filter by minimum score"
+// @Param max_score query number false "Filter by maximum score"
+// @Param passed query bool false "Filter by pass/fail"
+// @Param employee.active query bool false "Filter by whether the inspected employee is active"
+// @Param employee.employee_number query string false "Filter by the inspected employee's number"
+// @Param limit query int false "Page size (default 50, at most 200)"
+// @Param offset query int false "Rows to skip"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort keys, - for descending (e.g. -score,created_at)"
+// @Param fields query string false "Comma separated fields to return (e.g. id,score)"
+// @Success 200 {object} utils.Page[Inspection]
+// @Failure 400 {object} utils.ErrorResponse
 // @Failure 500 {object} utils.ErrorResponse
 // @Security BearerAuth
 // @Router /inspections [get]
@@ -72,6 +86,9 @@ func (s *InspectionService) GetInspectionsHandler(c echo.Context) error {
 
 	inspections, err := s.GetInspections(filter)
 	if err != nil {
+		if errors.Is(err, utils.ErrInvalidQuery) {
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+		}
 		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: "failed to retrieve inspections"})
 	}
 	return c.JSON(http.StatusOK, inspections)
@@ -79,7 +96,7 @@ func (s *InspectionService) GetInspectionsHandler(c echo.Context) error {
 
 // GetInspectionHandler godoc
 // @Summary Get inspection by ID
-// @Description Retrieve a specific inspection by its ID
+// @Description Retrieve a specific inspection by its ID, including its checklist answers and visited properties
 // @Tags inspections
 // @Accept json
 // @Produce json
@@ -103,12 +120,17 @@ func (s *InspectionService) GetInspectionHandler(c echo.Context) error {
 
 // GetByEmployeeHandler godoc
 // @Summary Get inspections by employee ID
-// @Description Retrieve all inspections for a specific employee
+// @Description Retrieve the inspections of a specific employee, by default oldest first
 // @Tags inspections
 // @Accept json
 // @Produce json
 // @Param id path string true "Employee ID"
-// @Success 200 {array} Inspection
+// @Param limit query int false "Page size (default 50, at most 200)"
+// @Param offset query int false "Rows to skip"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort keys, - for descending (e.g. -score,created_at)"
+// @Param fields query string false "Comma separated fields to return (e.g. id,score)"
+// @Success 200 {object} utils.Page[Inspection]
 // @Failure 400 {object} utils.ErrorResponse
 // @Failure 500 {object} utils.ErrorResponse
 // @Security BearerAuth
@@ -119,8 +141,15 @@ func (s *InspectionService) GetByEmployeeHandler(c echo.Context) error {
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "Invalid employee ID"})
 	}
-	inspections, err := s.GetInspectionsByEmployee(employee_id)
+	var params utils.PageParams
+	if err := c.Bind(&params); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	inspections, err := s.GetInspections(InspectionFilter{EmployeeID: &employee_id, PageParams: params})
 	if err != nil {
+		if errors.Is(err, utils.ErrInvalidQuery) {
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+		}
 		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
 	}
 	return c.JSON(http.StatusOK, inspections)
@@ -136,6 +165,7 @@ func (s *InspectionService) GetByEmployeeHandler(c echo.Context) error {
 // @Param inspection body InspectionPatch true "Inspection update data"
 // @Success 200 {object} Inspection
 // @Failure 400 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
 // @Failure 500 {object} utils.ErrorResponse
 // @Security BearerAuth
 // @Router /inspections/{id} [patch]
@@ -150,13 +180,64 @@ func (s *InspectionService) PatchInspectionHandler(c echo.Context) error {
 	if err := c.Bind(&patch); err != nil {
 		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
 	}
+	if err := c.Validate(&patch); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
 	inspection, err := s.UpdateInspection(inspection_id, patch)
 	if err != nil {
+		if errors.Is(err, ErrInvalidAnswer) {
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+		}
+		if errors.Is(err, ErrInspectionLocked) {
+			return c.JSON(http.StatusConflict, utils.ErrorResponse{Error: err.Error()})
+		}
 		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: "update to database failed"})
 	}
 	return c.JSON(http.StatusOK, inspection)
 }
 
+// PatchInspectionStatusHandler godoc
+// @Summary Change inspection status
+// @Description Move an inspection through its lifecycle: draft -> submitted -> reviewed -> closed. Submitted inspections can be returned to draft and reviewed ones reopened.
+// @Tags inspections
+// @Accept json
+// @Produce json
+// @Param id path string true "Inspection ID"
+// @Param status body InspectionStatusDTO true "Target status"
+// @Success 200 {object} Inspection
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /inspections/{id}/status [patch]
+func (s *InspectionService) PatchInspectionStatusHandler(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid inspection id"})
+	}
+	var statusDTO InspectionStatusDTO
+	if err := c.Bind(&statusDTO); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid JSON"})
+	}
+	if err := c.Validate(&statusDTO); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	userID, _ := c.Get("user_id").(uuid.UUID)
+
+	inspection, err := s.TransitionInspection(id, statusDTO.Status, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: "inspection not found"})
+		case errors.Is(err, ErrInvalidTransition):
+			return c.JSON(http.StatusConflict, utils.ErrorResponse{Error: err.Error()})
+		}
+		return answerErrorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, inspection)
+}
+
 // DeleteInspectionHandler godoc
 // @Summary Delete inspection by ID
 // @Description Soft-delete an inspection by its ID
@@ -185,3 +266,248 @@ func (s *InspectionService) DeleteInspectionHandler(c echo.Context) error {
 
 	return c.NoContent(http.StatusNoContent)
 }
+
+func answerErrorResponse(c echo.Context, err error) error {
+	if errors.Is(err, ErrInvalidAnswer) {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+}
+
+// === Checklist Templates ===
+
+// PostChecklistTemplateHandler godoc
+// @Summary Create a checklist template
+// @Description Create a checklist template that inspections can be created from
+// @Tags inspections
+// @Accept json
+// @Produce json
+// @Param template body ChecklistTemplateDTO true "Checklist template data"
+// @Success 201 {object} ChecklistTemplate
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /checklists [post]
+func (s *InspectionService) PostChecklistTemplateHandler(c echo.Context) error {
+	var templateDTO ChecklistTemplateDTO
+	if err := c.Bind(&templateDTO); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid JSON"})
+	}
+	if err := c.Validate(&templateDTO); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+
+	template := &ChecklistTemplate{
+		Name:         templateDTO.Name,
+		Description:  templateDTO.Description,
+		IsDefault:    templateDTO.IsDefault,
+		PassingScore: 80,
+	}
+	if templateDTO.PassingScore != nil {
+		template.PassingScore = *templateDTO.PassingScore
+	}
+	keys := make(map[string]bool, len(templateDTO.Questions))
+	for i, q := range templateDTO.Questions {
+		if keys[q.Key] {
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "duplicate question key " + q.Key})
+		}
+		keys[q.Key] = true
+		if q.Type == QuestionChoice && len(q.Choices) == 0 {
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "choice question " + q.Key + " needs choices"})
+		}
+		for _, choice := range q.PassingChoices {
+			if !slices.Contains(q.Choices, choice) {
+				return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "passing choice " + choice + " is not a choice of " + q.Key})
+			}
+		}
+		question := ChecklistQuestion{
+			Key:            q.Key,
+			Prompt:         q.Prompt,
+			Type:           q.Type,
+			Choices:        q.Choices,
+			Required:       q.Required,
+			Position:       i,
+			Weight:         1,
+			Critical:       q.Critical,
+			MinValue:       q.MinValue,
+			MaxValue:       q.MaxValue,
+			PassingChoices: q.PassingChoices,
+		}
+		if q.Weight != nil {
+			question.Weight = *q.Weight
+		}
+		template.Questions = append(template.Questions, question)
+	}
+
+	if err := s.CreateChecklistTemplate(template); err != nil {
+		if utils.IsUniqueConstraintError(err) {
+			return c.JSON(http.StatusConflict, utils.ErrorResponse{Error: "checklist template name already exists"})
+		}
+		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+	}
+	return c.JSON(http.StatusCreated, template)
+}
+
+// GetChecklistTemplatesHandler godoc
+// @Summary Get all checklist templates
+// @Description Retrieve checklist templates with their questions, by default ordered by name
+// @Tags inspections
+// @Accept json
+// @Produce json
+// @Param name query string false "Filter by name"
+// @Param is_default query bool false "Filter by whether the template is the default"
+// @Param limit query int false "Page size (default 50, at most 200)"
+// @Param offset query int false "Rows to skip"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort keys, - for descending (e.g. -created_at)"
+// @Param fields query string false "Comma separated fields to return (e.g. id,name)"
+// @Success 200 {object} utils.Page[ChecklistTemplate]
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /checklists [get]
+func (s *InspectionService) GetChecklistTemplatesHandler(c echo.Context) error {
+	var filter ChecklistTemplateFilter
+	if err := c.Bind(&filter); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+
+	templates, err := s.ReadChecklistTemplates(filter)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidQuery) {
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+	}
+	return c.JSON(http.StatusOK, templates)
+}
+
+// GetChecklistTemplateHandler godoc
+// @Summary Get checklist template by ID
+// @Description Retrieve a specific checklist template with its questions
+// @Tags inspections
+// @Accept json
+// @Produce json
+// @Param id path string true "Checklist Template ID"
+// @Success 200 {object} ChecklistTemplate
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /checklists/{id} [get]
+func (s *InspectionService) GetChecklistTemplateHandler(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid checklist template id"})
+	}
+	template, err := s.ReadChecklistTemplate(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: "checklist template not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+	}
+	return c.JSON(http.StatusOK, template)
+}
+
+// PatchChecklistTemplateHandler godoc
+// @Summary Update checklist template by ID
+// @Description Update the name, description or default flag of a checklist template
+// @Tags inspections
+// @Accept json
+// @Produce json
+// @Param id path string true "Checklist Template ID"
+// @Param template body ChecklistTemplatePatch true "Checklist template update data"
+// @Success 200 {object} ChecklistTemplate
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /checklists/{id} [patch]
+func (s *InspectionService) PatchChecklistTemplateHandler(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid checklist template id"})
+	}
+	var patch ChecklistTemplatePatch
+	if err := c.Bind(&patch); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	if err := c.Validate(&patch); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	template, err := s.UpdateChecklistTemplate(id, patch)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: "update to database failed"})
+	}
+	return c.JSON(http.StatusOK, template)
+}
+
+// PatchChecklistQuestionHandler godoc
+// @Summary Update checklist question
+// @Description Update the prompt, weight, critical flag or pass criteria of a checklist question
+// @Tags inspections
+// @Accept json
+// @Produce json
+// @Param templateId path string true "Checklist Template ID"
+// @Param id path string true "Checklist Question ID"
+// @Param question body ChecklistQuestionPatch true "Checklist question update data"
+// @Success 200 {object} ChecklistQuestion
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /checklists/{templateId}/questions/{id} [patch]
+func (s *InspectionService) PatchChecklistQuestionHandler(c echo.Context) error {
+	templateID, err := uuid.Parse(c.Param("templateId"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid checklist template id"})
+	}
+	questionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid checklist question id"})
+	}
+	var patch ChecklistQuestionPatch
+	if err := c.Bind(&patch); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	if err := c.Validate(&patch); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	question, err := s.UpdateChecklistQuestion(templateID, questionID, patch)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: "checklist question not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: "update to database failed"})
+	}
+	return c.JSON(http.StatusOK, question)
+}
+
+// DeleteChecklistTemplateHandler godoc
+// @Summary Delete checklist template by ID
+// @Description Soft-delete a checklist template by its ID
+// @Tags inspections
+// @Accept json
+// @Produce json
+// @Param id path string true "Checklist Template ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /checklists/{id} [delete]
+func (s *InspectionService) DeleteChecklistTemplateHandler(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid checklist template id"})
+	}
+
+	if err := s.DeleteChecklistTemplate(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: "checklist template not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: "delete failed"})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,586 @@
+package inspections_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"qc_api/internal/employees"
+	inspectionproperties "qc_api/internal/inspectionProperties"
+	"qc_api/internal/inspections"
+	"qc_api/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB() *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		panic("failed to connect database")
+	}
+	models := append(inspections.Models(), inspectionproperties.Models()...)
+	if err := db.AutoMigrate(models...); err != nil {
+		panic("failed to migrate database")
+	}
+	if err := inspections.Migrate(db); err != nil {
+		panic("failed to run inspection migrations: " + err.Error())
+	}
+	return db
+}
+
+func newContext(method, url, body string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	e.Validator = utils.NewValidator()
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	return e.NewContext(req, rec), rec
+}
+
+func answerValue(t *testing.T, inspection inspections.Inspection, key string) inspections.InspectionAnswer {
+	for _, a := range inspection.Answers {
+		if a.Key == key {
+			return a
+		}
+	}
+	t.Fatalf("no answer for %q", key)
+	return inspections.InspectionAnswer{}
+}
+
+func TestMigrateLegacyChecklistColumns(t *testing.T) {
+	// Setup: an inspections table as created before checklist templates existed
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.Exec(`CREATE TABLE inspections (
+		id TEXT PRIMARY KEY,
+		created_at DATETIME,
+		updated_at DATETIME,
+		deleted_at DATETIME,
+		report TEXT,
+		uniform_ppe_good NUMERIC,
+		pic_present NUMERIC,
+		motive_logged_in NUMERIC,
+		podium_logged_in NUMERIC,
+		spill_adsorbtion_present NUMERIC,
+		calibration REAL,
+		employee_id TEXT
+	)`).Error)
+	legacyID := uuid.New()
+	require.NoError(t, db.Exec(`INSERT INTO inspections (id, report, uniform_ppe_good, pic_present, motive_logged_in, podium_logged_in, spill_adsorbtion_present, calibration, employee_id)
+		VALUES (?, 'legacy', 1, 0, 1, 1, 0, 1.5, ?)`, legacyID, uuid.New()).Error)
+
+	// Migrate
+	require.NoError(t, db.AutoMigrate(inspections.Models()...))
+	require.NoError(t, inspections.Migrate(db))
+	require.NoError(t, db.AutoMigrate(append(inspections.Models(), inspectionproperties.Models()...)...))
+	require.NoError(t, inspections.Migrate(db))
+
+	// Assertions
+	assert.False(t, db.Migrator().HasColumn(&inspections.Inspection{}, "uniform_ppe_good"))
+	service := inspections.NewInspectionService(db)
+	inspection, err := service.GetInspectionByID(legacyID)
+	require.NoError(t, err)
+	assert.Equal(t, "legacy", inspection.Report)
+	assert.Len(t, inspection.Answers, 5)
+	assert.True(t, *answerValue(t, *inspection, inspections.KeyUniformPPEGood).BoolValue)
+	assert.False(t, *answerValue(t, *inspection, inspections.KeyPICPresent).BoolValue)
+	assert.False(t, *answerValue(t, *inspection, inspections.KeySpillAdsorbtionPresent).BoolValue)
+
+	template, err := service.ReadDefaultChecklistTemplate()
+	require.NoError(t, err)
+	assert.Equal(t, template.ID, inspection.TemplateID)
+}
+
+func TestMigrateLegacyChecklistColumnsMissingQuestion(t *testing.T) {
+	// Setup: a legacy inspections table and a default template without pic_present
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.Exec(`CREATE TABLE inspections (
+		id TEXT PRIMARY KEY,
+		created_at DATETIME,
+		updated_at DATETIME,
+		deleted_at DATETIME,
+		report TEXT,
+		uniform_ppe_good NUMERIC,
+		pic_present NUMERIC,
+		motive_logged_in NUMERIC,
+		podium_logged_in NUMERIC,
+		spill_adsorbtion_present NUMERIC,
+		calibration REAL,
+		employee_id TEXT
+	)`).Error)
+	legacyID := uuid.New()
+	require.NoError(t, db.Exec(`INSERT INTO inspections (id, report, uniform_ppe_good, pic_present, calibration, employee_id)
+		VALUES (?, 'legacy', 1, 0, 1.5, ?)`, legacyID, uuid.New()).Error)
+	require.NoError(t, db.AutoMigrate(append(inspections.Models(), inspectionproperties.Models()...)...))
+	template := &inspections.ChecklistTemplate{Name: "Custom", IsDefault: true, Questions: []inspections.ChecklistQuestion{
+		{Key: inspections.KeyUniformPPEGood, Prompt: "Uniform and PPE in good condition", Type: inspections.QuestionYesNo},
+	}}
+	require.NoError(t, db.Create(template).Error)
+
+	// Migrate
+	err = inspections.Migrate(db)
+
+	// Assertions: nothing was migrated and the values are kept
+	assert.ErrorIs(t, err, inspections.ErrLegacyQuestionMissing)
+	assert.ErrorContains(t, err, inspections.KeyPICPresent)
+	assert.True(t, db.Migrator().HasColumn(&inspections.Inspection{}, inspections.KeyPICPresent))
+	var answers int64
+	require.NoError(t, db.Model(&inspections.InspectionAnswer{}).Count(&answers).Error)
+	assert.Zero(t, answers)
+
+	// Once the template has the question the migration goes through
+	require.NoError(t, db.Create(&inspections.ChecklistQuestion{TemplateID: template.ID, Key: inspections.KeyPICPresent, Prompt: "Person in charge present", Type: inspections.QuestionYesNo, Position: 1}).Error)
+	require.NoError(t, inspections.Migrate(db))
+	assert.False(t, db.Migrator().HasColumn(&inspections.Inspection{}, inspections.KeyPICPresent))
+	inspection, err := inspections.NewInspectionService(db).GetInspectionByID(legacyID)
+	require.NoError(t, err)
+	assert.Len(t, inspection.Answers, 2)
+	assert.False(t, *answerValue(t, *inspection, inspections.KeyPICPresent).BoolValue)
+}
+
+func TestPostInspectionLegacyFields(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	service := inspections.NewInspectionService(db)
+
+	// Request
+	reqBody := `{"report": "ok", "employee_id": "` + uuid.New().String() + `", "uniform_ppe_good": true, "pic_present": false}`
+	c, rec := newContext(http.MethodPost, "/inspections", reqBody)
+
+	// Handler
+	err := service.PostInspectionHandler(c)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var inspection inspections.Inspection
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &inspection))
+	assert.Len(t, inspection.Answers, 2)
+	assert.True(t, *answerValue(t, inspection, inspections.KeyUniformPPEGood).BoolValue)
+	assert.False(t, *answerValue(t, inspection, inspections.KeyPICPresent).BoolValue)
+}
+
+func TestPostInspectionWithTemplate(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	service := inspections.NewInspectionService(db)
+
+	// Create a template
+	reqBody := `{"name": "Granular", "questions": [
+		{"key": "spreader_setting", "prompt": "Spreader setting", "type": "numeric", "required": true},
+		{"key": "weather", "prompt": "Weather", "type": "choice", "choices": ["dry", "wet"]}
+	]}`
+	c, rec := newContext(http.MethodPost, "/checklists", reqBody)
+	assert.NoError(t, service.PostChecklistTemplateHandler(c))
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var template inspections.ChecklistTemplate
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &template))
+
+	tests := []struct {
+		name         string
+		answers      string
+		expectedCode int
+	}{
+		{"valid answers", `[{"key": "spreader_setting", "value": 4.5}, {"key": "weather", "value": "dry"}]`, http.StatusOK},
+		{"missing required answer", `[{"key": "weather", "value": "dry"}]`, http.StatusBadRequest},
+		{"wrong value type", `[{"key": "spreader_setting", "value": "high"}]`, http.StatusBadRequest},
+		{"unknown choice", `[{"key": "spreader_setting", "value": 4}, {"key": "weather", "value": "snow"}]`, http.StatusBadRequest},
+		{"unknown question", `[{"key": "spreader_setting", "value": 4}, {"key": "nope", "value": true}]`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reqBody := `{"report": "ok", "employee_id": "` + uuid.New().String() + `", "template_id": "` + template.ID.String() + `", "status": "submitted", "answers": ` + tt.answers + `}`
+			c, rec := newContext(http.MethodPost, "/inspections", reqBody)
+
+			err := service.PostInspectionHandler(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedCode, rec.Code, rec.Body.String())
+		})
+	}
+}
+
+func TestPatchInspectionAnswers(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	service := inspections.NewInspectionService(db)
+	inspection := &inspections.Inspection{Report: "ok", EmployeeID: uuid.New()}
+	require.NoError(t, service.ApplyAnswers(inspection, []inspections.AnswerDTO{{Key: inspections.KeyPICPresent, Value: false}}))
+	_, err := service.CreateInspection(inspection)
+	require.NoError(t, err)
+
+	// Request
+	reqBody := `{"pic_present": true, "answers": [{"key": "motive_logged_in", "value": true}]}`
+	c, rec := newContext(http.MethodPatch, "/inspections/"+inspection.ID.String(), reqBody)
+	c.SetParamNames("id")
+	c.SetParamValues(inspection.ID.String())
+
+	// Handler
+	err = service.PatchInspectionHandler(c)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	updated, err := service.GetInspectionByID(inspection.ID)
+	require.NoError(t, err)
+	assert.Len(t, updated.Answers, 2)
+	assert.True(t, *answerValue(t, *updated, inspections.KeyPICPresent).BoolValue)
+	assert.True(t, *answerValue(t, *updated, inspections.KeyMotiveLoggedIn).BoolValue)
+}
+
+func TestInspectionScoring(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	service := inspections.NewInspectionService(db)
+
+	// Create a weighted template
+	reqBody := `{"name": "Weighted", "passing_score": 75, "questions": [
+		{"key": "ppe", "prompt": "PPE worn", "type": "yes_no", "weight": 3},
+		{"key": "spill_kit", "prompt": "Spill kit present", "type": "yes_no", "critical": true},
+		{"key": "rate", "prompt": "Application rate", "type": "numeric", "min_value": 1, "max_value": 2},
+		{"key": "notes", "prompt": "Notes", "type": "text"}
+	]}`
+	c, rec := newContext(http.MethodPost, "/checklists", reqBody)
+	assert.NoError(t, service.PostChecklistTemplateHandler(c))
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var template inspections.ChecklistTemplate
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &template))
+
+	tests := []struct {
+		name             string
+		answers          string
+		expectedScore    float64
+		expectedPassed   bool
+		expectedCritical bool
+	}{
+		{"all passing", `[{"key": "ppe", "value": true}, {"key": "spill_kit", "value": true}, {"key": "rate", "value": 1.5}, {"key": "notes", "value": "fine"}]`, 100, true, false},
+		{"rate out of range", `[{"key": "ppe", "value": true}, {"key": "spill_kit", "value": true}, {"key": "rate", "value": 2.5}]`, 80, true, false},
+		{"below passing score", `[{"key": "ppe", "value": false}, {"key": "spill_kit", "value": true}, {"key": "rate", "value": 1.5}]`, 40, false, false},
+		{"critical failure", `[{"key": "ppe", "value": true}, {"key": "spill_kit", "value": false}, {"key": "rate", "value": 1.5}]`, 80, false, true},
+		{"unanswered counts as failed", `[{"key": "ppe", "value": true}, {"key": "rate", "value": 1.5}]`, 80, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reqBody := `{"report": "ok", "employee_id": "` + uuid.New().String() + `", "template_id": "` + template.ID.String() + `", "answers": ` + tt.answers + `}`
+			c, rec := newContext(http.MethodPost, "/inspections", reqBody)
+
+			err := service.PostInspectionHandler(c)
+
+			assert.NoError(t, err)
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			var inspection inspections.Inspection
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &inspection))
+			require.NotNil(t, inspection.Score)
+			assert.InDelta(t, tt.expectedScore, *inspection.Score, 0.001)
+			assert.Equal(t, tt.expectedPassed, inspection.Passed)
+			assert.Equal(t, tt.expectedCritical, inspection.CriticalFailure)
+		})
+	}
+}
+
+func TestGetInspectionsByScore(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	service := inspections.NewInspectionService(db)
+	for _, ppe := range []bool{true, false, true} {
+		inspection := &inspections.Inspection{Report: "ok", EmployeeID: uuid.New()}
+		answers := []inspections.AnswerDTO{
+			{Key: inspections.KeyUniformPPEGood, Value: ppe},
+			{Key: inspections.KeyPICPresent, Value: true},
+		}
+		require.NoError(t, service.ApplyAnswers(inspection, answers))
+		_, err := service.CreateInspection(inspection)
+		require.NoError(t, err)
+	}
+
+	// Patching an answer rescores the inspection
+	all, err := service.GetInspections(inspections.InspectionFilter{PageParams: utils.PageParams{Sort: "score"}})
+	require.NoError(t, err)
+	require.Len(t, all.Data, 3)
+	assert.InDelta(t, 20, *all.Data[0].Score, 0.001)
+	ppe := true
+	patched, err := service.UpdateInspection(all.Data[0].ID, inspections.InspectionPatch{UniformPPEGood: &ppe})
+	require.NoError(t, err)
+	assert.InDelta(t, 40, *patched.Score, 0.001)
+
+	tests := []struct {
+		name          string
+		query         string
+		expectedCode  int
+		expectedCount int
+	}{
+		{"min score", "min_score=40", http.StatusOK, 3},
+		{"max score", "max_score=30", http.StatusOK, 0},
+		{"failed only", "passed=false", http.StatusOK, 3},
+		{"sorted by score", "sort=-score", http.StatusOK, 3},
+		{"invalid sort", "sort=report", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, rec := newContext(http.MethodGet, "/inspections?"+tt.query, "")
+
+			err := service.GetInspectionsHandler(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			if tt.expectedCode == http.StatusOK {
+				var results utils.Page[inspections.Inspection]
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &results))
+				assert.Len(t, results.Data, tt.expectedCount)
+			}
+		})
+	}
+}
+
+func TestInspectionStatusLifecycle(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	service := inspections.NewInspectionService(db)
+	inspection, err := service.CreateInspection(&inspections.Inspection{Report: "ok", EmployeeID: uuid.New()})
+	require.NoError(t, err)
+	assert.Equal(t, inspections.StatusDraft, inspection.Status)
+	reviewer := uuid.New()
+
+	steps := []struct {
+		name         string
+		status       string
+		expectedCode int
+	}{
+		{"draft cannot be closed", "closed", http.StatusConflict},
+		{"submit", "submitted", http.StatusOK},
+		{"review", "reviewed", http.StatusOK},
+		{"unknown status", "archived", http.StatusBadRequest},
+		{"close", "closed", http.StatusOK},
+		{"closed is final", "reviewed", http.StatusConflict},
+	}
+
+	for _, tt := range steps {
+		t.Run(tt.name, func(t *testing.T) {
+			c, rec := newContext(http.MethodPatch, "/inspections/"+inspection.ID.String()+"/status", `{"status": "`+tt.status+`"}`)
+			c.SetParamNames("id")
+			c.SetParamValues(inspection.ID.String())
+			c.Set("user_id", reviewer)
+
+			err := service.PatchInspectionStatusHandler(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedCode, rec.Code, rec.Body.String())
+		})
+	}
+
+	closed, err := service.GetInspectionByID(inspection.ID)
+	require.NoError(t, err)
+	assert.Equal(t, inspections.StatusClosed, closed.Status)
+	assert.NotNil(t, closed.SubmittedAt)
+	assert.NotNil(t, closed.ReviewedAt)
+	assert.NotNil(t, closed.ClosedAt)
+	require.NotNil(t, closed.ReviewedBy)
+	assert.Equal(t, reviewer, *closed.ReviewedBy)
+
+	// Closed inspections can no longer be edited
+	report := "changed"
+	c, rec := newContext(http.MethodPatch, "/inspections/"+inspection.ID.String(), `{"report": "`+report+`"}`)
+	c.SetParamNames("id")
+	c.SetParamValues(inspection.ID.String())
+	require.NoError(t, service.PatchInspectionHandler(c))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func TestGetInspectionsByStatusAndDate(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	service := inspections.NewInspectionService(db)
+	for _, status := range []inspections.InspectionStatus{inspections.StatusDraft, inspections.StatusDraft, inspections.StatusSubmitted} {
+		_, err := service.CreateInspection(&inspections.Inspection{Report: "ok", EmployeeID: uuid.New(), Status: status})
+		require.NoError(t, err)
+	}
+	old, err := service.CreateInspection(&inspections.Inspection{Report: "old", EmployeeID: uuid.New()})
+	require.NoError(t, err)
+	require.NoError(t, db.Model(old).Update("created_at", time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)).Error)
+
+	tests := []struct {
+		name          string
+		query         string
+		expectedCount int
+	}{
+		{"drafts", "status=draft", 3},
+		{"submitted", "status=submitted", 1},
+		{"from date", "date_from=2025-01-01", 3},
+		{"to date", "date_to=2024-01-15", 1},
+		{"drafts in range", "status=draft&date_from=2024-01-01&date_to=2024-01-31", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, rec := newContext(http.MethodGet, "/inspections?"+tt.query, "")
+
+			err := service.GetInspectionsHandler(c)
+
+			assert.NoError(t, err)
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			var results utils.Page[inspections.Inspection]
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &results))
+			assert.Len(t, results.Data, tt.expectedCount)
+		})
+	}
+}
+
+func TestGetInspectionsByEmployeeFields(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	require.NoError(t, db.AutoMigrate(employees.Models()...))
+	service := inspections.NewInspectionService(db)
+	var staff []employees.Employee
+	for _, number := range []string{"EMP001", "EMP002"} {
+		employee, err := employees.NewEmployee("Sam", "Sam", "Smith", number)
+		require.NoError(t, err)
+		require.NoError(t, db.Create(employee).Error)
+		staff = append(staff, *employee)
+	}
+	require.NoError(t, db.Model(&staff[1]).Update("active", false).Error)
+	for _, employee := range []employees.Employee{staff[0], staff[1], staff[1]} {
+		_, err := service.CreateInspection(&inspections.Inspection{Report: "ok", EmployeeID: employee.ID})
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		name          string
+		query         string
+		expectedCount int
+	}{
+		{"inactive employees", "employee.active=false", 2},
+		{"active employees", "employee.active=true", 1},
+		{"employee number", "employee.employee_number=EMP001", 1},
+		{"with other filters", "employee.active=false&status=draft&date_from=2025-01-01&sort=-created_at", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, rec := newContext(http.MethodGet, "/inspections?"+tt.query, "")
+
+			err := service.GetInspectionsHandler(c)
+
+			assert.NoError(t, err)
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			var results utils.Page[inspections.Inspection]
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &results))
+			assert.Len(t, results.Data, tt.expectedCount)
+		})
+	}
+
+	// Inspections of deleted employees are left out
+	require.NoError(t, db.Delete(&staff[1]).Error)
+	page, err := service.GetInspections(inspections.InspectionFilter{EmployeeActive: new(bool)})
+	require.NoError(t, err)
+	assert.Empty(t, page.Data)
+}
+
+func TestGetInspectionsByEmployee(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	service := inspections.NewInspectionService(db)
+	employeeID := uuid.New()
+	for _, report := range []string{"first", "second", "third"} {
+		_, err := service.CreateInspection(&inspections.Inspection{Report: report, EmployeeID: employeeID})
+		require.NoError(t, err)
+	}
+	_, err := service.CreateInspection(&inspections.Inspection{Report: "other", EmployeeID: uuid.New()})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name            string
+		query           string
+		expectedCode    int
+		expectedReports []string
+	}{
+		{"oldest first", "", http.StatusOK, []string{"first", "second", "third"}},
+		{"paged", "limit=2&sort=-created_at", http.StatusOK, []string{"third", "second"}},
+		{"unknown sort", "sort=report", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, rec := newContext(http.MethodGet, "/employees/"+employeeID.String()+"/inspections?"+tt.query, "")
+			c.SetParamNames("id")
+			c.SetParamValues(employeeID.String())
+
+			err := service.GetByEmployeeHandler(c)
+
+			assert.NoError(t, err)
+			require.Equal(t, tt.expectedCode, rec.Code, rec.Body.String())
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+			var results utils.Page[inspections.Inspection]
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &results))
+			reports := []string{}
+			for _, inspection := range results.Data {
+				reports = append(reports, inspection.Report)
+			}
+			assert.Equal(t, tt.expectedReports, reports)
+			assert.Equal(t, int64(3), results.Total)
+		})
+	}
+}
+
+func TestGetChecklistTemplates(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	service := inspections.NewInspectionService(db)
+	require.NoError(t, service.CreateChecklistTemplate(&inspections.ChecklistTemplate{
+		Name: "Aeration",
+		Questions: []inspections.ChecklistQuestion{
+			{Key: "cores_pulled", Prompt: "Cores pulled", Type: inspections.QuestionYesNo, Position: 1},
+			{Key: "depth", Prompt: "Core depth", Type: inspections.QuestionNumeric, Position: 0},
+		},
+	}))
+
+	tests := []struct {
+		name          string
+		query         string
+		expectedCode  int
+		expectedNames []string
+	}{
+		{"by name", "", http.StatusOK, []string{"Aeration", inspections.DefaultChecklistTemplateName}},
+		{"paged", "limit=1&sort=-name", http.StatusOK, []string{inspections.DefaultChecklistTemplateName}},
+		{"default only", "is_default=true", http.StatusOK, []string{inspections.DefaultChecklistTemplateName}},
+		{"unknown sort", "sort=passing_score", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, rec := newContext(http.MethodGet, "/checklists?"+tt.query, "")
+
+			err := service.GetChecklistTemplatesHandler(c)
+
+			assert.NoError(t, err)
+			require.Equal(t, tt.expectedCode, rec.Code, rec.Body.String())
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+			var results utils.Page[inspections.ChecklistTemplate]
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &results))
+			names := []string{}
+			for _, template := range results.Data {
+				names = append(names, template.Name)
+				if template.Name == "Aeration" {
+					require.Len(t, template.Questions, 2)
+					assert.Equal(t, "depth", template.Questions[0].Key)
+				}
+			}
+			assert.Equal(t, tt.expectedNames, names)
+		})
+	}
+}
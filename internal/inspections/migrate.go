@@ -0,0 +1,144 @@
+package inspections
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const DefaultChecklistTemplateName = "Default QC Checklist"
+
+// ErrLegacyQuestionMissing is returned when legacy checklist values cannot be
+// migrated because the default template has no question for them.
+var ErrLegacyQuestionMissing = errors.New("default checklist template has no question for a legacy checklist column")
+
+// Keys of the checklist items that used to be hardcoded boolean columns on Inspection.
+const (
+	KeyUniformPPEGood         = "uniform_ppe_good"
+	KeyPICPresent             = "pic_present"
+	KeyMotiveLoggedIn         = "motive_logged_in"
+	KeyPodiumLoggedIn         = "podium_logged_in"
+	KeySpillAdsorbtionPresent = "spill_adsorbtion_present"
+)
+
+var legacyChecklist = []ChecklistQuestion{
+	{Key: KeyUniformPPEGood, Prompt: "Uniform and PPE in good condition", Type: QuestionYesNo},
+	{Key: KeyPICPresent, Prompt: "Person in charge present", Type: QuestionYesNo},
+	{Key: KeyMotiveLoggedIn, Prompt: "Logged in to Motive", Type: QuestionYesNo},
+	{Key: KeyPodiumLoggedIn, Prompt: "Logged in to Podium", Type: QuestionYesNo},
+	{Key: KeySpillAdsorbtionPresent, Prompt: "Spill absorbent present", Type: QuestionYesNo},
+}
+
+// Migrate runs the data migrations that AutoMigrate cannot express. It must be
+// called after AutoMigrate and is safe to run on every startup.
+func Migrate(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		template, err := ensureDefaultChecklistTemplate(tx)
+		if err != nil {
+			return err
+		}
+		if err := migrateLegacyChecklistColumns(tx, template); err != nil {
+			return err
+		}
+		// Inspections created before the lifecycle existed were final when saved.
+		return tx.Model(&Inspection{}).Where("status IS NULL OR status = ''").Update("status", StatusSubmitted).Error
+	})
+}
+
+// ensureDefaultChecklistTemplate returns the default template, creating it from
+// the legacy checklist if no template has been marked as default yet.
+func ensureDefaultChecklistTemplate(tx *gorm.DB) (*ChecklistTemplate, error) {
+	var template ChecklistTemplate
+	err := tx.Preload("Questions").Where("is_default = ?", true).First(&template).Error
+	if err == nil {
+		return &template, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	template = ChecklistTemplate{
+		Name:        DefaultChecklistTemplateName,
+		Description: "Checklist items from the original inspection form",
+		IsDefault:   true,
+	}
+	for i, q := range legacyChecklist {
+		q.Position = i
+		template.Questions = append(template.Questions, q)
+	}
+	if err := tx.Create(&template).Error; err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+type legacyInspectionRow struct {
+	ID                     uuid.UUID
+	UniformPPEGood         sql.NullBool
+	PICPresent             sql.NullBool
+	MotiveLoggedIn         sql.NullBool
+	PodiumLoggedIn         sql.NullBool
+	SpillAdsorbtionPresent sql.NullBool
+}
+
+// migrateLegacyChecklistColumns converts the boolean checklist columns of
+// existing inspections into answers on the default template, then drops them.
+// A value without a matching question on the template fails the migration and
+// keeps the columns, rather than dropping the value.
+func migrateLegacyChecklistColumns(tx *gorm.DB, template *ChecklistTemplate) error {
+	if !tx.Migrator().HasColumn(&Inspection{}, KeyUniformPPEGood) {
+		return nil
+	}
+
+	questions := make(map[string]uuid.UUID, len(template.Questions))
+	for _, q := range template.Questions {
+		questions[q.Key] = q.ID
+	}
+
+	var rows []legacyInspectionRow
+	if err := tx.Table("inspections").Find(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		inspection := Inspection{TemplateID: template.ID}
+		values := []sql.NullBool{row.UniformPPEGood, row.PICPresent, row.MotiveLoggedIn, row.PodiumLoggedIn, row.SpillAdsorbtionPresent}
+		for i, value := range values {
+			if !value.Valid {
+				continue
+			}
+			questionID, ok := questions[legacyChecklist[i].Key]
+			if !ok {
+				return fmt.Errorf("%w: %q, answered on inspection %s", ErrLegacyQuestionMissing, legacyChecklist[i].Key, row.ID)
+			}
+			answer := InspectionAnswer{
+				InspectionID: row.ID,
+				QuestionID:   questionID,
+				Key:          legacyChecklist[i].Key,
+				BoolValue:    &value.Bool,
+			}
+			if err := tx.Create(&answer).Error; err != nil {
+				return err
+			}
+			inspection.Answers = append(inspection.Answers, answer)
+		}
+		ScoreInspection(&inspection, template)
+		if err := tx.Table("inspections").Where("id = ?", row.ID).Updates(map[string]any{
+			"template_id":      template.ID,
+			"score":            inspection.Score,
+			"passed":           inspection.Passed,
+			"critical_failure": inspection.CriticalFailure,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, q := range legacyChecklist {
+		if err := tx.Exec("ALTER TABLE inspections DROP COLUMN " + q.Key).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,57 +2,269 @@ package inspections
 
 import (
 	"qc_api/internal/db"
+	inspectionproperties "qc_api/internal/inspectionProperties"
+	"qc_api/internal/utils"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 func Models() []any {
 	return []any{
+		&ChecklistTemplate{},
+		&ChecklistQuestion{},
 		&Inspection{},
+		&InspectionAnswer{},
 	}
 }
 
+// QuestionType is the kind of answer a checklist question expects.
+type QuestionType string
+
+const (
+	QuestionYesNo   QuestionType = "yes_no"
+	QuestionNumeric QuestionType = "numeric"
+	QuestionChoice  QuestionType = "choice"
+	QuestionText    QuestionType = "text"
+	QuestionPhoto   QuestionType = "photo"
+)
+
+// InspectionStatus is the lifecycle stage of an inspection.
+type InspectionStatus string
+
+const (
+	StatusDraft     InspectionStatus = "draft"
+	StatusSubmitted InspectionStatus = "submitted"
+	StatusReviewed  InspectionStatus = "reviewed"
+	StatusClosed    InspectionStatus = "closed"
+)
+
+// statusTransitions lists the statuses an inspection may move to from each status.
+var statusTransitions = map[InspectionStatus][]InspectionStatus{
+	StatusDraft:     {StatusSubmitted},
+	StatusSubmitted: {StatusDraft, StatusReviewed},
+	StatusReviewed:  {StatusSubmitted, StatusClosed},
+	StatusClosed:    {},
+}
+
+// ChecklistTemplate is a configurable set of questions that inspections are created from.
+type ChecklistTemplate struct {
+	db.BaseModel
+	Name         string              `gorm:"unique;not null" json:"name"`
+	Description  string              `json:"description"`
+	IsDefault    bool                `gorm:"default:false" json:"is_default"`
+	PassingScore float64             `gorm:"default:80" json:"passing_score"` //percent of the weighted score needed to pass
+	Questions    []ChecklistQuestion `gorm:"foreignKey:TemplateID" json:"questions"`
+}
+
+// ChecklistTemplateFilter represents the filter for retrieving checklist templates.
+type ChecklistTemplateFilter struct {
+	Name      *string `json:"name,omitempty" query:"name"`
+	IsDefault *bool   `json:"is_default,omitempty" query:"is_default"`
+	utils.PageParams
+}
+
+// checklistTemplateSorting lists what checklist templates can be sorted by.
+var checklistTemplateSorting = utils.Sorting{
+	Columns: map[string]string{
+		"created_at": "created_at",
+		"updated_at": "updated_at",
+		"name":       "name",
+	},
+	Default: "name",
+}
+
+// ChecklistQuestion is a single item on a checklist template.
+type ChecklistQuestion struct {
+	db.BaseModel
+	TemplateID uuid.UUID    `gorm:"type:string;index" json:"template_id"`
+	Key        string       `gorm:"not null" json:"key"` //e.g. "uniform_ppe_good", unique within a template
+	Prompt     string       `json:"prompt"`
+	Type       QuestionType `gorm:"not null" json:"type"`
+	Choices    []string     `gorm:"serializer:json" json:"choices,omitempty"`
+	Required   bool         `gorm:"default:false" json:"required"`
+	Position   int          `json:"position"`
+
+	// Scoring. Yes/no questions pass when answered yes, numeric questions when
+	// within MinValue..MaxValue and choice questions when the answer is one of
+	// PassingChoices. Questions without pass criteria are not scored.
+	Weight         float64  `gorm:"default:1" json:"weight"`
+	Critical       bool     `gorm:"default:false" json:"critical"` //failing a critical question fails the inspection
+	MinValue       *float64 `json:"min_value,omitempty"`
+	MaxValue       *float64 `json:"max_value,omitempty"`
+	PassingChoices []string `gorm:"serializer:json" json:"passing_choices,omitempty"`
+}
+
+type ChecklistTemplateDTO struct {
+	Name         string                 `json:"name" validate:"required"`
+	Description  string                 `json:"description"`
+	IsDefault    bool                   `json:"is_default"`
+	PassingScore *float64               `json:"passing_score,omitempty" validate:"omitempty,min=0,max=100"`
+	Questions    []ChecklistQuestionDTO `json:"questions" validate:"required,min=1,dive"`
+}
+
+type ChecklistQuestionDTO struct {
+	Key      string       `json:"key" validate:"required"`
+	Prompt   string       `json:"prompt" validate:"required"`
+	Type     QuestionType `json:"type" validate:"required,oneof=yes_no numeric choice text photo"`
+	Choices  []string     `json:"choices,omitempty"`
+	Required bool         `json:"required"`
+
+	Weight         *float64 `json:"weight,omitempty" validate:"omitempty,gt=0"`
+	Critical       bool     `json:"critical"`
+	MinValue       *float64 `json:"min_value,omitempty"`
+	MaxValue       *float64 `json:"max_value,omitempty"`
+	PassingChoices []string `json:"passing_choices,omitempty"`
+}
+
+type ChecklistTemplatePatch struct {
+	Name         *string  `json:"name,omitempty"`
+	Description  *string  `json:"description,omitempty"`
+	IsDefault    *bool    `json:"is_default,omitempty"`
+	PassingScore *float64 `json:"passing_score,omitempty" validate:"omitempty,min=0,max=100"`
+}
+
+// ChecklistQuestionPatch updates how a question is scored.
+type ChecklistQuestionPatch struct {
+	Prompt         *string   `json:"prompt,omitempty"`
+	Required       *bool     `json:"required,omitempty"`
+	Weight         *float64  `json:"weight,omitempty" validate:"omitempty,gt=0"`
+	Critical       *bool     `json:"critical,omitempty"`
+	MinValue       *float64  `json:"min_value,omitempty"`
+	MaxValue       *float64  `json:"max_value,omitempty"`
+	PassingChoices *[]string `json:"passing_choices,omitempty"`
+}
+
+// InspectionAnswer stores the answer to one checklist question for an inspection.
+// Exactly one of the value columns is set, depending on the question type.
+type InspectionAnswer struct {
+	db.BaseModel
+	InspectionID uuid.UUID         `gorm:"type:string;index" json:"inspection_id"`
+	QuestionID   uuid.UUID         `gorm:"type:string;index" json:"question_id"`
+	Question     ChecklistQuestion `gorm:"foreignKey:QuestionID" json:"-"`
+	Key          string            `json:"key"`
+	BoolValue    *bool             `json:"bool_value,omitempty"`
+	NumberValue  *float64          `json:"number_value,omitempty"`
+	TextValue    *string           `json:"text_value,omitempty"`
+}
+
+// AnswerDTO is an answer to a checklist question, identified by the question key.
+// Value must be a bool for yes/no questions, a number for numeric questions and
+// a string for choice, text and photo questions.
+type AnswerDTO struct {
+	Key   string `json:"key" validate:"required"`
+	Value any    `json:"value"`
+}
+
 // InspectionDTO represents the data transfer object for creating an inspection.
 type InspectionDTO struct {
-	Report                 string    `json:"report" validate:"required"`
-	UniformPPEGood         bool      `json:"uniform_ppe_good"`
-	PICPresent             bool      `json:"pic_present"`
-	MotiveLoggedIn         bool      `json:"motive_logged_in"`
-	PodiumLoggedIn         bool      `json:"podium_logged_in"`
-	SpillAdsorbtionPresent bool      `json:"spill_adsorbtion_present"`
-	Calibration            float32   `json:"calibration"`
-	EmployeeID             uuid.UUID `json:"employee_id" validate:"required"`
+	Report      string      `json:"report" validate:"required"`
+	TemplateID  *uuid.UUID  `json:"template_id,omitempty"`
+	Answers     []AnswerDTO `json:"answers,omitempty" validate:"dive"`
+	Calibration float32     `json:"calibration"`
+	EmployeeID  uuid.UUID   `json:"employee_id" validate:"required"`
+	// Status defaults to draft; creating an inspection as submitted requires all required answers.
+	Status InspectionStatus `json:"status,omitempty" validate:"omitempty,oneof=draft submitted"`
+
+	// Original hardcoded checklist fields, recorded as answers on the default template.
+	UniformPPEGood         *bool `json:"uniform_ppe_good,omitempty"`
+	PICPresent             *bool `json:"pic_present,omitempty"`
+	MotiveLoggedIn         *bool `json:"motive_logged_in,omitempty"`
+	PodiumLoggedIn         *bool `json:"podium_logged_in,omitempty"`
+	SpillAdsorbtionPresent *bool `json:"spill_adsorbtion_present,omitempty"`
 }
 
 // Inspection represents the inspection model.
 type Inspection struct {
 	db.BaseModel
-	Report                 string    `json:"report"`
-	UniformPPEGood         bool      `json:"uniform_ppe_good"`
-	PICPresent             bool      `json:"pic_present"`
-	MotiveLoggedIn         bool      `json:"motive_logged_in"`
-	PodiumLoggedIn         bool      `json:"podium_logged_in"`
-	SpillAdsorbtionPresent bool      `json:"spill_adsorbtion_present"`
-	Calibration            float32   `json:"calibration"`
-	EmployeeID             uuid.UUID `gorm:"type:string" json:"employee_id"`
+	Report      string             `json:"report"`
+	Calibration float32            `json:"calibration"`
+	EmployeeID  uuid.UUID          `gorm:"type:string" json:"employee_id"`
+	TemplateID  uuid.UUID          `gorm:"type:string;index" json:"template_id"`
+	Answers     []InspectionAnswer `gorm:"foreignKey:InspectionID" json:"answers"`
+
+	// Only loaded for the inspection detail response.
+	Properties []inspectionproperties.InspectionProperty `gorm:"foreignKey:InspectionID" json:"properties,omitempty"`
+
+	Status      InspectionStatus `gorm:"index" json:"status"`
+	SubmittedAt *time.Time       `json:"submitted_at"`
+	ReviewedAt  *time.Time       `json:"reviewed_at"`
+	ReviewedBy  *uuid.UUID       `gorm:"type:string" json:"reviewed_by"`
+	ClosedAt    *time.Time       `json:"closed_at"`
+
+	// Computed from the answers whenever the inspection is created or patched.
+	Score           *float64 `gorm:"index" json:"score"` //weighted percent, nil if nothing on the checklist is scored
+	Passed          bool     `json:"passed"`
+	CriticalFailure bool     `json:"critical_failure"`
 }
 
 // InspectionFilter represents the filter for retrieving inspections.
 type InspectionFilter struct {
-	EmployeeID *uuid.UUID `json:"employee_id,omitempty" query:"employee_id"`
-	Status     *string    `json:"status,omitempty" query:"status"`
-	DateFrom   *string    `json:"date_from,omitempty" query:"date_from"`
-	DateTo     *string    `json:"date_to,omitempty" query:"date_to"`
+	EmployeeID *uuid.UUID        `json:"employee_id,omitempty" query:"employee_id"`
+	Status     *InspectionStatus `json:"status,omitempty" query:"status"`
+	DateFrom   *utils.SimpleDate `json:"date_from,omitempty" query:"date_from" filter:"created_at"`
+	DateTo     *utils.SimpleDate `json:"date_to,omitempty" query:"date_to" filter:"created_at"`
+	MinScore   *float64          `json:"min_score,omitempty" query:"min_score" filter:"score"`
+	MaxScore   *float64          `json:"max_score,omitempty" query:"max_score" filter:"score"`
+	Passed     *bool             `json:"passed,omitempty" query:"passed"`
+
+	// Filters on the inspected employee
+	EmployeeActive *bool   `json:"employee.active,omitempty" query:"employee.active" filter:"employee.active"`
+	EmployeeNumber *string `json:"employee.employee_number,omitempty" query:"employee.employee_number" filter:"employee.employee_number"`
+	utils.PageParams
+}
+
+// inspectionSorting lists what inspections can be sorted by.
+var inspectionSorting = utils.Sorting{
+	Columns: map[string]string{
+		"created_at":   "created_at",
+		"updated_at":   "updated_at",
+		"submitted_at": "submitted_at",
+		"reviewed_at":  "reviewed_at",
+		"closed_at":    "closed_at",
+		"status":       "status",
+		"employee_id":  "employee_id",
+		"score":        "score",
+	},
+	Default: "created_at",
+}
+
+// InspectionStatusDTO requests a lifecycle transition.
+type InspectionStatusDTO struct {
+	Status InspectionStatus `json:"status" validate:"required,oneof=draft submitted reviewed closed"`
 }
 
 // InspectionPatch represents the fields that can be updated in an inspection.
 type InspectionPatch struct {
-	Report                 *string    `json:"report,omitempty"`
-	UniformPPEGood         *bool      `json:"uniform_ppe_good,omitempty"`
-	PICPresent             *bool      `json:"pic_present,omitempty"`
-	MotiveLoggedIn         *bool      `json:"motive_logged_in,omitempty"`
-	PodiumLoggedIn         *bool      `json:"podium_logged_in,omitempty"`
-	SpillAdsorbtionPresent *bool      `json:"spill_adsorbtion_present,omitempty"`
-	Calibration            *float32   `json:"calibration,omitempty"`
-	EmployeeID             *uuid.UUID `json:"employee_id,omitempty"`
+	Report      *string     `json:"report,omitempty"`
+	Calibration *float32    `json:"calibration,omitempty"`
+	EmployeeID  *uuid.UUID  `json:"employee_id,omitempty"`
+	Answers     []AnswerDTO `gorm:"-" json:"answers,omitempty" validate:"dive"`
+
+	UniformPPEGood         *bool `gorm:"-" json:"uniform_ppe_good,omitempty"`
+	PICPresent             *bool `gorm:"-" json:"pic_present,omitempty"`
+	MotiveLoggedIn         *bool `gorm:"-" json:"motive_logged_in,omitempty"`
+	PodiumLoggedIn         *bool `gorm:"-" json:"podium_logged_in,omitempty"`
+	SpillAdsorbtionPresent *bool `gorm:"-" json:"spill_adsorbtion_present,omitempty"`
+}
+
+// ChecklistAnswers returns the explicit answers plus any legacy checklist fields.
+func (d InspectionDTO) ChecklistAnswers() []AnswerDTO {
+	return append(d.Answers, legacyAnswers(d.UniformPPEGood, d.PICPresent, d.MotiveLoggedIn, d.PodiumLoggedIn, d.SpillAdsorbtionPresent)...)
+}
+
+// ChecklistAnswers returns the explicit answers plus any legacy checklist fields.
+func (p InspectionPatch) ChecklistAnswers() []AnswerDTO {
+	return append(p.Answers, legacyAnswers(p.UniformPPEGood, p.PICPresent, p.MotiveLoggedIn, p.PodiumLoggedIn, p.SpillAdsorbtionPresent)...)
+}
+
+func legacyAnswers(uniformPPEGood, picPresent, motiveLoggedIn, podiumLoggedIn, spillAdsorbtionPresent *bool) []AnswerDTO {
+	values := []*bool{uniformPPEGood, picPresent, motiveLoggedIn, podiumLoggedIn, spillAdsorbtionPresent}
+	var answers []AnswerDTO
+	for i, value := range values {
+		if value != nil {
+			answers = append(answers, AnswerDTO{Key: legacyChecklist[i].Key, Value: *value})
+		}
+	}
+	return answers
 }
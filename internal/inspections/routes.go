@@ -8,5 +8,12 @@ func RegisterRoutes(g *echo.Group, inspectionService *InspectionService) {
 	g.GET("/inspections", inspectionService.GetInspectionsHandler)
 	g.GET("/inspections/:id", inspectionService.GetInspectionHandler)
 	g.PATCH("/inspections/:id", inspectionService.PatchInspectionHandler)
+	g.PATCH("/inspections/:id/status", inspectionService.PatchInspectionStatusHandler)
 	g.DELETE("/inspections/:id", inspectionService.DeleteInspectionHandler)
+	g.POST("/checklists", inspectionService.PostChecklistTemplateHandler)
+	g.GET("/checklists", inspectionService.GetChecklistTemplatesHandler)
+	g.GET("/checklists/:id", inspectionService.GetChecklistTemplateHandler)
+	g.PATCH("/checklists/:id", inspectionService.PatchChecklistTemplateHandler)
+	g.DELETE("/checklists/:id", inspectionService.DeleteChecklistTemplateHandler)
+	g.PATCH("/checklists/:templateId/questions/:id", inspectionService.PatchChecklistQuestionHandler)
 }
@@ -0,0 +1,74 @@
+package inspections
+
+import (
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// ScoreInspection computes the weighted score, pass/fail and critical failure
+// of an inspection from its answers. Scored questions without an answer count
+// as failed.
+func ScoreInspection(inspection *Inspection, template *ChecklistTemplate) {
+	answers := make(map[uuid.UUID]InspectionAnswer, len(inspection.Answers))
+	for _, a := range inspection.Answers {
+		answers[a.QuestionID] = a
+	}
+
+	var earned, possible float64
+	critical := false
+	for _, q := range template.Questions {
+		if !q.scored() {
+			continue
+		}
+		answer, ok := answers[q.ID]
+		passed := ok && q.passes(answer)
+		if passed {
+			earned += q.Weight
+		} else if q.Critical {
+			critical = true
+		}
+		possible += q.Weight
+	}
+
+	inspection.CriticalFailure = critical
+	if possible == 0 {
+		inspection.Score = nil
+		inspection.Passed = !critical
+		return
+	}
+	score := earned / possible * 100
+	inspection.Score = &score
+	inspection.Passed = !critical && score >= template.PassingScore
+}
+
+// scored reports whether the question has pass criteria.
+func (q ChecklistQuestion) scored() bool {
+	switch q.Type {
+	case QuestionYesNo:
+		return true
+	case QuestionNumeric:
+		return q.MinValue != nil || q.MaxValue != nil
+	case QuestionChoice:
+		return len(q.PassingChoices) > 0
+	default:
+		return false
+	}
+}
+
+func (q ChecklistQuestion) passes(answer InspectionAnswer) bool {
+	switch q.Type {
+	case QuestionYesNo:
+		return answer.BoolValue != nil && *answer.BoolValue
+	case QuestionNumeric:
+		if answer.NumberValue == nil {
+			return false
+		}
+		v := *answer.NumberValue
+		return (q.MinValue == nil || v >= *q.MinValue) && (q.MaxValue == nil || v <= *q.MaxValue)
+	case QuestionChoice:
+		return answer.TextValue != nil && slices.Contains(q.PassingChoices, *answer.TextValue)
+	default:
+		return false
+	}
+}
@@ -1,14 +1,44 @@
 package inspections
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"qc_api/internal/utils"
+	"slices"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+var ErrInvalidAnswer = errors.New("invalid checklist answer")
+var ErrInvalidTransition = errors.New("invalid status transition")
+var ErrInspectionLocked = errors.New("inspection can no longer be edited")
+
 type InspectionService struct {
-	DB *gorm.DB
+	DB        *gorm.DB
+	observers []InspectionObserver
+}
+
+// InspectionObserver is notified inside the saving transaction whenever an
+// inspection is created, edited or changes status. Returning an error rolls
+// the save back.
+type InspectionObserver interface {
+	InspectionSaved(tx *gorm.DB, inspection *Inspection) error
+}
+
+func (s *InspectionService) AddObserver(observer InspectionObserver) {
+	s.observers = append(s.observers, observer)
+}
+
+func (s *InspectionService) notify(tx *gorm.DB, inspection *Inspection) error {
+	for _, observer := range s.observers {
+		if err := observer.InspectionSaved(tx, inspection); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func NewInspectionService(db *gorm.DB) *InspectionService {
@@ -17,50 +47,380 @@ func NewInspectionService(db *gorm.DB) *InspectionService {
 	}
 }
 
-func (s *InspectionService) CreateInspection(inspection *Inspection) (*Inspection, error) {
-	result := s.DB.Create(&inspection)
-	if result.Error != nil {
-		return nil, result.Error
-	}
-	return inspection, nil
+// WithDB returns the service, with its observers, working on db, e.g. a
+// transaction of the caller's.
+func (s *InspectionService) WithDB(db *gorm.DB) *InspectionService {
+	return &InspectionService{DB: db, observers: s.observers}
 }
 
-func (s *InspectionService) GetInspections(filter InspectionFilter) ([]Inspection, error) {
-	var inspections []Inspection
-	query := utils.ApplyFilter(s.DB.Model(&Inspection{}), filter)
-	return inspections, query.Find(&inspections).Error
+// === Checklist Templates ===
+func (s *InspectionService) CreateChecklistTemplate(template *ChecklistTemplate) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if template.IsDefault {
+			if err := tx.Model(&ChecklistTemplate{}).Where("is_default = ?", true).Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(template).Error
+	})
 }
 
-func (s *InspectionService) GetInspectionByID(inspection_id uuid.UUID) (*Inspection, error) {
-	var inspection Inspection
-	result := s.DB.Where("id = ?", inspection_id).First(&inspection)
+func (s *InspectionService) ReadChecklistTemplates(filter ChecklistTemplateFilter) (*utils.Page[ChecklistTemplate], error) {
+	query := utils.ApplyFilter(s.DB.Model(&ChecklistTemplate{}), filter)
+	return utils.Paginate[ChecklistTemplate](query, filter.PageParams, checklistTemplateSorting, func(db *gorm.DB) *gorm.DB {
+		return db.Preload("Questions", orderByPosition)
+	})
+}
+
+func (s *InspectionService) ReadChecklistTemplate(id uuid.UUID) (*ChecklistTemplate, error) {
+	var template ChecklistTemplate
+	result := s.DB.Preload("Questions", orderByPosition).Where("id = ?", id).First(&template)
 	if result.Error != nil {
 		return nil, result.Error
 	}
-	return &inspection, nil
+	return &template, nil
 }
 
-func (s *InspectionService) GetInspectionsByEmployee(employee_id uuid.UUID) ([]Inspection, error) {
-	var inspections []Inspection
-	result := s.DB.Where(&Inspection{EmployeeID: employee_id}).Find(&inspections)
+// ReadDefaultChecklistTemplate returns the template used when an inspection does not name one.
+func (s *InspectionService) ReadDefaultChecklistTemplate() (*ChecklistTemplate, error) {
+	var template ChecklistTemplate
+	result := s.DB.Preload("Questions", orderByPosition).Where("is_default = ?", true).First(&template)
 	if result.Error != nil {
 		return nil, result.Error
 	}
-	return inspections, nil
+	return &template, nil
 }
-func (s *InspectionService) UpdateInspection(id uuid.UUID, patch InspectionPatch) (*Inspection, error) {
-	result := s.DB.Model(&Inspection{}).Where("id = ?", id).Updates(patch)
+
+func (s *InspectionService) UpdateChecklistTemplate(id uuid.UUID, patch ChecklistTemplatePatch) (*ChecklistTemplate, error) {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if patch.IsDefault != nil && *patch.IsDefault {
+			if err := tx.Model(&ChecklistTemplate{}).Where("is_default = ? AND id <> ?", true, id).Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&ChecklistTemplate{}).Where("id = ?", id).Updates(patch).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.ReadChecklistTemplate(id)
+}
+
+func (s *InspectionService) DeleteChecklistTemplate(id uuid.UUID) error {
+	result := s.DB.Delete(&ChecklistTemplate{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// UpdateChecklistQuestion changes the prompt or scoring of a question. Existing
+// inspections keep the score they were given until they are next patched.
+func (s *InspectionService) UpdateChecklistQuestion(templateID, questionID uuid.UUID, patch ChecklistQuestionPatch) (*ChecklistQuestion, error) {
+	updates := map[string]any{}
+	if patch.Prompt != nil {
+		updates["prompt"] = *patch.Prompt
+	}
+	if patch.Required != nil {
+		updates["required"] = *patch.Required
+	}
+	if patch.Weight != nil {
+		updates["weight"] = *patch.Weight
+	}
+	if patch.Critical != nil {
+		updates["critical"] = *patch.Critical
+	}
+	if patch.MinValue != nil {
+		updates["min_value"] = *patch.MinValue
+	}
+	if patch.MaxValue != nil {
+		updates["max_value"] = *patch.MaxValue
+	}
+	if patch.PassingChoices != nil {
+		choices, err := json.Marshal(*patch.PassingChoices)
+		if err != nil {
+			return nil, err
+		}
+		updates["passing_choices"] = string(choices)
+	}
+
+	query := s.DB.Model(&ChecklistQuestion{}).Where("id = ? AND template_id = ?", questionID, templateID)
+	if len(updates) > 0 {
+		if err := query.Updates(updates).Error; err != nil {
+			return nil, err
+		}
+	}
+	var question ChecklistQuestion
+	result := s.DB.Where("id = ? AND template_id = ?", questionID, templateID).First(&question)
 	if result.Error != nil {
 		return nil, result.Error
 	}
+	return &question, nil
+}
+
+func orderByPosition(db *gorm.DB) *gorm.DB {
+	return db.Order("position ASC")
+}
+
+// === Checklist Answers ===
+
+// ApplyAnswers validates answers against the inspection's checklist template and
+// merges them into inspection.Answers, replacing earlier answers to the same question.
+// An inspection without a template is assigned the default template.
+func (s *InspectionService) ApplyAnswers(inspection *Inspection, answers []AnswerDTO) error {
+	template, err := s.resolveChecklistTemplate(inspection.TemplateID)
+	if err != nil {
+		return err
+	}
+	inspection.TemplateID = template.ID
+
+	for _, dto := range answers {
+		idx := slices.IndexFunc(template.Questions, func(q ChecklistQuestion) bool { return q.Key == dto.Key })
+		if idx < 0 {
+			return fmt.Errorf("%w: unknown question %q", ErrInvalidAnswer, dto.Key)
+		}
+		question := template.Questions[idx]
+		answer := InspectionAnswer{
+			InspectionID: inspection.ID,
+			QuestionID:   question.ID,
+			Key:          question.Key,
+		}
+		if err := setAnswerValue(&answer, question, dto.Value); err != nil {
+			return err
+		}
+
+		existing := slices.IndexFunc(inspection.Answers, func(a InspectionAnswer) bool { return a.QuestionID == question.ID })
+		if existing >= 0 {
+			answer.BaseModel = inspection.Answers[existing].BaseModel
+			inspection.Answers[existing] = answer
+		} else {
+			inspection.Answers = append(inspection.Answers, answer)
+		}
+	}
+	return nil
+}
+
+// CheckRequiredAnswers returns an error naming the first required question without an answer.
+func (s *InspectionService) CheckRequiredAnswers(inspection *Inspection) error {
+	template, err := s.resolveChecklistTemplate(inspection.TemplateID)
+	if err != nil {
+		return err
+	}
+	for _, q := range template.Questions {
+		if !q.Required {
+			continue
+		}
+		if !slices.ContainsFunc(inspection.Answers, func(a InspectionAnswer) bool { return a.QuestionID == q.ID }) {
+			return fmt.Errorf("%w: question %q is required", ErrInvalidAnswer, q.Key)
+		}
+	}
+	return nil
+}
+
+func (s *InspectionService) resolveChecklistTemplate(id uuid.UUID) (*ChecklistTemplate, error) {
+	if id == uuid.Nil {
+		template, err := s.ReadDefaultChecklistTemplate()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: no default checklist template", ErrInvalidAnswer)
+		}
+		return template, err
+	}
+	template, err := s.ReadChecklistTemplate(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: checklist template %s not found", ErrInvalidAnswer, id)
+	}
+	return template, err
+}
+
+func setAnswerValue(answer *InspectionAnswer, question ChecklistQuestion, value any) error {
+	switch question.Type {
+	case QuestionYesNo:
+		v, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("%w: %q expects a yes/no value", ErrInvalidAnswer, question.Key)
+		}
+		answer.BoolValue = &v
+	case QuestionNumeric:
+		v, ok := value.(float64)
+		if !ok {
+			return fmt.Errorf("%w: %q expects a number", ErrInvalidAnswer, question.Key)
+		}
+		answer.NumberValue = &v
+	case QuestionChoice:
+		v, ok := value.(string)
+		if !ok || !slices.Contains(question.Choices, v) {
+			return fmt.Errorf("%w: %q expects one of %v", ErrInvalidAnswer, question.Key, question.Choices)
+		}
+		answer.TextValue = &v
+	case QuestionText, QuestionPhoto:
+		v, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%w: %q expects a string", ErrInvalidAnswer, question.Key)
+		}
+		answer.TextValue = &v
+	default:
+		return fmt.Errorf("%w: %q has unsupported type %q", ErrInvalidAnswer, question.Key, question.Type)
+	}
+	return nil
+}
+
+// scoreInspection scores an inspection against its checklist template.
+func (s *InspectionService) scoreInspection(inspection *Inspection) error {
+	template, err := s.resolveChecklistTemplate(inspection.TemplateID)
+	if err != nil {
+		return err
+	}
+	inspection.TemplateID = template.ID
+	ScoreInspection(inspection, template)
+	return nil
+}
+
+// CheckResults reports whether each answered checklist question that has pass
+// criteria passed, keyed by question key.
+func (s *InspectionService) CheckResults(inspection *Inspection) (map[string]bool, error) {
+	template, err := s.resolveChecklistTemplate(inspection.TemplateID)
+	if err != nil {
+		return nil, err
+	}
+	answers := make(map[uuid.UUID]InspectionAnswer, len(inspection.Answers))
+	for _, a := range inspection.Answers {
+		answers[a.QuestionID] = a
+	}
+	results := make(map[string]bool)
+	for _, q := range template.Questions {
+		if answer, ok := answers[q.ID]; ok && q.scored() {
+			results[q.Key] = q.passes(answer)
+		}
+	}
+	return results, nil
+}
+
+// === Inspections ===
+func (s *InspectionService) CreateInspection(inspection *Inspection) (*Inspection, error) {
+	if inspection.Status == "" {
+		inspection.Status = StatusDraft
+	}
+	if inspection.Status == StatusSubmitted {
+		if err := s.CheckRequiredAnswers(inspection); err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		inspection.SubmittedAt = &now
+	}
+	if err := s.scoreInspection(inspection); err != nil {
+		return nil, err
+	}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(inspection).Error; err != nil {
+			return err
+		}
+		return s.notify(tx, inspection)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return inspection, nil
+}
+
+func (s *InspectionService) GetInspections(filter InspectionFilter) (*utils.Page[Inspection], error) {
+	query := utils.ApplyFilter(s.DB.Model(&Inspection{}), filter)
+	return utils.Paginate[Inspection](query, filter.PageParams, inspectionSorting, func(db *gorm.DB) *gorm.DB {
+		return db.Preload("Answers")
+	})
+}
+
+func (s *InspectionService) GetInspectionByID(inspection_id uuid.UUID) (*Inspection, error) {
 	var inspection Inspection
-	result = s.DB.Where("id = ?", id).First(&inspection)
+	result := s.DB.Preload("Answers").Preload("Properties", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at ASC")
+	}).Where("id = ?", inspection_id).First(&inspection)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return &inspection, nil
 }
 
+func (s *InspectionService) UpdateInspection(id uuid.UUID, patch InspectionPatch) (*Inspection, error) {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var inspection Inspection
+		if err := tx.Preload("Answers").Where("id = ?", id).First(&inspection).Error; err != nil {
+			return err
+		}
+		if inspection.Status == StatusReviewed || inspection.Status == StatusClosed {
+			return fmt.Errorf("%w: inspection is %s", ErrInspectionLocked, inspection.Status)
+		}
+		if err := tx.Model(&inspection).Updates(patch).Error; err != nil {
+			return err
+		}
+		txService := NewInspectionService(tx)
+		if err := txService.ApplyAnswers(&inspection, patch.ChecklistAnswers()); err != nil {
+			return err
+		}
+		if err := txService.scoreInspection(&inspection); err != nil {
+			return err
+		}
+		if err := tx.Model(&inspection).Select("template_id", "score", "passed", "critical_failure").Updates(&inspection).Error; err != nil {
+			return err
+		}
+		for i := range inspection.Answers {
+			if err := tx.Save(&inspection.Answers[i]).Error; err != nil {
+				return err
+			}
+		}
+		var saved Inspection
+		if err := tx.Preload("Answers").Where("id = ?", id).First(&saved).Error; err != nil {
+			return err
+		}
+		return s.notify(tx, &saved)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetInspectionByID(id)
+}
+
+// TransitionInspection moves an inspection to a new lifecycle status. Submitting
+// requires every required checklist question to be answered.
+func (s *InspectionService) TransitionInspection(id uuid.UUID, status InspectionStatus, userID uuid.UUID) (*Inspection, error) {
+	inspection, err := s.GetInspectionByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(statusTransitions[inspection.Status], status) {
+		return nil, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, inspection.Status, status)
+	}
+
+	now := time.Now()
+	switch status {
+	case StatusSubmitted:
+		if err := s.CheckRequiredAnswers(inspection); err != nil {
+			return nil, err
+		}
+		inspection.SubmittedAt = &now
+	case StatusReviewed:
+		inspection.ReviewedAt = &now
+		inspection.ReviewedBy = &userID
+	case StatusClosed:
+		inspection.ClosedAt = &now
+	}
+	inspection.Status = status
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(inspection).Select("status", "submitted_at", "reviewed_at", "reviewed_by", "closed_at").Updates(inspection)
+		if result.Error != nil {
+			return result.Error
+		}
+		return s.notify(tx, inspection)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return inspection, nil
+}
+
 func (s *InspectionService) DeleteInspection(id uuid.UUID) error {
 	result := s.DB.Delete(&Inspection{}, id)
 	if result.Error != nil {
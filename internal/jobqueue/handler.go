@@ -0,0 +1,227 @@
+package jobqueue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"qc_api/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// GetJobsHandler godoc
+// @Summary Get jobs
+// @Description Retrieve background jobs, by default newest first, with optional filtering
+// @Tags jobs
+// @Produce json
+// @Param type query string false "Filter by job type (Transcription, ReportGeneration)"
+// @Param status query string false "Filter by status (queued, running, succeeded, failed, dead, cancelled, blocked)"
+// @Param limit query int false "Page size (default 50, at most 200)"
+// @Param offset query int false "Rows to skip"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort keys, - for descending (e.g. -created_at,status)"
+// @Param fields query string false "Comma separated fields to return (e.g. id,status)"
+// @Success 200 {object} utils.Page[Job]
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /jobs [get]
+func (q *Queue) GetJobsHandler(c echo.Context) error {
+	var filter JobFilter
+	if err := c.Bind(&filter); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	jobs, err := q.GetJobs(filter)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidQuery) {
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: "failed to retrieve jobs"})
+	}
+	return c.JSON(http.StatusOK, jobs)
+}
+
+// GetJobHandler godoc
+// @Summary Get job by ID
+// @Description Retrieve a background job with its status, attempts, error, result and the log of its last attempt
+// @Tags jobs
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} Job
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /jobs/{id} [get]
+func (q *Queue) GetJobHandler(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid job id"})
+	}
+	job, err := q.GetJobByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: "job not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: "failed to retrieve job"})
+	}
+	return c.JSON(http.StatusOK, job)
+}
+
+// RerunJobHandler godoc
+// @Summary Rerun a dead or cancelled job
+// @Description Requeue a job that ran out of attempts, failed permanently or was cancelled, resetting its attempts. Jobs blocked on it are requeued too. Admin only.
+// @Tags jobs
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} Job
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 403 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /jobs/{id}/rerun [post]
+func (q *Queue) RerunJobHandler(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid job id"})
+	}
+	job, err := q.Rerun(id)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: "job not found"})
+	case errors.Is(err, ErrJobNotDead):
+		return c.JSON(http.StatusConflict, utils.ErrorResponse{Error: err.Error()})
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: "failed to rerun job"})
+	}
+	return c.JSON(http.StatusOK, job)
+}
+
+// CancelJobHandler godoc
+// @Summary Cancel a job
+// @Description Cancel a queued job, or stop a running one. Jobs waiting on a cancelled job are blocked. Admin only.
+// @Tags jobs
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} Job
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 403 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /jobs/{id}/cancel [post]
+func (q *Queue) CancelJobHandler(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid job id"})
+	}
+	job, err := q.Cancel(id)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: "job not found"})
+	case errors.Is(err, ErrJobFinished):
+		return c.JSON(http.StatusConflict, utils.ErrorResponse{Error: err.Error()})
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: "failed to cancel job"})
+	}
+	return c.JSON(http.StatusOK, job)
+}
+
+// heartbeatInterval is how often an idle stream sends a comment, so proxies
+// do not close it.
+const heartbeatInterval = 15 * time.Second
+
+// StreamJobHandler godoc
+// @Summary Stream job output
+// @Description Follow a job as server-sent events. Output the job produced before connecting is sent first. "output" events carry new text as a JSON string, "reset" means the job started over and its earlier output should be discarded, "status" carries the job when it starts or is rescheduled, and "done" carries the finished job with its stored result and ends the stream. Browser EventSource cannot send the Authorization header; get a token for this path from POST /stream-tokens and pass it as the token query parameter instead.
+// @Tags jobs
+// @Produce text/event-stream
+// @Param id path string true "Job ID"
+// @Param token query string false "Stream token from POST /stream-tokens, instead of the Authorization header"
+// @Success 200 {string} string
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 401 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /jobs/{id}/stream [get]
+func (q *Queue) StreamJobHandler(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid job id"})
+	}
+	// Subscribe before looking the job up, so it cannot finish unnoticed in between
+	replay, events, unsubscribe := q.streams.subscribe(id)
+	defer unsubscribe()
+	job, err := q.GetJobByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: "job not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: "failed to retrieve job"})
+	}
+
+	ctx := c.Request().Context()
+	w := c.Response()
+	w.Header().Set(echo.HeaderContentType, "text/event-stream")
+	w.Header().Set(echo.HeaderCacheControl, "no-cache")
+	w.Header().Set(echo.HeaderConnection, "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if replay != "" {
+		if err := writeEvent(w, StreamEvent{Name: EventOutput, Data: replay}); err != nil {
+			return nil
+		}
+	}
+	if job.finished() {
+		writeEvent(w, q.doneEvent(ctx, job))
+		return nil
+	}
+	if err := writeEvent(w, StreamEvent{Name: EventStatus, Data: job}); err != nil {
+		return nil
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+			w.Flush()
+		case event, ok := <-events:
+			// Closed when the server shuts down or this listener fell behind;
+			// the client reconnects and gets the output replayed
+			if !ok {
+				return nil
+			}
+			if err := writeEvent(w, event); err != nil || event.Name == EventDone {
+				return nil
+			}
+		}
+	}
+}
+
+func writeEvent(w *echo.Response, event StreamEvent) error {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Name, data); err != nil {
+		return err
+	}
+	w.Flush()
+	return nil
+}
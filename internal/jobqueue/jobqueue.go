@@ -1,81 +1,457 @@
 package jobqueue
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"sync"
 	"time"
-)
 
-type JobType string
+	"qc_api/internal/utils"
 
-const (
-	Transcription    JobType = "Transcription"
-	ReportGeneration JobType = "ReportGeneration"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-type Job struct {
-	Type        JobType
-	Description string
-	// Cmd         *exec.Cmd
-	Run func() error
-}
+var ErrJobNotDead = errors.New("only dead or cancelled jobs can be rerun")
+var ErrJobFinished = errors.New("job has already finished")
 
-// Channels for each job type
-var (
-	transcriptionChan    = make(chan *Job, 100)
-	reportGenerationChan = make(chan *Job, 100)
-)
+// ErrJobBlocked is the error of a job that will not run because a job it
+// depends on is dead or cancelled.
+var ErrJobBlocked = errors.New("a job this job depends on did not succeed")
 
-func Enqueue(job *Job) {
-	switch job.Type {
-	case Transcription:
-		transcriptionChan <- job
-	case ReportGeneration:
-		reportGenerationChan <- job
-	default:
-		fmt.Println("Unknown job type")
+// ErrJobCancelled is the cause of a running job's context being cancelled
+// through Cancel.
+var ErrJobCancelled = errors.New("job cancelled")
+
+// Handler runs a job of one type. The returned string is stored as the job's
+// result reference, and anything the handler sets job.Log to as its log.
+// Handlers should return promptly once ctx is done.
+type Handler func(ctx context.Context, job *Job) (string, error)
+
+// Queue keeps jobs in the database so queued work survives restarts. Jobs are
+// run by handlers registered per job type, either one at a time with RunNext
+// or by a pool of workers started with Start.
+type Queue struct {
+	DB       *gorm.DB
+	handlers map[JobType]Handler
+	policies map[JobType]RetryPolicy
+	onDead   map[JobType]func(job *Job)
+	results  map[JobType]ResultLoader
+	streams  streams
+
+	mu      sync.Mutex
+	wake    map[JobType]chan struct{}
+	running map[uuid.UUID]context.CancelCauseFunc
+	pool    *pool
+
+	// enqueued collects the types of jobs enqueued in a transaction, see
+	// Transaction, so workers are only woken once it commits
+	enqueued *[]JobType
+}
+
+func NewQueue(db *gorm.DB) *Queue {
+	return &Queue{
+		DB:       db,
+		handlers: map[JobType]Handler{},
+		policies: map[JobType]RetryPolicy{},
+		onDead:   map[JobType]func(job *Job){},
+		results:  map[JobType]ResultLoader{},
+		wake:     map[JobType]chan struct{}{},
+		running:  map[uuid.UUID]context.CancelCauseFunc{},
 	}
 }
 
-func Worker() {
-	var lastType JobType
+// Register sets the handler and retry policy for a job type. Call it before
+// Start.
+func (q *Queue) Register(jobType JobType, handler Handler, policy RetryPolicy) {
+	q.handlers[jobType] = handler
+	q.policies[jobType] = policy
+}
+
+// OnDead sets a hook run when a job of the type stops for good: it ran out of
+// attempts, failed permanently or was cancelled.
+func (q *Queue) OnDead(jobType JobType, hook func(job *Job)) {
+	q.onDead[jobType] = hook
+}
+
+func (q *Queue) policy(jobType JobType) RetryPolicy {
+	if policy, ok := q.policies[jobType]; ok {
+		return policy
+	}
+	return DefaultRetryPolicy
+}
 
-	for {
-		var job *Job
+// wakeChan returns the channel that wakes the workers of a job type.
+func (q *Queue) wakeChan(jobType JobType) chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	ch, ok := q.wake[jobType]
+	if !ok {
+		ch = make(chan struct{}, 1)
+		q.wake[jobType] = ch
+	}
+	return ch
+}
 
-		// Try to get preferred job type first
+// notify wakes an idle worker of each given type, or of every type, without
+// blocking if they are already awake.
+func (q *Queue) notify(jobTypes ...JobType) {
+	if len(jobTypes) == 0 {
+		q.mu.Lock()
+		for jobType := range q.wake {
+			jobTypes = append(jobTypes, jobType)
+		}
+		q.mu.Unlock()
+	}
+	for _, jobType := range jobTypes {
 		select {
-		case job = <-preferChan(lastType):
-			// Got preferred type
+		case q.wakeChan(jobType) <- struct{}{}:
 		default:
-			// Preferred type not available; try any
-			select {
-			case job = <-transcriptionChan:
-			case job = <-reportGenerationChan:
-			default:
-				// No jobs; wait a bit
-				time.Sleep(100 * time.Millisecond)
-				continue
-			}
 		}
+	}
+}
+
+// Enqueue stores a queued job with payload encoded as JSON.
+func (q *Queue) Enqueue(jobType JobType, description string, payload any) (*Job, error) {
+	return q.enqueue(nil, jobType, description, payload)
+}
 
-		if job == nil {
-			continue
+// EnqueueAfter stores a job that only runs once the dependsOn job succeeds.
+func (q *Queue) EnqueueAfter(dependsOn uuid.UUID, jobType JobType, description string, payload any) (*Job, error) {
+	return q.enqueue(&dependsOn, jobType, description, payload)
+}
+
+func (q *Queue) enqueue(dependsOn *uuid.UUID, jobType JobType, description string, payload any) (*Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s payload: %w", jobType, err)
+	}
+	job := &Job{Type: jobType, Description: description, Payload: data, Status: JobQueued, DependsOnID: dependsOn, RunAt: time.Now()}
+	if err := q.DB.Create(job).Error; err != nil {
+		return nil, err
+	}
+	if q.enqueued != nil {
+		*q.enqueued = append(*q.enqueued, jobType)
+	} else {
+		q.notify(jobType)
+	}
+	return job, nil
+}
+
+// Transaction runs fn in a transaction on db, which must hold the queue's
+// tables, so jobs can be enqueued together with the caller's own writes. The
+// queue passed to fn stores jobs in the transaction and is only for
+// enqueueing; workers are woken for its jobs once the transaction commits.
+func (q *Queue) Transaction(db *gorm.DB, fn func(tx *gorm.DB, jobs *Queue) error) error {
+	var enqueued []JobType
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return fn(tx, &Queue{DB: tx, enqueued: &enqueued})
+	})
+	if err == nil && len(enqueued) > 0 {
+		q.notify(enqueued...)
+	}
+	return err
+}
+
+// Resume requeues jobs that were running when the server stopped. Call it
+// once at startup, before starting the workers.
+func (q *Queue) Resume() error {
+	result := q.DB.Model(&Job{}).Where("status = ?", JobRunning).
+		Updates(map[string]any{"status": JobQueued, "started_at": nil})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("requeued %d interrupted jobs", result.RowsAffected)
+	}
+	q.notify()
+	return nil
+}
+
+// runnable selects jobs of the given types (any type if none) that are due
+// and whose dependency has succeeded.
+func (q *Queue) runnable(tx *gorm.DB, now time.Time, jobTypes []JobType) *gorm.DB {
+	succeeded := tx.Session(&gorm.Session{NewDB: true}).Model(&Job{}).Select("id").Where("status = ?", JobSucceeded)
+	tx = tx.Where("status IN ? AND run_at <= ?", []JobStatus{JobQueued, JobFailed}, now).
+		Where("depends_on_id IS NULL OR depends_on_id IN (?)", succeeded)
+	if len(jobTypes) > 0 {
+		tx = tx.Where("type IN ?", jobTypes)
+	}
+	return tx
+}
+
+// claim marks the oldest runnable job as running.
+func (q *Queue) claim(jobTypes []JobType) (*Job, error) {
+	var job Job
+	err := q.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := q.runnable(tx, now, jobTypes).
+			Order(clause.OrderBy{Expression: clause.Expr{SQL: "created_at ASC"}}).
+			Take(&job).Error // First would replace this order with the primary key
+		if err != nil {
+			return err
 		}
+		result := tx.Model(&Job{}).Where("id = ? AND status = ?", job.ID, job.Status).
+			Updates(map[string]any{"status": JobRunning, "started_at": now, "attempts": gorm.Expr("attempts + 1")})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		job.Status, job.StartedAt, job.Attempts = JobRunning, &now, job.Attempts+1
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
 
-		fmt.Printf("[RUNNING] Job: %s (%s)\n", job.Description, job.Type)
-		err := job.Run()
+// RunNext runs one runnable job of the given types, or of any type if none
+// are given, and returns it, or nil if there was nothing to run. Jobs run
+// with a context derived from ctx. If ctx is done before the job finishes the
+// job is put back in the queue without counting the attempt.
+func (q *Queue) RunNext(ctx context.Context, jobTypes ...JobType) (*Job, error) {
+	job, err := q.claim(jobTypes)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	jobCtx, cancel := context.WithCancelCause(ctx)
+	q.mu.Lock()
+	q.running[job.ID] = cancel
+	q.mu.Unlock()
+	defer func() {
+		q.mu.Lock()
+		delete(q.running, job.ID)
+		q.mu.Unlock()
+		cancel(nil)
+	}()
+
+	log.Printf("[RUNNING] Job %s: %s (%s)", job.ID, job.Description, job.Type)
+	q.started(job)
+	var result string
+	job.Log = ""
+	handler, ok := q.handlers[job.Type]
+	if !ok {
+		err = fmt.Errorf("no handler registered for job type %q", job.Type)
+	} else {
+		result, err = handler(jobCtx, job)
+	}
+
+	now := time.Now()
+	updates := map[string]any{"result": result, "log": job.Log}
+	policy := q.policy(job.Type)
+	dead := false
+	switch {
+	case err == nil:
+		// Work that finished is kept, even if a cancel or shutdown raced it
+		log.Printf("[DONE] Job %s", job.ID)
+		updates["status"], updates["error"], updates["finished_at"] = JobSucceeded, "", now
+	case errors.Is(context.Cause(jobCtx), ErrJobCancelled):
+		log.Printf("[CANCELLED] Job %s", job.ID)
+		updates["status"], updates["error"], updates["finished_at"] = JobCancelled, ErrJobCancelled.Error(), now
+		dead = true
+	case ctx.Err() != nil:
+		log.Printf("[INTERRUPTED] Job %s, requeued", job.ID)
+		updates["status"], updates["started_at"], updates["attempts"] = JobQueued, nil, job.Attempts-1
+	case !ok || isPermanent(err) || job.Attempts >= policy.MaxAttempts:
+		log.Printf("[DEAD] Job %s after %d attempts: %v", job.ID, job.Attempts, err)
+		updates["status"], updates["error"], updates["finished_at"] = JobDead, err.Error(), now
+		dead = true
+	default:
+		delay := policy.Backoff(job.Attempts)
+		log.Printf("[ERROR] Job %s, retrying in %s: %v", job.ID, delay.Round(time.Second), err)
+		updates["status"], updates["error"], updates["run_at"] = JobFailed, err.Error(), now.Add(delay)
+	}
+	var blocked []Job
+	err = q.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(job).Updates(updates).Error; err != nil {
+			return err
+		}
+		var err error
+		if dead {
+			blocked, err = blockDependents(tx, job.ID, now)
+		}
+		return err
+	})
+	if err != nil {
+		return job, err
+	}
+	job, err = q.GetJobByID(job.ID)
+	if err != nil {
+		return nil, err
+	}
+	if hook := q.onDead[job.Type]; dead && hook != nil {
+		hook(job)
+	}
+	q.stopped(job)
+	for i := range blocked {
+		q.stopped(&blocked[i])
+	}
+	switch job.Status {
+	case JobSucceeded:
+		// Jobs depending on this one may be runnable now
+		q.notify()
+	case JobFailed:
+		q.notify(job.Type)
+	}
+	return job, nil
+}
+
+// nextRunAt returns when the earliest scheduled job of the given types is
+// due, if any is scheduled in the future.
+func (q *Queue) nextRunAt(jobTypes ...JobType) (time.Time, bool) {
+	var job Job
+	query := q.DB.Where("status IN ? AND run_at > ?", []JobStatus{JobQueued, JobFailed}, time.Now())
+	if len(jobTypes) > 0 {
+		query = query.Where("type IN ?", jobTypes)
+	}
+	err := query.Order("run_at ASC").Take(&job).Error
+	return job.RunAt, err == nil
+}
+
+// blockDependents marks the jobs waiting on a job that stopped for good as
+// blocked, along with the jobs waiting on those, and returns them.
+func blockDependents(tx *gorm.DB, id uuid.UUID, now time.Time) ([]Job, error) {
+	var blocked []Job
+	parents := []uuid.UUID{id}
+	for len(parents) > 0 {
+		var dependents []Job
+		if err := tx.Where("depends_on_id IN ? AND status IN ?", parents, []JobStatus{JobQueued, JobFailed}).Find(&dependents).Error; err != nil {
+			return nil, err
+		}
+		parents = nil
+		for i := range dependents {
+			dependents[i].Status, dependents[i].Error, dependents[i].FinishedAt = JobBlocked, ErrJobBlocked.Error(), &now
+			parents = append(parents, dependents[i].ID)
+		}
+		if len(parents) == 0 {
+			break
+		}
+		err := tx.Model(&Job{}).Where("id IN ?", parents).
+			Updates(map[string]any{"status": JobBlocked, "error": ErrJobBlocked.Error(), "finished_at": now}).Error
 		if err != nil {
-			fmt.Printf("[ERROR] %v\n", err)
-		} else {
-			fmt.Println("[DONE]")
+			return nil, err
 		}
-		lastType = job.Type
+		blocked = append(blocked, dependents...)
 	}
+	return blocked, nil
+}
+
+// unblockDependents requeues the jobs blocked on a job that is rerun, along
+// with the jobs blocked on those.
+func unblockDependents(tx *gorm.DB, id uuid.UUID, now time.Time) error {
+	parents := []uuid.UUID{id}
+	for len(parents) > 0 {
+		var dependents []uuid.UUID
+		if err := tx.Model(&Job{}).Where("depends_on_id IN ? AND status = ?", parents, JobBlocked).Pluck("id", &dependents).Error; err != nil {
+			return err
+		}
+		if len(dependents) == 0 {
+			return nil
+		}
+		err := tx.Model(&Job{}).Where("id IN ?", dependents).
+			Updates(map[string]any{"status": JobQueued, "error": "", "attempts": 0, "run_at": now, "started_at": nil, "finished_at": nil}).Error
+		if err != nil {
+			return err
+		}
+		parents = dependents
+	}
+	return nil
+}
+
+// Rerun puts a dead or cancelled job back in the queue with a fresh set of
+// attempts. Jobs blocked on it are queued again too.
+func (q *Queue) Rerun(id uuid.UUID) (*Job, error) {
+	var result *gorm.DB
+	err := q.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result = tx.Model(&Job{}).Where("id = ? AND status IN ?", id, []JobStatus{JobDead, JobCancelled}).
+			Updates(map[string]any{"status": JobQueued, "attempts": 0, "run_at": now, "started_at": nil, "finished_at": nil})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return unblockDependents(tx, id, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+	job, err := q.GetJobByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("%w: job is %s", ErrJobNotDead, job.Status)
+	}
+	q.notify(job.Type)
+	return job, nil
+}
+
+// Cancel stops a job. Queued jobs are cancelled straight away; running jobs
+// have their context cancelled and are marked cancelled once their handler
+// returns. Jobs depending on a cancelled job are blocked.
+func (q *Queue) Cancel(id uuid.UUID) (*Job, error) {
+	q.mu.Lock()
+	cancel, running := q.running[id]
+	q.mu.Unlock()
+	if running {
+		cancel(ErrJobCancelled)
+		return q.GetJobByID(id)
+	}
+
+	var result *gorm.DB
+	var blocked []Job
+	err := q.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result = tx.Model(&Job{}).Where("id = ? AND status IN ?", id, []JobStatus{JobQueued, JobFailed}).
+			Updates(map[string]any{"status": JobCancelled, "error": ErrJobCancelled.Error(), "finished_at": now})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		var err error
+		blocked, err = blockDependents(tx, id, now)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	job, err := q.GetJobByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("%w: job is %s", ErrJobFinished, job.Status)
+	}
+	if hook := q.onDead[job.Type]; hook != nil {
+		hook(job)
+	}
+	q.stopped(job)
+	for i := range blocked {
+		q.stopped(&blocked[i])
+	}
+	return job, nil
+}
+
+// === Jobs ===
+
+func (q *Queue) GetJobs(filter JobFilter) (*utils.Page[Job], error) {
+	query := utils.ApplyFilter(q.DB.Model(&Job{}), filter)
+	return utils.Paginate[Job](query, filter.PageParams, jobSorting)
 }
 
-func preferChan(last JobType) chan *Job {
-	if last == Transcription {
-		return transcriptionChan
+func (q *Queue) GetJobByID(id uuid.UUID) (*Job, error) {
+	var job Job
+	if err := q.DB.Where("id = ?", id).First(&job).Error; err != nil {
+		return nil, err
 	}
-	return reportGenerationChan
+	return &job, nil
 }
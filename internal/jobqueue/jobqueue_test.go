@@ -0,0 +1,778 @@
+package jobqueue_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"qc_api/internal/jobqueue"
+	"qc_api/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB() *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		panic("failed to connect database")
+	}
+	if err := db.AutoMigrate(jobqueue.Models()...); err != nil {
+		panic("failed to migrate database")
+	}
+	return db
+}
+
+func TestQueueRunsJobsInOrder(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	queue := jobqueue.NewQueue(db)
+	ctx := context.Background()
+	var ran []string
+	handler := func(ctx context.Context, job *jobqueue.Job) (string, error) {
+		var payload struct{ Name string }
+		require.NoError(t, json.Unmarshal(job.Payload, &payload))
+		ran = append(ran, payload.Name)
+		if payload.Name == "broken" {
+			return "", errors.New("audio is silent")
+		}
+		return "out/" + payload.Name, nil
+	}
+	queue.Register(jobqueue.Transcription, handler, jobqueue.RetryPolicy{MaxAttempts: 1})
+	queue.Register(jobqueue.ReportGeneration, handler, jobqueue.RetryPolicy{MaxAttempts: 1})
+
+	for _, j := range []struct {
+		jobType jobqueue.JobType
+		name    string
+	}{
+		{jobqueue.Transcription, "first"},
+		{jobqueue.ReportGeneration, "report"},
+		{jobqueue.Transcription, "broken"},
+	} {
+		_, err := queue.Enqueue(j.jobType, j.name, map[string]string{"Name": j.name})
+		require.NoError(t, err)
+	}
+
+	// Only the requested types run, oldest first
+	job, err := queue.RunNext(ctx, jobqueue.Transcription)
+	require.NoError(t, err)
+	assert.Equal(t, jobqueue.JobSucceeded, job.Status)
+	assert.Equal(t, "out/first", job.Result)
+	job, err = queue.RunNext(ctx, jobqueue.Transcription)
+	require.NoError(t, err)
+	assert.Equal(t, jobqueue.JobDead, job.Status)
+	assert.Equal(t, "audio is silent", job.Error)
+	assert.Empty(t, job.Result)
+	job, err = queue.RunNext(ctx, jobqueue.Transcription)
+	require.NoError(t, err)
+	assert.Nil(t, job)
+	job, err = queue.RunNext(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "report", job.Description)
+	assert.Equal(t, []string{"first", "broken", "report"}, ran)
+
+	dead := jobqueue.JobDead
+	jobs, err := queue.GetJobs(jobqueue.JobFilter{Status: &dead})
+	require.NoError(t, err)
+	require.Len(t, jobs.Data, 1)
+	assert.Equal(t, "broken", jobs.Data[0].Description)
+}
+
+func TestQueueTransaction(t *testing.T) {
+	// Setup
+	db := setupPoolDB()
+	queue := jobqueue.NewQueue(db)
+	ran := make(chan uuid.UUID, 1)
+	queue.Register(jobqueue.Transcription, func(ctx context.Context, job *jobqueue.Job) (string, error) {
+		ran <- job.ID
+		return "", nil
+	}, jobqueue.DefaultRetryPolicy)
+	queue.Start(nil)
+	defer queue.Shutdown(context.Background())
+
+	// Jobs of a rolled back transaction are never stored
+	err := queue.Transaction(db, func(tx *gorm.DB, jobs *jobqueue.Queue) error {
+		_, err := jobs.Enqueue(jobqueue.Transcription, "rolled back", nil)
+		require.NoError(t, err)
+		return errors.New("upload rejected")
+	})
+	assert.EqualError(t, err, "upload rejected")
+	var count int64
+	require.NoError(t, db.Model(&jobqueue.Job{}).Count(&count).Error)
+	assert.Zero(t, count)
+
+	// Committed jobs wake the workers
+	var job *jobqueue.Job
+	err = queue.Transaction(db, func(tx *gorm.DB, jobs *jobqueue.Queue) error {
+		job, err = jobs.Enqueue(jobqueue.Transcription, "committed", nil)
+		return err
+	})
+	require.NoError(t, err)
+	select {
+	case id := <-ran:
+		assert.Equal(t, job.ID, id)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the committed job never ran")
+	}
+}
+
+func TestQueueResume(t *testing.T) {
+	// Setup: a job left running by a previous process
+	db := setupTestDB()
+	interrupted := &jobqueue.Job{Type: jobqueue.Transcription, Description: "interrupted", Payload: json.RawMessage(`{}`), Status: jobqueue.JobRunning, Attempts: 1}
+	require.NoError(t, db.Create(interrupted).Error)
+
+	queue := jobqueue.NewQueue(db)
+	queue.Register(jobqueue.Transcription, func(ctx context.Context, job *jobqueue.Job) (string, error) {
+		return "done", nil
+	}, jobqueue.DefaultRetryPolicy)
+	job, err := queue.RunNext(context.Background())
+	require.NoError(t, err)
+	assert.Nil(t, job, "running jobs are not picked up again without Resume")
+
+	require.NoError(t, queue.Resume())
+	job, err = queue.RunNext(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	assert.Equal(t, interrupted.ID, job.ID)
+	assert.Equal(t, jobqueue.JobSucceeded, job.Status)
+	assert.Equal(t, 2, job.Attempts)
+}
+
+func TestQueueUnknownJobType(t *testing.T) {
+	queue := jobqueue.NewQueue(setupTestDB())
+	_, err := queue.Enqueue("Unknown", "mystery", nil)
+	require.NoError(t, err)
+
+	job, err := queue.RunNext(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, jobqueue.JobDead, job.Status)
+	assert.Contains(t, job.Error, "no handler")
+}
+
+func TestQueueRetriesAndDeadLetter(t *testing.T) {
+	// Setup: transcription fails twice before succeeding, reports always fail
+	queue := jobqueue.NewQueue(setupTestDB())
+	ctx := context.Background()
+	transcriptionRuns := 0
+	queue.Register(jobqueue.Transcription, func(ctx context.Context, job *jobqueue.Job) (string, error) {
+		transcriptionRuns++
+		if transcriptionRuns < 3 {
+			return "", errors.New("whisper server unavailable")
+		}
+		return "transcriptions/a.txt", nil
+	}, jobqueue.RetryPolicy{MaxAttempts: 3})
+	var dead []*jobqueue.Job
+	queue.Register(jobqueue.ReportGeneration, func(ctx context.Context, job *jobqueue.Job) (string, error) {
+		return "", errors.New("model not loaded")
+	}, jobqueue.RetryPolicy{MaxAttempts: 2})
+	queue.OnDead(jobqueue.ReportGeneration, func(job *jobqueue.Job) { dead = append(dead, job) })
+
+	transcription, err := queue.Enqueue(jobqueue.Transcription, "transcribe", nil)
+	require.NoError(t, err)
+	report, err := queue.EnqueueAfter(transcription.ID, jobqueue.ReportGeneration, "report", nil)
+	require.NoError(t, err)
+
+	// The report waits while transcription is retried
+	for attempt := 1; attempt <= 2; attempt++ {
+		job, err := queue.RunNext(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, transcription.ID, job.ID)
+		assert.Equal(t, jobqueue.JobFailed, job.Status)
+		assert.Equal(t, attempt, job.Attempts)
+		assert.Equal(t, "whisper server unavailable", job.Error)
+	}
+	job, err := queue.RunNext(ctx, jobqueue.ReportGeneration)
+	require.NoError(t, err)
+	assert.Nil(t, job)
+	job, err = queue.RunNext(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, transcription.ID, job.ID)
+	assert.Equal(t, jobqueue.JobSucceeded, job.Status)
+	assert.Empty(t, job.Error)
+
+	// Then the report runs out of attempts and is dead-lettered
+	job, err = queue.RunNext(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, report.ID, job.ID)
+	assert.Equal(t, jobqueue.JobFailed, job.Status)
+	job, err = queue.RunNext(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, jobqueue.JobDead, job.Status)
+	assert.Equal(t, 2, job.Attempts)
+	require.Len(t, dead, 1)
+	assert.Equal(t, report.ID, dead[0].ID)
+	job, err = queue.RunNext(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, job)
+
+	// Only dead jobs can be rerun
+	_, err = queue.Rerun(transcription.ID)
+	assert.True(t, errors.Is(err, jobqueue.ErrJobNotDead))
+	job, err = queue.Rerun(report.ID)
+	require.NoError(t, err)
+	assert.Equal(t, jobqueue.JobQueued, job.Status)
+	assert.Equal(t, 0, job.Attempts)
+	job, err = queue.RunNext(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, report.ID, job.ID)
+	assert.Equal(t, 1, job.Attempts)
+}
+
+func TestQueueBlocksDependents(t *testing.T) {
+	// Setup: transcription fails permanently until fixed
+	db := setupTestDB()
+	queue := jobqueue.NewQueue(db)
+	ctx := context.Background()
+	fixed := false
+	queue.Register(jobqueue.Transcription, func(ctx context.Context, job *jobqueue.Job) (string, error) {
+		if !fixed {
+			return "", jobqueue.Permanent(errors.New("unsupported audio"))
+		}
+		return "transcriptions/a.txt", nil
+	}, jobqueue.DefaultRetryPolicy)
+	queue.Register(jobqueue.ReportGeneration, func(ctx context.Context, job *jobqueue.Job) (string, error) {
+		return "reports/" + job.Description, nil
+	}, jobqueue.DefaultRetryPolicy)
+
+	transcription, err := queue.Enqueue(jobqueue.Transcription, "transcribe", nil)
+	require.NoError(t, err)
+	report, err := queue.EnqueueAfter(transcription.ID, jobqueue.ReportGeneration, "report", nil)
+	require.NoError(t, err)
+	summary, err := queue.EnqueueAfter(report.ID, jobqueue.ReportGeneration, "summary", nil)
+	require.NoError(t, err)
+
+	// A dead job blocks everything waiting on it, so nothing is left queued
+	job, err := queue.RunNext(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, jobqueue.JobDead, job.Status)
+	for _, id := range []uuid.UUID{report.ID, summary.ID} {
+		blocked, err := queue.GetJobByID(id)
+		require.NoError(t, err)
+		assert.Equal(t, jobqueue.JobBlocked, blocked.Status)
+		assert.Equal(t, jobqueue.ErrJobBlocked.Error(), blocked.Error)
+		assert.NotNil(t, blocked.FinishedAt)
+	}
+	queued := jobqueue.JobQueued
+	page, err := queue.GetJobs(jobqueue.JobFilter{Status: &queued})
+	require.NoError(t, err)
+	assert.Empty(t, page.Data)
+	_, err = queue.Rerun(report.ID)
+	assert.True(t, errors.Is(err, jobqueue.ErrJobNotDead))
+
+	// Rerunning the dead job queues its dependents again
+	fixed = true
+	_, err = queue.Rerun(transcription.ID)
+	require.NoError(t, err)
+	var ran []uuid.UUID
+	for {
+		job, err := queue.RunNext(ctx)
+		require.NoError(t, err)
+		if job == nil {
+			break
+		}
+		assert.Equal(t, jobqueue.JobSucceeded, job.Status)
+		ran = append(ran, job.ID)
+	}
+	assert.Equal(t, []uuid.UUID{transcription.ID, report.ID, summary.ID}, ran)
+
+	// Cancelling a queued job blocks its dependents too
+	transcription, err = queue.Enqueue(jobqueue.Transcription, "transcribe", nil)
+	require.NoError(t, err)
+	report, err = queue.EnqueueAfter(transcription.ID, jobqueue.ReportGeneration, "report", nil)
+	require.NoError(t, err)
+	_, err = queue.Cancel(transcription.ID)
+	require.NoError(t, err)
+	job, err = queue.GetJobByID(report.ID)
+	require.NoError(t, err)
+	assert.Equal(t, jobqueue.JobBlocked, job.Status)
+}
+
+func TestQueueBackoff(t *testing.T) {
+	// Setup
+	queue := jobqueue.NewQueue(setupTestDB())
+	queue.Register(jobqueue.Transcription, func(ctx context.Context, job *jobqueue.Job) (string, error) {
+		if string(job.Payload) == `"bad"` {
+			return "", jobqueue.Permanent(errors.New("unreadable payload"))
+		}
+		return "", errors.New("timeout")
+	}, jobqueue.RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: 4 * time.Hour})
+
+	_, err := queue.Enqueue(jobqueue.Transcription, "slow", "ok")
+	require.NoError(t, err)
+	before := time.Now()
+	job, err := queue.RunNext(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, jobqueue.JobFailed, job.Status)
+	assert.WithinRange(t, job.RunAt, before.Add(30*time.Minute), time.Now().Add(time.Hour))
+
+	// Not due yet
+	job, err = queue.RunNext(context.Background())
+	require.NoError(t, err)
+	assert.Nil(t, job)
+
+	// Permanent errors skip the remaining attempts
+	_, err = queue.Enqueue(jobqueue.Transcription, "bad", "bad")
+	require.NoError(t, err)
+	job, err = queue.RunNext(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, jobqueue.JobDead, job.Status)
+	assert.Equal(t, 1, job.Attempts)
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := jobqueue.RetryPolicy{MaxAttempts: 10, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{60, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		for range 20 {
+			delay := policy.Backoff(tt.attempt)
+			assert.GreaterOrEqual(t, delay, tt.max/2, "attempt %d", tt.attempt)
+			assert.LessOrEqual(t, delay, tt.max, "attempt %d", tt.attempt)
+		}
+	}
+}
+
+func TestGetJobHandlers(t *testing.T) {
+	// Setup
+	queue := jobqueue.NewQueue(setupTestDB())
+	job, err := queue.Enqueue(jobqueue.Transcription, "visit.m4a", map[string]string{"upload_id": "abc"})
+	require.NoError(t, err)
+	_, err = queue.Enqueue(jobqueue.ReportGeneration, "visit.m4a", nil)
+	require.NoError(t, err)
+	e := echo.New()
+
+	tests := []struct {
+		name         string
+		id           string
+		expectedCode int
+	}{
+		{"existing", job.ID.String(), http.StatusOK},
+		{"missing", uuid.New().String(), http.StatusNotFound},
+		{"invalid", "not-a-uuid", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+			c.SetParamNames("id")
+			c.SetParamValues(tt.id)
+
+			require.NoError(t, queue.GetJobHandler(c))
+			assert.Equal(t, tt.expectedCode, rec.Code)
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/jobs?type=Transcription", nil), rec)
+	require.NoError(t, queue.GetJobsHandler(c))
+	var jobs utils.Page[jobqueue.Job]
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &jobs))
+	require.Len(t, jobs.Data, 1)
+	assert.Equal(t, job.ID, jobs.Data[0].ID)
+	assert.JSONEq(t, `{"upload_id":"abc"}`, string(jobs.Data[0].Payload))
+
+	rec = httptest.NewRecorder()
+	c = e.NewContext(httptest.NewRequest(http.MethodGet, "/jobs?sort=payload", nil), rec)
+	require.NoError(t, queue.GetJobsHandler(c))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestRerunJobHandler(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	queue := jobqueue.NewQueue(db)
+	dead := &jobqueue.Job{Type: jobqueue.ReportGeneration, Payload: json.RawMessage(`{}`), Status: jobqueue.JobDead, Attempts: 5, Error: "model not loaded"}
+	require.NoError(t, db.Create(dead).Error)
+	queued, err := queue.Enqueue(jobqueue.Transcription, "queued", nil)
+	require.NoError(t, err)
+	e := echo.New()
+
+	tests := []struct {
+		name         string
+		id           string
+		expectedCode int
+	}{
+		{"dead job", dead.ID.String(), http.StatusOK},
+		{"queued job", queued.ID.String(), http.StatusConflict},
+		{"missing", uuid.New().String(), http.StatusNotFound},
+		{"invalid", "nope", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec)
+			c.SetParamNames("id")
+			c.SetParamValues(tt.id)
+
+			require.NoError(t, queue.RerunJobHandler(c))
+			assert.Equal(t, tt.expectedCode, rec.Code, rec.Body.String())
+		})
+	}
+}
+
+// setupPoolDB shares one connection between workers, since every connection
+// to :memory: opens a separate empty database.
+func setupPoolDB() *gorm.DB {
+	db := setupTestDB()
+	sqlDB, err := db.DB()
+	if err != nil {
+		panic(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	return db
+}
+
+func waitForStatus(t *testing.T, queue *jobqueue.Queue, id uuid.UUID, status jobqueue.JobStatus) *jobqueue.Job {
+	var job *jobqueue.Job
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = queue.GetJobByID(id)
+		return err == nil && job.Status == status
+	}, 5*time.Second, 5*time.Millisecond, "job never became %s", status)
+	return job
+}
+
+func TestWorkerPoolConcurrency(t *testing.T) {
+	// Setup: transcription jobs block until released
+	queue := jobqueue.NewQueue(setupPoolDB())
+	release := make(chan struct{})
+	var mu sync.Mutex
+	active, maxActive := 0, 0
+	queue.Register(jobqueue.Transcription, func(ctx context.Context, job *jobqueue.Job) (string, error) {
+		mu.Lock()
+		active++
+		maxActive = max(maxActive, active)
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			active--
+			mu.Unlock()
+		}()
+		select {
+		case <-release:
+			return "ok", nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}, jobqueue.DefaultRetryPolicy)
+	queue.Register(jobqueue.ReportGeneration, func(ctx context.Context, job *jobqueue.Job) (string, error) {
+		return "report", nil
+	}, jobqueue.DefaultRetryPolicy)
+	queue.Start(map[jobqueue.JobType]int{jobqueue.Transcription: 2})
+	defer queue.Shutdown(context.Background())
+
+	var ids []uuid.UUID
+	for range 4 {
+		job, err := queue.Enqueue(jobqueue.Transcription, "slow", nil)
+		require.NoError(t, err)
+		ids = append(ids, job.ID)
+	}
+
+	// Reports have their own workers, so they aren't stuck behind transcription
+	report, err := queue.Enqueue(jobqueue.ReportGeneration, "fast", nil)
+	require.NoError(t, err)
+	waitForStatus(t, queue, report.ID, jobqueue.JobSucceeded)
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return active == 2
+	}, 5*time.Second, 5*time.Millisecond)
+	close(release)
+	for _, id := range ids {
+		waitForStatus(t, queue, id, jobqueue.JobSucceeded)
+	}
+	assert.Equal(t, 2, maxActive)
+}
+
+func TestCancelJob(t *testing.T) {
+	// Setup: jobs run until cancelled
+	queue := jobqueue.NewQueue(setupPoolDB())
+	started := make(chan uuid.UUID, 1)
+	var dead []uuid.UUID
+	queue.Register(jobqueue.Transcription, func(ctx context.Context, job *jobqueue.Job) (string, error) {
+		started <- job.ID
+		<-ctx.Done()
+		return "", ctx.Err()
+	}, jobqueue.DefaultRetryPolicy)
+	queue.OnDead(jobqueue.Transcription, func(job *jobqueue.Job) { dead = append(dead, job.ID) })
+	queue.Start(nil)
+	defer queue.Shutdown(context.Background())
+
+	running, err := queue.Enqueue(jobqueue.Transcription, "running", nil)
+	require.NoError(t, err)
+	require.Equal(t, running.ID, <-started)
+	queued, err := queue.Enqueue(jobqueue.Transcription, "queued", nil)
+	require.NoError(t, err)
+
+	// Cancelling a queued job is immediate
+	job, err := queue.Cancel(queued.ID)
+	require.NoError(t, err)
+	assert.Equal(t, jobqueue.JobCancelled, job.Status)
+
+	// A running job stops once its handler sees the cancellation
+	_, err = queue.Cancel(running.ID)
+	require.NoError(t, err)
+	job = waitForStatus(t, queue, running.ID, jobqueue.JobCancelled)
+	assert.Equal(t, jobqueue.ErrJobCancelled.Error(), job.Error)
+	assert.Equal(t, 1, job.Attempts, "cancelled jobs are not retried")
+
+	_, err = queue.Cancel(running.ID)
+	assert.True(t, errors.Is(err, jobqueue.ErrJobFinished))
+	assert.ElementsMatch(t, []uuid.UUID{queued.ID, running.ID}, dead)
+}
+
+func TestCancelJobRace(t *testing.T) {
+	// Setup: the handler finishes its work even though it is cancelled
+	queue := jobqueue.NewQueue(setupTestDB())
+	queue.Register(jobqueue.Transcription, func(ctx context.Context, job *jobqueue.Job) (string, error) {
+		_, err := queue.Cancel(job.ID)
+		require.NoError(t, err)
+		return "transcriptions/a.txt", nil
+	}, jobqueue.DefaultRetryPolicy)
+	_, err := queue.Enqueue(jobqueue.Transcription, "transcribe", nil)
+	require.NoError(t, err)
+
+	// The result is kept
+	job, err := queue.RunNext(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, jobqueue.JobSucceeded, job.Status)
+	assert.Equal(t, "transcriptions/a.txt", job.Result)
+
+	// Only admins may cancel
+	e := echo.New()
+	forbidden := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error { return c.NoContent(http.StatusForbidden) }
+	}
+	jobqueue.RegisterRoutes(e, e.Group(""), queue, forbidden, forbidden)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs/"+job.ID.String()+"/cancel", nil))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestShutdownDrainsJobs(t *testing.T) {
+	// Setup: one job that finishes when asked, one that only stops when cancelled
+	queue := jobqueue.NewQueue(setupPoolDB())
+	started := make(chan string, 2)
+	finish := make(chan struct{})
+	queue.Register(jobqueue.Transcription, func(ctx context.Context, job *jobqueue.Job) (string, error) {
+		started <- job.Description
+		<-finish
+		return "done", nil
+	}, jobqueue.DefaultRetryPolicy)
+	queue.Register(jobqueue.ReportGeneration, func(ctx context.Context, job *jobqueue.Job) (string, error) {
+		started <- job.Description
+		<-ctx.Done()
+		return "", ctx.Err()
+	}, jobqueue.DefaultRetryPolicy)
+	queue.Start(nil)
+
+	finishing, err := queue.Enqueue(jobqueue.Transcription, "finishing", nil)
+	require.NoError(t, err)
+	stuck, err := queue.Enqueue(jobqueue.ReportGeneration, "stuck", nil)
+	require.NoError(t, err)
+	<-started
+	<-started
+
+	// The finishing job completes during the drain; the stuck one is
+	// interrupted at the deadline and left queued for the next start
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(finish)
+	}()
+	err = queue.Shutdown(ctx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	job, err := queue.GetJobByID(finishing.ID)
+	require.NoError(t, err)
+	assert.Equal(t, jobqueue.JobSucceeded, job.Status)
+	job, err = queue.GetJobByID(stuck.ID)
+	require.NoError(t, err)
+	assert.Equal(t, jobqueue.JobQueued, job.Status)
+	assert.Equal(t, 0, job.Attempts)
+
+	// Nothing new is started after shutdown
+	late, err := queue.Enqueue(jobqueue.Transcription, "late", nil)
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	job, err = queue.GetJobByID(late.ID)
+	require.NoError(t, err)
+	assert.Equal(t, jobqueue.JobQueued, job.Status)
+}
+
+type sseEvent struct {
+	name string
+	data string
+}
+
+// readEvents parses server-sent events from r until it ends, skipping
+// comments.
+func readEvents(r io.Reader) <-chan sseEvent {
+	events := make(chan sseEvent, 16)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(r)
+		var event sseEvent
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				events <- event
+				event = sseEvent{}
+			case strings.HasPrefix(line, "event: "):
+				event.name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				event.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+	return events
+}
+
+func nextEvent(t *testing.T, events <-chan sseEvent) sseEvent {
+	select {
+	case event, ok := <-events:
+		require.True(t, ok, "stream ended")
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event")
+		return sseEvent{}
+	}
+}
+
+func TestStreamJob(t *testing.T) {
+	// Setup: the job writes some output, then waits to be released
+	queue := jobqueue.NewQueue(setupPoolDB())
+	wrote, release := make(chan struct{}), make(chan struct{})
+	queue.Register(jobqueue.ReportGeneration, func(ctx context.Context, job *jobqueue.Job) (string, error) {
+		output := queue.Output(job.ID)
+		fmt.Fprint(output, "PPE: ")
+		close(wrote)
+		<-release
+		fmt.Fprint(output, "yes\n")
+		return "reports/a.json", nil
+	}, jobqueue.DefaultRetryPolicy)
+	queue.SetResultLoader(jobqueue.ReportGeneration, func(ctx context.Context, job *jobqueue.Job) (any, error) {
+		return map[string]string{"stored": job.Result}, nil
+	})
+	e := echo.New()
+	pass := func(next echo.HandlerFunc) echo.HandlerFunc { return next }
+	jobqueue.RegisterRoutes(e, e.Group(""), queue, pass, pass)
+	server := httptest.NewServer(e)
+	t.Cleanup(server.Close)
+	connect := func(id string) (*http.Response, <-chan sseEvent) {
+		resp, err := http.Get(server.URL + "/jobs/" + id + "/stream")
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp, readEvents(resp.Body)
+	}
+
+	job, err := queue.Enqueue(jobqueue.ReportGeneration, "report", nil)
+	require.NoError(t, err)
+	go queue.RunNext(context.Background())
+	<-wrote
+
+	// Listeners connecting mid-job get the output so far, then follow along
+	var listeners []<-chan sseEvent
+	for range 2 {
+		resp, events := connect(job.ID.String())
+		assert.Equal(t, "text/event-stream", resp.Header.Get(echo.HeaderContentType))
+		assert.Equal(t, sseEvent{"output", `"PPE: "`}, nextEvent(t, events))
+		status := nextEvent(t, events)
+		assert.Equal(t, "status", status.name)
+		assert.Contains(t, status.data, `"status":"running"`)
+		listeners = append(listeners, events)
+	}
+	close(release)
+	for _, events := range listeners {
+		assert.Equal(t, sseEvent{"output", `"yes\n"`}, nextEvent(t, events))
+		done := nextEvent(t, events)
+		assert.Equal(t, "done", done.name)
+		var data struct {
+			Job    jobqueue.Job      `json:"job"`
+			Result map[string]string `json:"result"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(done.data), &data))
+		assert.Equal(t, jobqueue.JobSucceeded, data.Job.Status)
+		assert.Equal(t, map[string]string{"stored": "reports/a.json"}, data.Result)
+		_, open := <-events
+		assert.False(t, open, "the stream ends after the final event")
+	}
+
+	// Connecting after the job finished only gets the final event
+	_, events := connect(job.ID.String())
+	done := nextEvent(t, events)
+	assert.Equal(t, "done", done.name)
+	assert.Contains(t, done.data, `"stored":"reports/a.json"`)
+
+	// Unknown jobs
+	resp, err := http.Get(server.URL + "/jobs/" + uuid.New().String() + "/stream")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestStreamJobRetry(t *testing.T) {
+	// Setup: the first attempt fails part way through
+	queue := jobqueue.NewQueue(setupPoolDB())
+	attempts := 0
+	queue.Register(jobqueue.ReportGeneration, func(ctx context.Context, job *jobqueue.Job) (string, error) {
+		attempts++
+		fmt.Fprintf(queue.Output(job.ID), "attempt %d", attempts)
+		if attempts == 1 {
+			return "", errors.New("model crashed")
+		}
+		return "reports/a.json", nil
+	}, jobqueue.RetryPolicy{MaxAttempts: 2})
+	e := echo.New()
+	pass := func(next echo.HandlerFunc) echo.HandlerFunc { return next }
+	jobqueue.RegisterRoutes(e, e.Group(""), queue, pass, pass)
+	server := httptest.NewServer(e)
+	t.Cleanup(server.Close)
+	job, err := queue.Enqueue(jobqueue.ReportGeneration, "report", nil)
+	require.NoError(t, err)
+	_, err = queue.RunNext(context.Background())
+	require.NoError(t, err)
+
+	// A listener sees the failed attempt's output and when it was rescheduled
+	resp, err := http.Get(server.URL + "/jobs/" + job.ID.String() + "/stream")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	events := readEvents(resp.Body)
+	assert.Equal(t, sseEvent{"output", `"attempt 1"`}, nextEvent(t, events))
+	assert.Contains(t, nextEvent(t, events).data, `"status":"failed"`)
+
+	// The retry starts over
+	require.NoError(t, queue.DB.Model(&jobqueue.Job{}).Where("id = ?", job.ID).Update("run_at", time.Now()).Error)
+	_, err = queue.RunNext(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "reset", nextEvent(t, events).name)
+	assert.Contains(t, nextEvent(t, events).data, `"status":"running"`)
+	assert.Equal(t, sseEvent{"output", `"attempt 2"`}, nextEvent(t, events))
+	assert.Equal(t, "done", nextEvent(t, events).name)
+}
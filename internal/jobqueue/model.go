@@ -0,0 +1,83 @@
+package jobqueue
+
+import (
+	"encoding/json"
+	"time"
+
+	"qc_api/internal/db"
+	"qc_api/internal/utils"
+
+	"github.com/google/uuid"
+)
+
+func Models() []any {
+	return []any{
+		&Job{},
+	}
+}
+
+type JobType string
+
+const (
+	Transcription    JobType = "Transcription"
+	ReportGeneration JobType = "ReportGeneration"
+)
+
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed" //the last attempt failed and a retry is scheduled at RunAt
+	JobDead      JobStatus = "dead"   //out of attempts, or failed permanently; only rerun by an admin
+	JobCancelled JobStatus = "cancelled"
+	JobBlocked   JobStatus = "blocked" //a job it depends on is dead or cancelled; requeued when that job is rerun
+)
+
+// Job is a unit of background work. Payload is the JSON input the job type's
+// handler needs and Result is a reference to what it produced, such as a
+// storage key. Log is the diagnostic output of the last attempt, such as a
+// transcription engine's stderr. A job with DependsOnID only runs once that
+// job has succeeded.
+type Job struct {
+	db.BaseModel
+	Type        JobType         `gorm:"index;not null" json:"type"`
+	Description string          `json:"description"`
+	Payload     json.RawMessage `gorm:"type:text" json:"payload" swaggertype:"object"`
+	Status      JobStatus       `gorm:"index;not null" json:"status"`
+	DependsOnID *uuid.UUID      `gorm:"type:string;index" json:"depends_on_id,omitempty"`
+	Attempts    int             `json:"attempts"`
+	RunAt       time.Time       `gorm:"index" json:"run_at"`
+	Error       string          `json:"error,omitempty"`
+	Result      string          `json:"result,omitempty"`
+	Log         string          `gorm:"type:text" json:"log,omitempty"`
+	StartedAt   *time.Time      `json:"started_at,omitempty"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+}
+
+// finished reports whether the job will not run again unless rerun.
+func (j *Job) finished() bool {
+	return j.Status == JobSucceeded || j.Status == JobDead || j.Status == JobCancelled || j.Status == JobBlocked
+}
+
+type JobFilter struct {
+	Type   *JobType   `json:"type,omitempty" query:"type"`
+	Status *JobStatus `json:"status,omitempty" query:"status"`
+	utils.PageParams
+}
+
+// jobSorting lists what jobs can be sorted by.
+var jobSorting = utils.Sorting{
+	Columns: map[string]string{
+		"created_at":  "created_at",
+		"updated_at":  "updated_at",
+		"type":        "type",
+		"status":      "status",
+		"attempts":    "attempts",
+		"run_at":      "run_at",
+		"started_at":  "started_at",
+		"finished_at": "finished_at",
+	},
+	Default: "-created_at",
+}
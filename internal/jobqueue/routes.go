@@ -0,0 +1,15 @@
+package jobqueue
+
+import "github.com/labstack/echo/v4"
+
+// RegisterRoutes registers the job routes on the authenticated group g;
+// adminOnly guards cancelling and rerunning jobs. The stream is registered on e
+// behind streamAuth instead, which also accepts a stream token, as browser
+// EventSource cannot send the Authorization header.
+func RegisterRoutes(e *echo.Echo, g *echo.Group, queue *Queue, adminOnly, streamAuth echo.MiddlewareFunc) {
+	g.GET("/jobs", queue.GetJobsHandler)
+	g.GET("/jobs/:id", queue.GetJobHandler)
+	e.GET("/jobs/:id/stream", queue.StreamJobHandler, streamAuth)
+	g.POST("/jobs/:id/cancel", queue.CancelJobHandler, adminOnly)
+	g.POST("/jobs/:id/rerun", queue.RerunJobHandler, adminOnly)
+}
@@ -0,0 +1,232 @@
+package jobqueue
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Stream event names.
+const (
+	EventOutput = "output" // text the job produced, as a JSON string
+	EventReset  = "reset"  // the job started over; discard earlier output
+	EventStatus = "status" // the job started or was rescheduled
+	EventDone   = "done"   // the job finished; always the last event
+)
+
+// StreamEvent is one event about a running job, sent to its listeners.
+type StreamEvent struct {
+	Name string
+	Data any
+}
+
+// DoneEvent is the data of the final event of a job stream. Result is what
+// the job stored, if its type has a ResultLoader and it succeeded.
+type DoneEvent struct {
+	Job    Job `json:"job"`
+	Result any `json:"result,omitempty"`
+}
+
+// ResultLoader loads what a succeeded job stored, such as the content behind
+// its Result key.
+type ResultLoader func(ctx context.Context, job *Job) (any, error)
+
+// subscriberBuffer is how many events a listener may fall behind before it is
+// dropped. Dropped listeners reconnect and get the output replayed.
+const subscriberBuffer = 256
+
+// streams fans the output of running jobs out to listeners. Output is kept
+// until the job finishes so late listeners can catch up.
+type streams struct {
+	mu   sync.Mutex
+	jobs map[uuid.UUID]*jobStream
+}
+
+type jobStream struct {
+	output      strings.Builder
+	subscribers map[chan StreamEvent]struct{}
+}
+
+func (s *streams) get(id uuid.UUID) *jobStream {
+	if s.jobs == nil {
+		s.jobs = map[uuid.UUID]*jobStream{}
+	}
+	stream, ok := s.jobs[id]
+	if !ok {
+		stream = &jobStream{subscribers: map[chan StreamEvent]struct{}{}}
+		s.jobs[id] = stream
+	}
+	return stream
+}
+
+// send delivers event to every listener, dropping those that have fallen
+// behind. s.mu must be held.
+func (s *streams) send(stream *jobStream, event StreamEvent) {
+	for ch := range stream.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(stream.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+func (s *streams) write(id uuid.UUID, text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stream := s.get(id)
+	stream.output.WriteString(text)
+	s.send(stream, StreamEvent{Name: EventOutput, Data: text})
+}
+
+// reset discards the job's output, telling listeners to do the same.
+func (s *streams) reset(id uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stream, ok := s.jobs[id]
+	if !ok || stream.output.Len() == 0 {
+		return
+	}
+	stream.output.Reset()
+	s.send(stream, StreamEvent{Name: EventReset, Data: struct{}{}})
+}
+
+func (s *streams) publish(id uuid.UUID, event StreamEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stream, ok := s.jobs[id]; ok {
+		s.send(stream, event)
+	}
+}
+
+func (s *streams) listening(id uuid.UUID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stream, ok := s.jobs[id]
+	return ok && len(stream.subscribers) > 0
+}
+
+// finish sends the final event, if any, and ends the job's stream.
+func (s *streams) finish(id uuid.UUID, event *StreamEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stream, ok := s.jobs[id]
+	if !ok {
+		return
+	}
+	if event != nil {
+		s.send(stream, *event)
+	}
+	for ch := range stream.subscribers {
+		delete(stream.subscribers, ch)
+		close(ch)
+	}
+	delete(s.jobs, id)
+}
+
+// subscribe returns the output so far and a channel of the events after it.
+// The channel is closed when the stream ends or the listener falls behind.
+func (s *streams) subscribe(id uuid.UUID) (string, <-chan StreamEvent, func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stream := s.get(id)
+	ch := make(chan StreamEvent, subscriberBuffer)
+	stream.subscribers[ch] = struct{}{}
+	unsubscribe := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := stream.subscribers[ch]; ok {
+			delete(stream.subscribers, ch)
+			close(ch)
+		}
+		if len(stream.subscribers) == 0 && stream.output.Len() == 0 && s.jobs[id] == stream {
+			delete(s.jobs, id)
+		}
+	}
+	return stream.output.String(), ch, unsubscribe
+}
+
+// closeAll ends every stream without a final event.
+func (s *streams) closeAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, stream := range s.jobs {
+		for ch := range stream.subscribers {
+			delete(stream.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// JobOutput is a running job's live output. Everything written to it is sent
+// to the job's stream listeners.
+type JobOutput struct {
+	streams *streams
+	id      uuid.UUID
+}
+
+func (o *JobOutput) Write(p []byte) (int, error) {
+	o.streams.write(o.id, string(p))
+	return len(p), nil
+}
+
+// Reset discards what was written so far, e.g. before generating again.
+func (o *JobOutput) Reset() {
+	o.streams.reset(o.id)
+}
+
+// Output returns the live output of a job, for its handler to write to.
+func (q *Queue) Output(id uuid.UUID) *JobOutput {
+	return &JobOutput{streams: &q.streams, id: id}
+}
+
+// SetResultLoader sets how the final stream event of a job type gets the
+// job's stored result.
+func (q *Queue) SetResultLoader(jobType JobType, loader ResultLoader) {
+	q.results[jobType] = loader
+}
+
+// CloseStreams disconnects every stream listener. Call it on shutdown so open
+// streams do not hold up the server.
+func (q *Queue) CloseStreams() {
+	q.streams.closeAll()
+}
+
+func (q *Queue) doneEvent(ctx context.Context, job *Job) StreamEvent {
+	done := DoneEvent{Job: *job}
+	if loader := q.results[job.Type]; loader != nil && job.Status == JobSucceeded {
+		result, err := loader(ctx, job)
+		if err != nil {
+			log.Printf("failed to load result of job %s: %v", job.ID, err)
+		}
+		done.Result = result
+	}
+	return StreamEvent{Name: EventDone, Data: done}
+}
+
+// started tells listeners a job is running again, clearing the output of
+// any earlier attempt. Events carry a copy of the job, which is updated in
+// place once it finishes.
+func (q *Queue) started(job *Job) {
+	q.streams.reset(job.ID)
+	q.streams.publish(job.ID, StreamEvent{Name: EventStatus, Data: *job})
+}
+
+// stopped tells listeners about a job's new status, ending its stream once the
+// job has finished.
+func (q *Queue) stopped(job *Job) {
+	if !job.finished() {
+		q.streams.publish(job.ID, StreamEvent{Name: EventStatus, Data: *job})
+		return
+	}
+	if !q.streams.listening(job.ID) {
+		q.streams.finish(job.ID, nil)
+		return
+	}
+	event := q.doneEvent(context.Background(), job)
+	q.streams.finish(job.ID, &event)
+}
@@ -0,0 +1,103 @@
+package jobqueue
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// pool is the set of workers started by Start.
+type pool struct {
+	stop   context.CancelFunc // stops workers claiming new jobs
+	cancel context.CancelFunc // cancels the jobs still running
+	wg     sync.WaitGroup
+}
+
+// Start runs registered job types on a pool of workers, with concurrency[t]
+// workers for type t (one if unset). Workers sleep until a job is enqueued or
+// a retry falls due. Stop them with Shutdown.
+func (q *Queue) Start(concurrency map[JobType]int) {
+	stopCtx, stop := context.WithCancel(context.Background())
+	jobCtx, cancel := context.WithCancel(context.Background())
+	p := &pool{stop: stop, cancel: cancel}
+	q.mu.Lock()
+	q.pool = p
+	q.mu.Unlock()
+
+	for jobType := range q.handlers {
+		n := concurrency[jobType]
+		if n <= 0 {
+			n = 1
+		}
+		for range n {
+			p.wg.Add(1)
+			go func() {
+				defer p.wg.Done()
+				q.work(stopCtx, jobCtx, jobType)
+			}()
+		}
+	}
+}
+
+func (q *Queue) work(stopCtx, jobCtx context.Context, jobType JobType) {
+	wake := q.wakeChan(jobType)
+	for stopCtx.Err() == nil {
+		job, err := q.RunNext(jobCtx, jobType)
+		if err != nil {
+			log.Printf("job queue: %v", err)
+		}
+		if job != nil {
+			continue
+		}
+
+		// Nothing to run: wait for new work, the next retry or shutdown
+		var due <-chan time.Time
+		var timer *time.Timer
+		if err != nil {
+			timer = time.NewTimer(time.Second)
+		} else if next, ok := q.nextRunAt(jobType); ok {
+			timer = time.NewTimer(time.Until(next))
+		}
+		if timer != nil {
+			due = timer.C
+		}
+		select {
+		case <-stopCtx.Done():
+		case <-wake:
+		case <-due:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// Shutdown stops workers taking new jobs and waits for running jobs to
+// finish. If ctx is done first, running jobs are cancelled and requeued so
+// they run again after a restart, and ctx's error is returned.
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.mu.Lock()
+	p := q.pool
+	q.pool = nil
+	q.mu.Unlock()
+	if p == nil {
+		return nil
+	}
+
+	p.stop()
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
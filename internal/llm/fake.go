@@ -0,0 +1,36 @@
+package llm
+
+import (
+	"context"
+	"sync"
+)
+
+// Fake answers every request with the same reply without calling a model. It
+// records the requests it was given so tests can check what was asked.
+type Fake struct {
+	Reply string
+	Err   error
+
+	mu       sync.Mutex
+	requests []Request
+}
+
+func (f *Fake) Generate(ctx context.Context, req Request, fn ChunkFunc) error {
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	f.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if f.Err != nil {
+		return f.Err
+	}
+	return fn(f.Reply)
+}
+
+// Requests returns every request generated so far.
+func (f *Fake) Requests() []Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Request(nil), f.requests...)
+}
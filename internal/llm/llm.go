@@ -0,0 +1,57 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// DefaultOllamaModel is used with Ollama when no model is configured.
+const DefaultOllamaModel = "lawnqc"
+
+// Request is one completion. Model overrides the provider's configured model
+// when set. Format, when set, is a JSON schema the reply must match.
+type Request struct {
+	Model   string
+	System  string
+	Prompt  string
+	Format  json.RawMessage
+	Options map[string]any // sampling options, e.g. temperature
+}
+
+// ChunkFunc receives the reply as it is generated. Returning an error stops
+// the completion.
+type ChunkFunc func(chunk string) error
+
+// Provider streams completions from an inference host.
+type Provider interface {
+	Generate(ctx context.Context, req Request, fn ChunkFunc) error
+}
+
+// Config selects and configures an inference host.
+type Config struct {
+	Provider string // "ollama" or "openai"
+	URL      string // base URL; empty uses OLLAMA_HOST for Ollama
+	APIKey   string // bearer token for OpenAI-compatible servers, if required
+	Model    string // used when a request does not name one
+}
+
+func New(cfg Config) (Provider, error) {
+	switch cfg.Provider {
+	case "ollama":
+		if cfg.Model == "" {
+			cfg.Model = DefaultOllamaModel
+		}
+		return NewOllama(cfg.URL, cfg.Model)
+	case "openai":
+		if cfg.URL == "" {
+			return nil, errors.New("LLM server URL is not configured")
+		}
+		if cfg.Model == "" {
+			return nil, errors.New("LLM model is not configured")
+		}
+		return NewOpenAI(cfg.URL, cfg.APIKey, cfg.Model), nil
+	}
+	return nil, fmt.Errorf("unknown LLM provider %q", cfg.Provider)
+}
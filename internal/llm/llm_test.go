@@ -0,0 +1,155 @@
+package llm_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"qc_api/internal/llm"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         llm.Config
+		expectedErr string
+	}{
+		{"ollama", llm.Config{Provider: "ollama", URL: "http://localhost:11434"}, ""},
+		{"openai", llm.Config{Provider: "openai", URL: "http://localhost:8000/v1", Model: "qwen3"}, ""},
+		{"openai without url", llm.Config{Provider: "openai", Model: "qwen3"}, "URL is not configured"},
+		{"openai without model", llm.Config{Provider: "openai", URL: "http://localhost:8000/v1"}, "model is not configured"},
+		{"unknown", llm.Config{Provider: "gpt"}, "unknown LLM provider"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := llm.New(tt.cfg)
+
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, provider)
+		})
+	}
+}
+
+func TestOpenAI(t *testing.T) {
+	// Setup
+	var body map[string]any
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/chat/completions", r.URL.Path)
+		auth = r.Header.Get("Authorization")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"role\":\"assistant\"}}]}\n\n")
+		fmt.Fprint(w, ": keep-alive\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"{\\\"ok\\\":\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\" true}\"},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+	provider := llm.NewOpenAI(server.URL+"/v1/", "secret", "qwen3")
+
+	// Execution
+	var chunks []string
+	err := provider.Generate(context.Background(), llm.Request{
+		System:  "Reply in JSON",
+		Prompt:  "Are you ok?",
+		Format:  json.RawMessage(`{"type": "object"}`),
+		Options: map[string]any{"temperature": 0, "num_predict": 512, "model": "ignored"},
+	}, func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+
+	// Assertions
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"ok":`, ` true}`}, chunks)
+	assert.Equal(t, "Bearer secret", auth)
+	assert.Equal(t, "qwen3", body["model"])
+	assert.Equal(t, true, body["stream"])
+	assert.Equal(t, float64(0), body["temperature"])
+	assert.Equal(t, float64(512), body["max_tokens"])
+	assert.NotContains(t, body, "num_predict")
+	assert.Equal(t, []any{
+		map[string]any{"role": "system", "content": "Reply in JSON"},
+		map[string]any{"role": "user", "content": "Are you ok?"},
+	}, body["messages"])
+	assert.Equal(t, map[string]any{
+		"type": "json_schema",
+		"json_schema": map[string]any{
+			"name":   "response",
+			"schema": map[string]any{"type": "object"},
+			"strict": true,
+		},
+	}, body["response_format"])
+}
+
+func TestOpenAIErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		response    string
+		expectedErr string
+	}{
+		{"nested error", http.StatusNotFound, `{"error": {"message": "model qwen3 not found"}}`, "404 Not Found: model qwen3 not found"},
+		{"top level message", http.StatusBadRequest, `{"object": "error", "message": "context too long"}`, "context too long"},
+		{"plain text", http.StatusBadGateway, "upstream down", "upstream down"},
+		{"error event", http.StatusOK, "data: {\"error\": {\"message\": \"out of memory\"}}\n\n", "out of memory"},
+		{"cut off", http.StatusOK, "data: {\"choices\":[{\"delta\":{\"content\":\"{\"}}]}\n\n", "ended early"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.response)
+			}))
+			defer server.Close()
+			provider := llm.NewOpenAI(server.URL, "", "qwen3")
+
+			err := provider.Generate(context.Background(), llm.Request{Prompt: "hi"}, func(string) error { return nil })
+
+			assert.ErrorContains(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestOllama(t *testing.T) {
+	// Setup
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/generate", r.URL.Path)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		fmt.Fprintln(w, `{"response": "hel"}`)
+		fmt.Fprintln(w, `{"response": "lo"}`)
+		fmt.Fprintln(w, `{"response": "", "done": true}`)
+	}))
+	defer server.Close()
+	provider, err := llm.NewOllama(server.URL, "lawnqc")
+	require.NoError(t, err)
+
+	// Execution
+	var reply strings.Builder
+	err = provider.Generate(context.Background(), llm.Request{Model: "qwen3:8b", Prompt: "hi"}, func(chunk string) error {
+		reply.WriteString(chunk)
+		return nil
+	})
+
+	// Assertions
+	require.NoError(t, err)
+	assert.Equal(t, "hello", reply.String())
+	assert.Equal(t, "qwen3:8b", body["model"])
+	assert.Equal(t, "0s", body["keep_alive"])
+}
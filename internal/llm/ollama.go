@@ -0,0 +1,55 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/ollama/ollama/api"
+)
+
+// Ollama generates with an Ollama server through its native API. Models are
+// unloaded after each completion, as reports are too infrequent to keep one
+// in memory.
+type Ollama struct {
+	Client *api.Client
+	Model  string
+}
+
+// NewOllama connects to the Ollama server at baseURL, or at OLLAMA_HOST when
+// baseURL is empty.
+func NewOllama(baseURL, model string) (*Ollama, error) {
+	if baseURL == "" {
+		client, err := api.ClientFromEnvironment()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Ollama client: %w", err)
+		}
+		return &Ollama{Client: client, Model: model}, nil
+	}
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Ollama URL: %w", err)
+	}
+	return &Ollama{Client: api.NewClient(base, http.DefaultClient), Model: model}, nil
+}
+
+func (o *Ollama) Generate(ctx context.Context, req Request, fn ChunkFunc) error {
+	model := req.Model
+	if model == "" {
+		model = o.Model
+	}
+	return o.Client.Generate(ctx, &api.GenerateRequest{
+		Model:     model,
+		System:    req.System,
+		Prompt:    req.Prompt,
+		Format:    req.Format,
+		Options:   req.Options,
+		KeepAlive: &api.Duration{Duration: 0},
+	}, func(resp api.GenerateResponse) error {
+		if resp.Response == "" {
+			return nil
+		}
+		return fn(resp.Response)
+	})
+}
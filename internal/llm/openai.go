@@ -0,0 +1,161 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"strings"
+)
+
+// OpenAI generates with any server exposing the OpenAI chat completions API,
+// such as llama.cpp's llama-server or vLLM. URL is the API base, e.g.
+// http://localhost:8000/v1.
+type OpenAI struct {
+	URL    string
+	APIKey string
+	Model  string
+	Client *http.Client
+}
+
+func NewOpenAI(baseURL, apiKey, model string) *OpenAI {
+	return &OpenAI{URL: strings.TrimSuffix(baseURL, "/"), APIKey: apiKey, Model: model, Client: &http.Client{}}
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// chatChunk is one server-sent event of a streamed completion.
+type chatChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (o *OpenAI) Generate(ctx context.Context, req Request, fn ChunkFunc) error {
+	body, err := json.Marshal(o.chatRequest(req))
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, o.URL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+	if o.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+o.APIKey)
+	}
+	resp, err := o.Client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("LLM request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		return fmt.Errorf("LLM server returned %s: %s", resp.Status, errorMessage(detail))
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			return nil
+		}
+		var chunk chatChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("invalid LLM stream event: %w", err)
+		}
+		if chunk.Error != nil {
+			return fmt.Errorf("LLM server error: %s", chunk.Error.Message)
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content == "" {
+				continue
+			}
+			if err := fn(choice.Delta.Content); err != nil {
+				return err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("LLM stream ended early: %w", io.ErrUnexpectedEOF)
+}
+
+// chatRequest builds the request body. Options are passed through as extra
+// sampling parameters, which llama.cpp and vLLM accept, with Ollama's
+// num_predict renamed to max_tokens.
+func (o *OpenAI) chatRequest(req Request) map[string]any {
+	body := map[string]any{}
+	maps.Copy(body, req.Options)
+	if n, ok := body["num_predict"]; ok {
+		delete(body, "num_predict")
+		body["max_tokens"] = n
+	}
+
+	model := req.Model
+	if model == "" {
+		model = o.Model
+	}
+	messages := []chatMessage{}
+	if req.System != "" {
+		messages = append(messages, chatMessage{Role: "system", Content: req.System})
+	}
+	messages = append(messages, chatMessage{Role: "user", Content: req.Prompt})
+	body["model"] = model
+	body["messages"] = messages
+	body["stream"] = true
+	if len(req.Format) > 0 {
+		body["response_format"] = map[string]any{
+			"type": "json_schema",
+			"json_schema": map[string]any{
+				"name":   "response",
+				"schema": req.Format,
+				"strict": true,
+			},
+		}
+	}
+	return body
+}
+
+// errorMessage pulls the message out of an error response. llama.cpp and
+// OpenAI nest it under "error", vLLM puts it at the top level.
+func errorMessage(body []byte) string {
+	var decoded struct {
+		Error   json.RawMessage `json:"error"`
+		Message string          `json:"message"`
+	}
+	if err := json.Unmarshal(body, &decoded); err == nil {
+		var nested struct {
+			Message string `json:"message"`
+		}
+		var plain string
+		switch {
+		case json.Unmarshal(decoded.Error, &nested) == nil && nested.Message != "":
+			return nested.Message
+		case json.Unmarshal(decoded.Error, &plain) == nil && plain != "":
+			return plain
+		case decoded.Message != "":
+			return decoded.Message
+		}
+	}
+	return strings.TrimSpace(string(body))
+}
@@ -0,0 +1,38 @@
+package search
+
+import (
+	"errors"
+	"net/http"
+
+	"qc_api/internal/utils"
+
+	"github.com/labstack/echo/v4"
+)
+
+// SearchHandler godoc
+// @Summary Search
+// @Description Full-text search across inspection reports, transcripts and tasks. Every word of the query must match; hits are ranked best first and link to the resource they were found in. Matched words in snippets are wrapped in **.
+// @Tags search
+// @Produce json
+// @Param q query string true "Words to search for"
+// @Param type query string false "Only search one kind of resource (inspection, transcript, task)"
+// @Param limit query int false "Maximum number of hits, 20 by default and at most 100"
+// @Success 200 {array} Hit
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /search [get]
+func (s *SearchService) SearchHandler(c echo.Context) error {
+	var filter SearchFilter
+	if err := c.Bind(&filter); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	hits, err := s.Search(filter)
+	if errors.Is(err, ErrEmptyQuery) || errors.Is(err, ErrInvalidFilter) {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: "search failed"})
+	}
+	return c.JSON(http.StatusOK, hits)
+}
@@ -0,0 +1,144 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// indexTable holds the searchable text of every indexed resource. It is an
+// FTS5 table when SQLite has FTS5 (build with -tags sqlite_fts5), otherwise a
+// plain table searched with LIKE.
+const indexTable = "search_index"
+
+// indexedTables are kept in the index by triggers. Body lists the columns
+// whose text is searched. Generated reports are found through the report of
+// the inspection they are saved to.
+var indexedTables = []struct {
+	Type  ResourceType
+	Table string
+	Body  []string
+}{
+	{ResourceInspection, "inspections", []string{"report"}},
+	{ResourceTranscript, "transcripts", []string{"text"}},
+	{ResourceTask, "tasks", []string{"description", "notes"}},
+}
+
+// body is the SQL for the searchable text of row, e.g. NEW.
+func body(row string, columns []string) string {
+	parts := make([]string, len(columns))
+	for i, column := range columns {
+		parts[i] = "COALESCE(" + row + column + ", '')"
+	}
+	return strings.Join(parts, " || char(10) || ")
+}
+
+// Migrate creates the search index and the triggers that keep it in sync,
+// indexing existing rows when the index is new. It must be called after
+// AutoMigrate and is safe to run on every startup.
+func Migrate(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		fts, err := hasFTS5(tx)
+		if err != nil {
+			return err
+		}
+		existing, err := indexSQL(tx)
+		if err != nil {
+			return err
+		}
+		created := false
+		switch {
+		case existing == "":
+			created = true
+		case isFTS(existing) && !fts:
+			return errors.New("the search index uses FTS5, which this build of SQLite lacks; build with -tags sqlite_fts5")
+		case !isFTS(existing) && fts:
+			// Upgrade from the LIKE fallback
+			if err := tx.Exec("DROP TABLE " + indexTable).Error; err != nil {
+				return err
+			}
+			created = true
+		}
+		if created {
+			if err := createIndex(tx, fts); err != nil {
+				return err
+			}
+		}
+		if !fts {
+			log.Printf("SQLite lacks FTS5, search falls back to unranked substring matching")
+		}
+
+		for _, source := range indexedTables {
+			if err := createTriggers(tx, source.Type, source.Table, source.Body); err != nil {
+				return fmt.Errorf("failed to create search triggers on %s: %w", source.Table, err)
+			}
+			if !created {
+				continue
+			}
+			err := tx.Exec(fmt.Sprintf("INSERT INTO %s (resource_type, resource_id, body) SELECT ?, id, %s FROM %s WHERE deleted_at IS NULL",
+				indexTable, body("", source.Body), source.Table), source.Type).Error
+			if err != nil {
+				return fmt.Errorf("failed to index %s: %w", source.Table, err)
+			}
+		}
+		return nil
+	})
+}
+
+func hasFTS5(db *gorm.DB) (bool, error) {
+	var used bool
+	err := db.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&used).Error
+	return used, err
+}
+
+// indexSQL returns the statement that created the index, or "" if there is none.
+func indexSQL(db *gorm.DB) (string, error) {
+	var statements []string
+	err := db.Raw("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?", indexTable).Scan(&statements).Error
+	if err != nil || len(statements) == 0 {
+		return "", err
+	}
+	return statements[0], nil
+}
+
+func isFTS(statement string) bool {
+	return strings.Contains(strings.ToLower(statement), "using fts5")
+}
+
+func createIndex(tx *gorm.DB, fts bool) error {
+	if fts {
+		return tx.Exec("CREATE VIRTUAL TABLE " + indexTable + " USING fts5(resource_type UNINDEXED, resource_id UNINDEXED, body, tokenize = 'porter unicode61 remove_diacritics 2')").Error
+	}
+	if err := tx.Exec("CREATE TABLE " + indexTable + " (resource_type TEXT NOT NULL, resource_id TEXT NOT NULL, body TEXT NOT NULL)").Error; err != nil {
+		return err
+	}
+	return tx.Exec("CREATE INDEX idx_search_index_resource ON " + indexTable + " (resource_type, resource_id)").Error
+}
+
+// createTriggers indexes rows of table as they are inserted, updated and
+// deleted. Soft deleted rows drop out of the index. Triggers are recreated so
+// changes to the indexed columns take effect.
+func createTriggers(tx *gorm.DB, resourceType ResourceType, table string, columns []string) error {
+	name := "search_" + table
+	remove := fmt.Sprintf("DELETE FROM %s WHERE resource_type = '%s' AND resource_id = OLD.id;", indexTable, resourceType)
+	insert := fmt.Sprintf("INSERT INTO %s (resource_type, resource_id, body) SELECT '%s', NEW.id, %s WHERE NEW.deleted_at IS NULL;",
+		indexTable, resourceType, body("NEW.", columns))
+	statements := []string{
+		"DROP TRIGGER IF EXISTS " + name + "_insert",
+		"DROP TRIGGER IF EXISTS " + name + "_update",
+		"DROP TRIGGER IF EXISTS " + name + "_delete",
+		fmt.Sprintf("CREATE TRIGGER %s_insert AFTER INSERT ON %s BEGIN %s END", name, table, insert),
+		fmt.Sprintf("CREATE TRIGGER %s_update AFTER UPDATE OF %s, deleted_at ON %s BEGIN %s %s END",
+			name, strings.Join(columns, ", "), table, remove, insert),
+		fmt.Sprintf("CREATE TRIGGER %s_delete AFTER DELETE ON %s BEGIN %s END", name, table, remove),
+	}
+	for _, statement := range statements {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package search
+
+import "github.com/google/uuid"
+
+type ResourceType string
+
+const (
+	ResourceInspection ResourceType = "inspection"
+	ResourceTranscript ResourceType = "transcript"
+	ResourceTask       ResourceType = "task"
+)
+
+// resourceLinks is where the API serves each indexed resource.
+var resourceLinks = map[ResourceType]string{
+	ResourceInspection: "/inspections/%s",
+	ResourceTranscript: "/transcripts/%s",
+	ResourceTask:       "/tasks/%s",
+}
+
+// Hit is one search result. Matched terms in Snippet are wrapped in **. Rank
+// orders hits, lower is better.
+type Hit struct {
+	Type    ResourceType `json:"type"`
+	ID      uuid.UUID    `json:"id"`
+	Link    string       `json:"link"`
+	Snippet string       `json:"snippet"`
+	Rank    float64      `json:"rank"`
+}
+
+type SearchFilter struct {
+	Query string        `json:"q" query:"q"`
+	Type  *ResourceType `json:"type,omitempty" query:"type"`
+	Limit int           `json:"limit,omitempty" query:"limit"` //defaults to DefaultLimit, at most MaxLimit
+}
@@ -0,0 +1,7 @@
+package search
+
+import "github.com/labstack/echo/v4"
+
+func RegisterRoutes(g *echo.Group, searchService *SearchService) {
+	g.GET("/search", searchService.SearchHandler)
+}
@@ -0,0 +1,185 @@
+package search_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	inspectionproperties "qc_api/internal/inspectionProperties"
+	"qc_api/internal/inspections"
+	"qc_api/internal/search"
+	"qc_api/internal/tasks"
+	"qc_api/internal/transcripts"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB() *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		panic("failed to connect database")
+	}
+	var models []any
+	models = append(models, inspections.Models()...)
+	models = append(models, inspectionproperties.Models()...)
+	models = append(models, tasks.Models()...)
+	models = append(models, transcripts.Models()...)
+	if err := db.AutoMigrate(models...); err != nil {
+		panic("failed to migrate database")
+	}
+	if err := inspections.Migrate(db); err != nil {
+		panic("failed to run inspection migrations: " + err.Error())
+	}
+	return db
+}
+
+func setupService(db *gorm.DB) *search.SearchService {
+	if err := search.Migrate(db); err != nil {
+		panic("failed to create search index: " + err.Error())
+	}
+	return search.NewSearchService(db)
+}
+
+func hitIDs(hits []search.Hit) []uuid.UUID {
+	ids := []uuid.UUID{}
+	for _, hit := range hits {
+		ids = append(ids, hit.ID)
+	}
+	return ids
+}
+
+func TestSearchStaysInSync(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	service := setupService(db)
+	inspectionService := inspections.NewInspectionService(db)
+	inspection, err := inspectionService.CreateInspection(&inspections.Inspection{Report: "Tech forgot the spill kit at the Elm St. job", EmployeeID: uuid.New()})
+	require.NoError(t, err)
+	task := &tasks.Task{Description: "Restock truck", Notes: "Spill kits ordered for every truck"}
+	require.NoError(t, tasks.NewTaskService(db).CreateTask(task))
+	transcript := &transcripts.Transcript{UploadID: uuid.New(), InspectionID: inspection.ID, Text: "no spill kit on the truck at Elm street"}
+	require.NoError(t, transcripts.NewTranscriptService(db).SaveTranscript(transcript))
+
+	// Every resource is found, linked to where the API serves it
+	hits, err := service.Search(search.SearchFilter{Query: "spill kit"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uuid.UUID{inspection.ID, task.ID, transcript.ID}, hitIDs(hits))
+	links := map[search.ResourceType]string{}
+	for _, hit := range hits {
+		links[hit.Type] = hit.Link
+	}
+	assert.Equal(t, map[search.ResourceType]string{
+		search.ResourceInspection: "/inspections/" + inspection.ID.String(),
+		search.ResourceTask:       "/tasks/" + task.ID.String(),
+		search.ResourceTranscript: "/transcripts/" + transcript.ID.String(),
+	}, links)
+
+	// Every word must match, punctuation is ignored
+	hits, err = service.Search(search.SearchFilter{Query: `"Elm St." spill`})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uuid.UUID{inspection.ID, transcript.ID}, hitIDs(hits))
+
+	// Filtering by type
+	resourceType := search.ResourceTask
+	hits, err = service.Search(search.SearchFilter{Query: "spill", Type: &resourceType})
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{task.ID}, hitIDs(hits))
+
+	// Updates replace what was indexed
+	report2 := "Spreader calibrated, all clear"
+	_, err = inspectionService.UpdateInspection(inspection.ID, inspections.InspectionPatch{Report: &report2})
+	require.NoError(t, err)
+	hits, err = service.Search(search.SearchFilter{Query: "spill"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uuid.UUID{task.ID, transcript.ID}, hitIDs(hits))
+	hits, err = service.Search(search.SearchFilter{Query: "spreader"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uuid.UUID{inspection.ID}, hitIDs(hits))
+
+	// Deleted resources drop out, whether soft or hard deleted
+	require.NoError(t, inspectionService.DeleteInspection(inspection.ID))
+	require.NoError(t, db.Unscoped().Delete(&tasks.Task{}, "id = ?", task.ID).Error)
+	hits, err = service.Search(search.SearchFilter{Query: "spill spreader"})
+	require.NoError(t, err)
+	assert.Empty(t, hits)
+	hits, err = service.Search(search.SearchFilter{Query: "truck"})
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{transcript.ID}, hitIDs(hits))
+}
+
+func TestSearchMigrate(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	inspection, err := inspections.NewInspectionService(db).CreateInspection(&inspections.Inspection{Report: "Drift noted on the east lawn", EmployeeID: uuid.New()})
+	require.NoError(t, err)
+
+	// Existing rows are indexed once, however often the migration runs
+	setupService(db)
+	service := setupService(db)
+	hits, err := service.Search(search.SearchFilter{Query: "drift"})
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{inspection.ID}, hitIDs(hits))
+	assert.Contains(t, hits[0].Snippet, "**Drift**")
+}
+
+func TestSearchRanking(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	service := setupService(db)
+	if !service.FTS {
+		t.Skip("ranking needs FTS5, run with -tags sqlite_fts5")
+	}
+	inspectionService := inspections.NewInspectionService(db)
+	repeated, err := inspectionService.CreateInspection(&inspections.Inspection{Report: "Calibration fine. Weeds near the fence, weeds by the drive, weeds everywhere.", EmployeeID: uuid.New()})
+	require.NoError(t, err)
+	focused, err := inspectionService.CreateInspection(&inspections.Inspection{Report: "Weeds spilling onto the sidewalk.", EmployeeID: uuid.New()})
+	require.NoError(t, err)
+
+	// Execution
+	hits, err := service.Search(search.SearchFilter{Query: "weed"})
+
+	// Assertions
+	require.NoError(t, err)
+	require.Len(t, hits, 2)
+	assert.Equal(t, []uuid.UUID{repeated.ID, focused.ID}, hitIDs(hits))
+	assert.Less(t, hits[0].Rank, hits[1].Rank)
+	assert.Contains(t, hits[0].Snippet, "**Weeds**")
+}
+
+func TestSearchHandler(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	service := setupService(db)
+	e := echo.New()
+	_, err := inspections.NewInspectionService(db).CreateInspection(&inspections.Inspection{Report: "PPE worn, spill kit present", EmployeeID: uuid.New()})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		query        string
+		expectedCode int
+	}{
+		{"valid", "q=spill&type=inspection&limit=5", http.StatusOK},
+		{"no words", "q=%22%3F%22", http.StatusBadRequest},
+		{"missing query", "", http.StatusBadRequest},
+		{"unknown type", "q=spill&type=employee", http.StatusBadRequest},
+		{"limit too high", "q=spill&limit=1000", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/search?"+tt.query, nil)
+			rec := httptest.NewRecorder()
+
+			err := service.SearchHandler(e.NewContext(req, rec))
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedCode, rec.Code, rec.Body.String())
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrEmptyQuery = errors.New("search query has no words")
+var ErrInvalidFilter = errors.New("invalid search filter")
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+
+	snippetTokens  = 12 // words of context in FTS snippets
+	snippetContext = 60 // bytes either side of the first match in fallback snippets
+)
+
+type SearchService struct {
+	DB  *gorm.DB
+	FTS bool // whether the index is an FTS5 table, see Migrate
+}
+
+// NewSearchService searches the index created by Migrate, which must run first.
+func NewSearchService(db *gorm.DB) *SearchService {
+	statement, err := indexSQL(db)
+	if err != nil {
+		log.Printf("failed to inspect search index: %v", err)
+	}
+	return &SearchService{DB: db, FTS: isFTS(statement)}
+}
+
+// queryTerms splits a query into the words it searches for, the way the FTS
+// tokenizer does, so punctuation in user input is never parsed as syntax.
+func queryTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// === Search ===
+
+type indexRow struct {
+	ResourceType ResourceType
+	ResourceID   uuid.UUID
+	Body         string
+	Snippet      string
+	Rank         float64
+}
+
+// Search returns the resources containing every word of the query, best
+// matches first. Words match as prefixes, and with FTS5 also by stem, so
+// "spill" finds "spills" and "spilled". Without FTS5 hits are unranked and
+// newest first.
+func (s *SearchService) Search(filter SearchFilter) ([]Hit, error) {
+	terms := queryTerms(filter.Query)
+	if len(terms) == 0 {
+		return nil, ErrEmptyQuery
+	}
+	if filter.Type != nil && resourceLinks[*filter.Type] == "" {
+		return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidFilter, *filter.Type)
+	}
+	limit := filter.Limit
+	switch {
+	case limit < 0 || limit > MaxLimit:
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidFilter, MaxLimit)
+	case limit == 0:
+		limit = DefaultLimit
+	}
+
+	var rows []indexRow
+	var err error
+	if s.FTS {
+		rows, err = s.searchFTS(terms, filter.Type, limit)
+	} else {
+		rows, err = s.searchLike(terms, filter.Type, limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]Hit, len(rows))
+	for i, row := range rows {
+		hits[i] = Hit{
+			Type:    row.ResourceType,
+			ID:      row.ResourceID,
+			Link:    fmt.Sprintf(resourceLinks[row.ResourceType], row.ResourceID),
+			Snippet: row.Snippet,
+			Rank:    row.Rank,
+		}
+	}
+	return hits, nil
+}
+
+func (s *SearchService) searchFTS(terms []string, resourceType *ResourceType, limit int) ([]indexRow, error) {
+	match := make([]string, len(terms))
+	for i, term := range terms {
+		match[i] = `"` + term + `"*`
+	}
+	query := s.DB.Table(indexTable).
+		Select("resource_type, resource_id, snippet("+indexTable+", 2, '**', '**', '…', ?) AS snippet, bm25("+indexTable+") AS rank", snippetTokens).
+		Where(indexTable+" MATCH ?", strings.Join(match, " "))
+	if resourceType != nil {
+		query = query.Where("resource_type = ?", *resourceType)
+	}
+	var rows []indexRow
+	err := query.Order("rank").Limit(limit).Scan(&rows).Error
+	return rows, err
+}
+
+func (s *SearchService) searchLike(terms []string, resourceType *ResourceType, limit int) ([]indexRow, error) {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	query := s.DB.Table(indexTable).Select("resource_type, resource_id, body")
+	for _, term := range terms {
+		query = query.Where(`body LIKE ? ESCAPE '\'`, "%"+replacer.Replace(term)+"%")
+	}
+	if resourceType != nil {
+		query = query.Where("resource_type = ?", *resourceType)
+	}
+	var rows []indexRow
+	if err := query.Order("rowid DESC").Limit(limit).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		rows[i].Snippet = likeSnippet(rows[i].Body, terms)
+	}
+	return rows, nil
+}
+
+// likeSnippet cuts the text around the first match out of body and marks
+// every match in it like the FTS snippets.
+func likeSnippet(body string, terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = regexp.QuoteMeta(term)
+	}
+	re := regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+	start, end := 0, min(len(body), 2*snippetContext)
+	if first := re.FindStringIndex(body); first != nil {
+		start, end = max(0, first[0]-snippetContext), min(len(body), first[1]+snippetContext)
+	}
+	for start > 0 && !utf8.RuneStart(body[start]) {
+		start--
+	}
+	for end < len(body) && !utf8.RuneStart(body[end]) {
+		end++
+	}
+	snippet := re.ReplaceAllString(body[start:end], "**$0**")
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(body) {
+		snippet += "…"
+	}
+	return snippet
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var ErrNotFound = errors.New("object not found")
+var ErrInvalidKey = errors.New("invalid storage key")
+
+// Storage stores opaque objects under slash separated keys. Keys are
+// generated by the server (see NewKey) and validated by every backend, so
+// client supplied names never reach a filesystem path or object URL.
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Get returns ErrNotFound if no object exists under key.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete does not fail if the object is already gone.
+	Delete(ctx context.Context, key string) error
+	Exists(ctx context.Context, key string) (bool, error)
+	// List returns the keys starting with prefix, in lexical order.
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+var keySegment = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ValidateKey rejects keys that are empty, absolute, or contain anything other
+// than letters, digits, '.', '_' and '-' between slashes. Segments may not
+// start with a dot, which rules out "." and "..".
+func ValidateKey(key string) error {
+	if key == "" || len(key) > 512 {
+		return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if !keySegment.MatchString(segment) {
+			return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+		}
+	}
+	return nil
+}
+
+var extension = regexp.MustCompile(`^\.[A-Za-z0-9]{1,10}$`)
+
+// NewKey returns a fresh random key under prefix, keeping filename's extension
+// when it is a plain one like ".m4a".
+func NewKey(prefix, filename string) string {
+	key := path.Join(prefix, uuid.NewString())
+	if ext := path.Ext(strings.ReplaceAll(filename, "\\", "/")); extension.MatchString(ext) {
+		key += strings.ToLower(ext)
+	}
+	return key
+}
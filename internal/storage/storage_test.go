@@ -0,0 +1,254 @@
+package storage_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"qc_api/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 is a minimal in-memory S3 server with path-style addressing. It
+// checks that requests are signed and that PUT bodies match the signed hash.
+type fakeS3 struct {
+	mu      sync.Mutex
+	bucket  string
+	objects map[string][]byte
+	pageLen int
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=test-key/") ||
+		!strings.Contains(auth, "SignedHeaders=host;x-amz-content-sha256;x-amz-date") ||
+		r.Header.Get("X-Amz-Date") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, "<Error><Code>AccessDenied</Code><Message>unsigned</Message></Error>")
+		return
+	}
+	rest, ok := strings.CutPrefix(r.URL.Path, "/"+f.bucket)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	key := strings.TrimPrefix(rest, "/")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		f.list(w, r)
+	case r.Method == http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		sum := sha256.Sum256(body)
+		if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, "<Error><Code>XAmzContentSHA256Mismatch</Code><Message>bad hash</Message></Error>")
+			return
+		}
+		f.objects[key] = body
+	case r.Method == http.MethodGet, r.Method == http.MethodHead:
+		body, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodGet {
+			w.Write(body)
+		}
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	keys := []string{}
+	for k := range f.objects {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	// Continuation tokens are simply the index of the next key
+	start := 0
+	if token := r.URL.Query().Get("continuation-token"); token != "" {
+		for i, k := range keys {
+			if k == token {
+				start = i
+			}
+		}
+	}
+	type content struct {
+		Key string `xml:"Key"`
+	}
+	result := struct {
+		XMLName               xml.Name  `xml:"ListBucketResult"`
+		Contents              []content `xml:"Contents"`
+		IsTruncated           bool      `xml:"IsTruncated"`
+		NextContinuationToken string    `xml:"NextContinuationToken,omitempty"`
+	}{}
+	end := min(start+f.pageLen, len(keys))
+	for _, k := range keys[start:end] {
+		result.Contents = append(result.Contents, content{Key: k})
+	}
+	if end < len(keys) {
+		result.IsTruncated = true
+		result.NextContinuationToken = keys[end]
+	}
+	xml.NewEncoder(w).Encode(result)
+}
+
+func newFakeS3(t *testing.T) *storage.S3 {
+	server := httptest.NewServer(&fakeS3{bucket: "qc", objects: map[string][]byte{}, pageLen: 2})
+	t.Cleanup(server.Close)
+	s3, err := storage.NewS3(storage.S3Config{
+		Endpoint:  server.URL,
+		Bucket:    "qc",
+		AccessKey: "test-key",
+		SecretKey: "test-secret",
+	})
+	require.NoError(t, err)
+	return s3
+}
+
+// testStorage runs the behaviour every backend must share
+func testStorage(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	t.Run("Put and get", func(t *testing.T) {
+		require.NoError(t, s.Put(ctx, "uploads/a.m4a", strings.NewReader("audio")))
+		r, err := s.Get(ctx, "uploads/a.m4a")
+		require.NoError(t, err)
+		defer r.Close()
+		data, _ := io.ReadAll(r)
+		assert.Equal(t, "audio", string(data))
+
+		ok, err := s.Exists(ctx, "uploads/a.m4a")
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("Put overwrites", func(t *testing.T) {
+		require.NoError(t, s.Put(ctx, "uploads/a.m4a", strings.NewReader("newer")))
+		r, err := s.Get(ctx, "uploads/a.m4a")
+		require.NoError(t, err)
+		defer r.Close()
+		data, _ := io.ReadAll(r)
+		assert.Equal(t, "newer", string(data))
+	})
+
+	t.Run("Missing object", func(t *testing.T) {
+		_, err := s.Get(ctx, "uploads/missing.m4a")
+		assert.True(t, errors.Is(err, storage.ErrNotFound))
+		ok, err := s.Exists(ctx, "uploads/missing.m4a")
+		require.NoError(t, err)
+		assert.False(t, ok)
+		assert.NoError(t, s.Delete(ctx, "uploads/missing.m4a"))
+	})
+
+	t.Run("List by prefix", func(t *testing.T) {
+		for _, key := range []string{"reports/b.pdf", "reports/a.pdf", "reports/c.pdf", "transcriptions/a.txt"} {
+			require.NoError(t, s.Put(ctx, key, strings.NewReader(key)))
+		}
+		keys, err := s.List(ctx, "reports/")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"reports/a.pdf", "reports/b.pdf", "reports/c.pdf"}, keys)
+
+		keys, err = s.List(ctx, "nothing/")
+		require.NoError(t, err)
+		assert.Empty(t, keys)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		require.NoError(t, s.Delete(ctx, "reports/b.pdf"))
+		ok, err := s.Exists(ctx, "reports/b.pdf")
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("Invalid keys", func(t *testing.T) {
+		for _, key := range []string{"", "/etc/passwd", "../secret", "uploads/../../secret", "uploads/.hidden", "uploads//a", "a b", `uploads\a`} {
+			err := s.Put(ctx, key, strings.NewReader("x"))
+			assert.True(t, errors.Is(err, storage.ErrInvalidKey), "key %q", key)
+			_, err = s.Get(ctx, key)
+			assert.True(t, errors.Is(err, storage.ErrInvalidKey), "key %q", key)
+		}
+	})
+}
+
+func TestLocalStorage(t *testing.T) {
+	root := t.TempDir()
+	s, err := storage.NewLocal(root)
+	require.NoError(t, err)
+	testStorage(t, s)
+
+	// Nothing may escape the root or linger as a temp file
+	entries, err := os.ReadDir(root)
+	require.NoError(t, err)
+	for _, e := range entries {
+		assert.False(t, strings.HasPrefix(e.Name(), "."), e.Name())
+	}
+}
+
+func TestS3Storage(t *testing.T) {
+	testStorage(t, newFakeS3(t))
+}
+
+func TestS3StorageRejectsBadCredentials(t *testing.T) {
+	server := httptest.NewServer(&fakeS3{bucket: "qc", objects: map[string][]byte{}, pageLen: 2})
+	defer server.Close()
+	s, err := storage.NewS3(storage.S3Config{Endpoint: server.URL, Bucket: "qc", AccessKey: "other", SecretKey: "x"})
+	require.NoError(t, err)
+
+	err = s.Put(context.Background(), "uploads/a.m4a", strings.NewReader("audio"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "AccessDenied")
+}
+
+// TestS3StorageLive runs the same checks against a real S3 compatible
+// server such as MinIO when STORAGE_TEST_S3_ENDPOINT is set.
+func TestS3StorageLive(t *testing.T) {
+	endpoint := os.Getenv("STORAGE_TEST_S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("STORAGE_TEST_S3_ENDPOINT not set")
+	}
+	s, err := storage.NewS3(storage.S3Config{
+		Endpoint:  endpoint,
+		Bucket:    os.Getenv("STORAGE_TEST_S3_BUCKET"),
+		Region:    os.Getenv("STORAGE_TEST_S3_REGION"),
+		AccessKey: os.Getenv("STORAGE_TEST_S3_ACCESS_KEY_ID"),
+		SecretKey: os.Getenv("STORAGE_TEST_S3_SECRET_ACCESS_KEY"),
+	})
+	require.NoError(t, err)
+	testStorage(t, s)
+}
+
+func TestNewKey(t *testing.T) {
+	key := storage.NewKey("uploads", "../../Evil Name.M4A")
+	assert.NoError(t, storage.ValidateKey(key))
+	assert.True(t, strings.HasPrefix(key, "uploads/"))
+	assert.True(t, strings.HasSuffix(key, ".m4a"))
+	assert.NotContains(t, key, "Evil")
+
+	key = storage.NewKey("uploads", "audio.m4a;rm -rf")
+	assert.NoError(t, storage.ValidateKey(key))
+	assert.NotContains(t, key, ";")
+}
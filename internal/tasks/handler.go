@@ -1 +1,432 @@
 package tasks
+
+import (
+	"errors"
+	"net/http"
+	"qc_api/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+func taskErrorResponse(c echo.Context, err error, notFound string) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: notFound})
+	case errors.Is(err, ErrUnknownAssignee), errors.Is(err, ErrUnknownChecklistKey), errors.Is(err, ErrInvalidRule):
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, ErrInvalidTransition):
+		return c.JSON(http.StatusConflict, utils.ErrorResponse{Error: err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+}
+
+// === Inspection Tasks ===
+
+// PostInspectionTaskHandler godoc
+// @Summary Raise a corrective action from an inspection
+// @Description Create a task linked to an inspection, optionally naming the failed checklist item it corrects
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param id path string true "Inspection ID"
+// @Param task body TaskDTO true "Task data"
+// @Success 201 {object} Task
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /inspections/{id}/tasks [post]
+func (s *TaskService) PostInspectionTaskHandler(c echo.Context) error {
+	inspectionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid inspection id"})
+	}
+	var taskDTO TaskDTO
+	if err := c.Bind(&taskDTO); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "Invalid request body"})
+	}
+	if err := c.Validate(&taskDTO); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+
+	task := &Task{
+		Description:  taskDTO.Description,
+		Notes:        taskDTO.Notes,
+		InspectionID: &inspectionID,
+		ChecklistKey: taskDTO.ChecklistKey,
+		AssigneeID:   taskDTO.AssigneeID,
+		Priority:     taskDTO.Priority,
+	}
+	if taskDTO.DueDate != nil {
+		task.DueDate = &taskDTO.DueDate.Time
+	}
+
+	if err := s.CreateTask(task); err != nil {
+		return taskErrorResponse(c, err, "inspection not found")
+	}
+	return c.JSON(http.StatusCreated, task)
+}
+
+// GetInspectionTasksHandler godoc
+// @Summary Get tasks raised from an inspection
+// @Description Retrieve the corrective actions linked to an inspection
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param id path string true "Inspection ID"
+// @Param limit query int false "Page size (default 50, at most 200)"
+// @Param offset query int false "Rows to skip"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort keys, - for descending (e.g. -created_at,due_date)"
+// @Param fields query string false "Comma separated fields to return (e.g. id,description)"
+// @Success 200 {object} utils.Page[Task]
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /inspections/{id}/tasks [get]
+func (s *TaskService) GetInspectionTasksHandler(c echo.Context) error {
+	inspectionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid inspection id"})
+	}
+	var params utils.PageParams
+	if err := c.Bind(&params); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	tasks, err := s.GetTasks(TaskFilter{InspectionID: &inspectionID, PageParams: params})
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidQuery) {
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: "failed to retrieve tasks"})
+	}
+	return c.JSON(http.StatusOK, tasks)
+}
+
+// === Tasks ===
+
+// GetTasksHandler godoc
+// @Summary Get tasks
+// @Description Retrieve corrective actions with optional filtering, by default soonest due first
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param inspection_id query string false "Filter by inspection ID (UUID)"
+// @Param assignee_id query string false "Filter by assigned employee ID (UUID)"
+// @Param status query string false "Filter by status (open, in_progress, verified, closed)"
+// @Param priority query string false "Filter by priority (low, medium, high, urgent)"
+// @Param due_from query string false "Filter by due date from (YYYY-MM-DD)"
+// @Param due_to query string false "Filter by due date to (YYYY-MM-DD)"
+// @Param overdue query boolean false "Only tasks past due that are not verified or closed"
+// @Param limit query int false "Page size (default 50, at most 200)"
+// @Param offset query int false "Rows to skip"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort keys, - for descending (e.g. -created_at,due_date)"
+// @Param fields query string false "Comma separated fields to return (e.g. id,description)"
+// @Success 200 {object} utils.Page[Task]
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /tasks [get]
+func (s *TaskService) GetTasksHandler(c echo.Context) error {
+	var filter TaskFilter
+	if err := c.Bind(&filter); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	tasks, err := s.GetTasks(filter)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidQuery) {
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: "failed to retrieve tasks"})
+	}
+	return c.JSON(http.StatusOK, tasks)
+}
+
+// GetTaskHandler godoc
+// @Summary Get task by ID
+// @Description Retrieve a task with its comments
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param id path string true "Task ID"
+// @Success 200 {object} Task
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /tasks/{id} [get]
+func (s *TaskService) GetTaskHandler(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid task id"})
+	}
+	task, err := s.GetTaskByID(id)
+	if err != nil {
+		return taskErrorResponse(c, err, "task not found")
+	}
+	if task.Comments == nil {
+		task.Comments = []TaskComment{}
+	}
+	return c.JSON(http.StatusOK, task)
+}
+
+// PatchTaskHandler godoc
+// @Summary Update task by ID
+// @Description Update the description, notes, assignee, due date or priority of a task
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param id path string true "Task ID"
+// @Param task body TaskPatch true "Task update data"
+// @Success 200 {object} Task
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /tasks/{id} [patch]
+func (s *TaskService) PatchTaskHandler(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid task id"})
+	}
+	var patch TaskPatch
+	if err := c.Bind(&patch); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	if err := c.Validate(&patch); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	task, err := s.UpdateTask(id, patch)
+	if err != nil {
+		return taskErrorResponse(c, err, "task not found")
+	}
+	return c.JSON(http.StatusOK, task)
+}
+
+// PatchTaskStatusHandler godoc
+// @Summary Change task status
+// @Description Move a task through open -> in_progress -> verified -> closed. Open tasks may be closed directly and closed tasks reopened.
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param id path string true "Task ID"
+// @Param status body TaskStatusDTO true "Target status"
+// @Success 200 {object} Task
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /tasks/{id}/status [patch]
+func (s *TaskService) PatchTaskStatusHandler(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid task id"})
+	}
+	var statusDTO TaskStatusDTO
+	if err := c.Bind(&statusDTO); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid JSON"})
+	}
+	if err := c.Validate(&statusDTO); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	userID, _ := c.Get("user_id").(uuid.UUID)
+
+	task, err := s.TransitionTask(id, statusDTO.Status, userID)
+	if err != nil {
+		return taskErrorResponse(c, err, "task not found")
+	}
+	return c.JSON(http.StatusOK, task)
+}
+
+// DeleteTaskHandler godoc
+// @Summary Delete task by ID
+// @Description Delete a task
+// @Tags tasks
+// @Param id path string true "Task ID"
+// @Success 204
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /tasks/{id} [delete]
+func (s *TaskService) DeleteTaskHandler(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid task id"})
+	}
+	if err := s.DeleteTask(id); err != nil {
+		return taskErrorResponse(c, err, "task not found")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// === Comments ===
+
+// PostTaskCommentHandler godoc
+// @Summary Comment on a task
+// @Description Add a comment to a task as the authenticated user
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param id path string true "Task ID"
+// @Param comment body TaskCommentDTO true "Comment"
+// @Success 201 {object} TaskComment
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /tasks/{id}/comments [post]
+func (s *TaskService) PostTaskCommentHandler(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid task id"})
+	}
+	var commentDTO TaskCommentDTO
+	if err := c.Bind(&commentDTO); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "Invalid request body"})
+	}
+	if err := c.Validate(&commentDTO); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	authorID, _ := c.Get("user_id").(uuid.UUID)
+
+	comment := &TaskComment{TaskID: id, AuthorID: authorID, Body: commentDTO.Body}
+	if err := s.CreateComment(comment); err != nil {
+		return taskErrorResponse(c, err, "task not found")
+	}
+	return c.JSON(http.StatusCreated, comment)
+}
+
+// === Rules ===
+
+// GetRulesHandler godoc
+// @Summary Get corrective action rules
+// @Description Retrieve the rules that raise tasks automatically from failed inspections, by default oldest first
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param trigger query string false "Filter by trigger (checklist_failed, calibration_out_of_range)"
+// @Param active query bool false "Filter by whether the rule is active"
+// @Param limit query int false "Page size (default 50, at most 200)"
+// @Param offset query int false "Rows to skip"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort keys, - for descending (e.g. name,-created_at)"
+// @Param fields query string false "Comma separated fields to return (e.g. id,name,active)"
+// @Success 200 {object} utils.Page[CorrectiveActionRule]
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /taskrules [get]
+func (s *TaskService) GetRulesHandler(c echo.Context) error {
+	var filter CorrectiveActionRuleFilter
+	if err := c.Bind(&filter); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+
+	rules, err := s.ReadRules(filter)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidQuery) {
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+	}
+	return c.JSON(http.StatusOK, rules)
+}
+
+// PostRuleHandler godoc
+// @Summary Create a corrective action rule
+// @Description Create a rule that raises a task for the inspected employee when a checklist item fails (checklist_failed) or calibration is outside min/max (calibration_out_of_range). The task is closed when a later inspection passes. description_template is a Go template with .Rule, .Employee, .InspectionID, .ChecklistKey, .Calibration and .DueDate.
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param rule body CorrectiveActionRuleDTO true "Rule data"
+// @Success 201 {object} CorrectiveActionRule
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /taskrules [post]
+func (s *TaskService) PostRuleHandler(c echo.Context) error {
+	var ruleDTO CorrectiveActionRuleDTO
+	if err := c.Bind(&ruleDTO); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "Invalid request body"})
+	}
+	if err := c.Validate(&ruleDTO); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+
+	rule := &CorrectiveActionRule{
+		Name:                ruleDTO.Name,
+		Trigger:             ruleDTO.Trigger,
+		ChecklistKey:        ruleDTO.ChecklistKey,
+		MinCalibration:      ruleDTO.MinCalibration,
+		MaxCalibration:      ruleDTO.MaxCalibration,
+		DescriptionTemplate: ruleDTO.DescriptionTemplate,
+		DueInDays:           ruleDTO.DueInDays,
+		Priority:            ruleDTO.Priority,
+		Active:              ruleDTO.Active == nil || *ruleDTO.Active,
+	}
+
+	if err := s.CreateRule(rule); err != nil {
+		return taskErrorResponse(c, err, "rule not found")
+	}
+	return c.JSON(http.StatusCreated, rule)
+}
+
+// PatchRuleHandler godoc
+// @Summary Update a corrective action rule
+// @Description Update specific fields of a corrective action rule
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param id path string true "Rule ID"
+// @Param rule body CorrectiveActionRulePatch true "Rule update data"
+// @Success 200 {object} CorrectiveActionRule
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /taskrules/{id} [patch]
+func (s *TaskService) PatchRuleHandler(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid rule id"})
+	}
+	var patch CorrectiveActionRulePatch
+	if err := c.Bind(&patch); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	if err := c.Validate(&patch); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	rule, err := s.UpdateRule(id, patch)
+	if err != nil {
+		return taskErrorResponse(c, err, "rule not found")
+	}
+	return c.JSON(http.StatusOK, rule)
+}
+
+// DeleteRuleHandler godoc
+// @Summary Delete a corrective action rule
+// @Description Delete a rule. Tasks it already raised are kept.
+// @Tags tasks
+// @Param id path string true "Rule ID"
+// @Success 204
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /taskrules/{id} [delete]
+func (s *TaskService) DeleteRuleHandler(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid rule id"})
+	}
+	if err := s.DeleteRule(id); err != nil {
+		return taskErrorResponse(c, err, "rule not found")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
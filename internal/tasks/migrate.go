@@ -0,0 +1,43 @@
+package tasks
+
+import (
+	"qc_api/internal/inspections"
+
+	"gorm.io/gorm"
+)
+
+var defaultRules = []CorrectiveActionRule{
+	{
+		Name:                "PPE not in good condition",
+		Trigger:             TriggerChecklistFailed,
+		ChecklistKey:        inspections.KeyUniformPPEGood,
+		DescriptionTemplate: "Replace or repair uniform/PPE for {{.Employee}} (inspection {{.InspectionID}})",
+		DueInDays:           7,
+		Priority:            PriorityHigh,
+		Active:              true,
+	},
+	{
+		Name:                "Spill absorbent missing",
+		Trigger:             TriggerChecklistFailed,
+		ChecklistKey:        inspections.KeySpillAdsorbtionPresent,
+		DescriptionTemplate: "Restock spill absorbent in {{.Employee}}'s vehicle (inspection {{.InspectionID}})",
+		DueInDays:           2,
+		Priority:            PriorityUrgent,
+		Active:              true,
+	},
+}
+
+// Migrate seeds the default corrective action rules the first time it runs.
+// Rules that were since deleted are not recreated.
+func Migrate(db *gorm.DB) error {
+	var count int64
+	if err := db.Unscoped().Model(&CorrectiveActionRule{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	rules := make([]CorrectiveActionRule, len(defaultRules))
+	copy(rules, defaultRules)
+	return db.Create(&rules).Error
+}
@@ -2,11 +2,185 @@ package tasks
 
 import (
 	"qc_api/internal/db"
+	"qc_api/internal/utils"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func Models() []any {
+	return []any{
+		&Task{},
+		&TaskComment{},
+		&CorrectiveActionRule{},
+	}
+}
+
+type TaskStatus string
+
+const (
+	TaskOpen       TaskStatus = "open"
+	TaskInProgress TaskStatus = "in_progress"
+	TaskVerified   TaskStatus = "verified"
+	TaskClosed     TaskStatus = "closed"
+)
+
+// taskStatusTransitions lists the statuses each status may move to. A task is
+// normally worked, verified by a reviewer and closed; open tasks may also be
+// closed outright and closed tasks reopened.
+var taskStatusTransitions = map[TaskStatus][]TaskStatus{
+	TaskOpen:       {TaskInProgress, TaskClosed},
+	TaskInProgress: {TaskOpen, TaskVerified},
+	TaskVerified:   {TaskInProgress, TaskClosed},
+	TaskClosed:     {TaskOpen},
+}
+
+type TaskPriority string
+
+const (
+	PriorityLow    TaskPriority = "low"
+	PriorityMedium TaskPriority = "medium"
+	PriorityHigh   TaskPriority = "high"
+	PriorityUrgent TaskPriority = "urgent"
 )
 
+// Task is a corrective action, usually raised from a failed inspection item.
 type Task struct {
 	db.BaseModel
-	Description  string `json:"description"`
-	Notes        string `json:"notes"`
-	InspectionID string `json:"inspection_id"`
+	Description  string        `gorm:"not null" json:"description"`
+	Notes        string        `json:"notes"`
+	InspectionID *uuid.UUID    `gorm:"type:string;index" json:"inspection_id"`
+	ChecklistKey string        `json:"checklist_key,omitempty"`              //the failed checklist item being corrected
+	AssigneeID   *uuid.UUID    `gorm:"type:string;index" json:"assignee_id"` //employee responsible for the action
+	DueDate      *time.Time    `gorm:"index" json:"due_date"`
+	Priority     TaskPriority  `gorm:"not null;default:medium" json:"priority"`
+	Status       TaskStatus    `gorm:"not null;default:open;index" json:"status"`
+	VerifiedAt   *time.Time    `json:"verified_at"`
+	VerifiedBy   *uuid.UUID    `gorm:"type:string" json:"verified_by"`
+	ClosedAt     *time.Time    `json:"closed_at"`
+	RuleID       *uuid.UUID    `gorm:"type:string;index" json:"rule_id,omitempty"` //set when raised automatically
+	Comments     []TaskComment `gorm:"foreignKey:TaskID" json:"comments,omitempty"`
+}
+
+type TaskComment struct {
+	db.BaseModel
+	TaskID   uuid.UUID `gorm:"type:string;index;not null" json:"task_id"`
+	AuthorID uuid.UUID `gorm:"type:string" json:"author_id"`
+	Body     string    `gorm:"not null" json:"body"`
+}
+
+type TaskDTO struct {
+	Description  string            `json:"description" validate:"required"`
+	Notes        string            `json:"notes"`
+	ChecklistKey string            `json:"checklist_key"`
+	AssigneeID   *uuid.UUID        `json:"assignee_id"`
+	DueDate      *utils.SimpleDate `json:"due_date"`
+	Priority     TaskPriority      `json:"priority" validate:"omitempty,oneof=low medium high urgent"`
+}
+
+type TaskPatch struct {
+	Description *string       `json:"description,omitempty" validate:"omitempty,min=1"`
+	Notes       *string       `json:"notes,omitempty"`
+	AssigneeID  *uuid.UUID    `json:"assignee_id,omitempty"`
+	Priority    *TaskPriority `json:"priority,omitempty" validate:"omitempty,oneof=low medium high urgent"`
+
+	DueDate *utils.SimpleDate `gorm:"-" json:"due_date,omitempty"`
+}
+
+type TaskStatusDTO struct {
+	Status TaskStatus `json:"status" validate:"required,oneof=open in_progress verified closed"`
+}
+
+type TaskCommentDTO struct {
+	Body string `json:"body" validate:"required"`
+}
+
+type TaskFilter struct {
+	InspectionID *uuid.UUID        `json:"inspection_id,omitempty" query:"inspection_id"`
+	AssigneeID   *uuid.UUID        `json:"assignee_id,omitempty" query:"assignee_id"`
+	Status       *TaskStatus       `json:"status,omitempty" query:"status"`
+	Priority     *TaskPriority     `json:"priority,omitempty" query:"priority"`
+	DueFrom      *utils.SimpleDate `json:"due_from,omitempty" query:"due_from" filter:"due_date"`
+	DueTo        *utils.SimpleDate `json:"due_to,omitempty" query:"due_to" filter:"due_date"`
+	Overdue      *bool             `json:"overdue,omitempty" query:"overdue" filter:"-"`
+	utils.PageParams
+}
+
+// taskSorting lists what tasks can be sorted by. Tasks without a due date
+// come after those with one.
+var taskSorting = utils.Sorting{
+	Columns: map[string]string{
+		"created_at":  "created_at",
+		"updated_at":  "updated_at",
+		"due_date":    "due_date",
+		"status":      "status",
+		"assignee_id": "assignee_id",
+		"verified_at": "verified_at",
+		"closed_at":   "closed_at",
+	},
+	Default:   "due_date,created_at",
+	NullsLast: []string{"due_date"},
+}
+
+type RuleTrigger string
+
+const (
+	TriggerChecklistFailed       RuleTrigger = "checklist_failed"
+	TriggerCalibrationOutOfRange RuleTrigger = "calibration_out_of_range"
+)
+
+// CorrectiveActionRule raises a task for the inspected employee when an
+// inspection fails its check, and closes it once a later inspection passes.
+type CorrectiveActionRule struct {
+	db.BaseModel
+	Name                string       `gorm:"not null" json:"name"`
+	Trigger             RuleTrigger  `gorm:"not null" json:"trigger"`
+	ChecklistKey        string       `json:"checklist_key,omitempty"`   //checklist_failed: the question key to watch
+	MinCalibration      *float64     `json:"min_calibration,omitempty"` //calibration_out_of_range: accepted bounds
+	MaxCalibration      *float64     `json:"max_calibration,omitempty"`
+	DescriptionTemplate string       `gorm:"not null" json:"description_template"` //text/template over RuleTemplateData
+	DueInDays           int          `json:"due_in_days"`
+	Priority            TaskPriority `gorm:"not null;default:medium" json:"priority"`
+	Active              bool         `json:"active"`
+}
+
+type CorrectiveActionRuleDTO struct {
+	Name                string       `json:"name" validate:"required"`
+	Trigger             RuleTrigger  `json:"trigger" validate:"required,oneof=checklist_failed calibration_out_of_range"`
+	ChecklistKey        string       `json:"checklist_key"`
+	MinCalibration      *float64     `json:"min_calibration"`
+	MaxCalibration      *float64     `json:"max_calibration"`
+	DescriptionTemplate string       `json:"description_template" validate:"required"`
+	DueInDays           int          `json:"due_in_days" validate:"min=0"`
+	Priority            TaskPriority `json:"priority" validate:"omitempty,oneof=low medium high urgent"`
+	Active              *bool        `json:"active"`
+}
+
+type CorrectiveActionRulePatch struct {
+	Name                *string       `json:"name,omitempty" validate:"omitempty,min=1"`
+	ChecklistKey        *string       `json:"checklist_key,omitempty"`
+	MinCalibration      *float64      `json:"min_calibration,omitempty"`
+	MaxCalibration      *float64      `json:"max_calibration,omitempty"`
+	DescriptionTemplate *string       `json:"description_template,omitempty" validate:"omitempty,min=1"`
+	DueInDays           *int          `json:"due_in_days,omitempty" validate:"omitempty,min=0"`
+	Priority            *TaskPriority `json:"priority,omitempty" validate:"omitempty,oneof=low medium high urgent"`
+	Active              *bool         `json:"active,omitempty"`
+}
+
+type CorrectiveActionRuleFilter struct {
+	Trigger *RuleTrigger `json:"trigger,omitempty" query:"trigger"`
+	Active  *bool        `json:"active,omitempty" query:"active"`
+	utils.PageParams
+}
+
+// ruleSorting lists what corrective action rules can be sorted by.
+var ruleSorting = utils.Sorting{
+	Columns: map[string]string{
+		"created_at": "created_at",
+		"updated_at": "updated_at",
+		"name":       "name",
+		"trigger":    "trigger",
+		"priority":   "priority",
+	},
+	Default: "created_at",
 }
@@ -0,0 +1,18 @@
+package tasks
+
+import "github.com/labstack/echo/v4"
+
+func RegisterRoutes(g *echo.Group, taskService *TaskService) {
+	g.POST("/inspections/:id/tasks", taskService.PostInspectionTaskHandler)
+	g.GET("/inspections/:id/tasks", taskService.GetInspectionTasksHandler)
+	g.GET("/tasks", taskService.GetTasksHandler)
+	g.GET("/tasks/:id", taskService.GetTaskHandler)
+	g.PATCH("/tasks/:id", taskService.PatchTaskHandler)
+	g.PATCH("/tasks/:id/status", taskService.PatchTaskStatusHandler)
+	g.DELETE("/tasks/:id", taskService.DeleteTaskHandler)
+	g.POST("/tasks/:id/comments", taskService.PostTaskCommentHandler)
+	g.GET("/taskrules", taskService.GetRulesHandler)
+	g.POST("/taskrules", taskService.PostRuleHandler)
+	g.PATCH("/taskrules/:id", taskService.PatchRuleHandler)
+	g.DELETE("/taskrules/:id", taskService.DeleteRuleHandler)
+}
@@ -0,0 +1,197 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"qc_api/internal/inspections"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidRule = errors.New("invalid corrective action rule")
+
+// RuleTemplateData is available to a rule's description template, e.g.
+// "Replace PPE for {{.Employee}} (inspection {{.InspectionID}})".
+type RuleTemplateData struct {
+	Rule         string
+	Employee     string
+	InspectionID string
+	ChecklistKey string
+	Calibration  float32
+	DueDate      string
+}
+
+func (r *CorrectiveActionRule) validate() error {
+	switch r.Trigger {
+	case TriggerChecklistFailed:
+		if r.ChecklistKey == "" {
+			return fmt.Errorf("%w: checklist_key is required for %s", ErrInvalidRule, r.Trigger)
+		}
+	case TriggerCalibrationOutOfRange:
+		if r.MinCalibration == nil && r.MaxCalibration == nil {
+			return fmt.Errorf("%w: min_calibration or max_calibration is required for %s", ErrInvalidRule, r.Trigger)
+		}
+		if r.MinCalibration != nil && r.MaxCalibration != nil && *r.MinCalibration > *r.MaxCalibration {
+			return fmt.Errorf("%w: min_calibration is greater than max_calibration", ErrInvalidRule)
+		}
+	default:
+		return fmt.Errorf("%w: unknown trigger %q", ErrInvalidRule, r.Trigger)
+	}
+	if r.DueInDays < 0 {
+		return fmt.Errorf("%w: due_in_days must not be negative", ErrInvalidRule)
+	}
+	if _, err := template.New(r.Name).Parse(r.DescriptionTemplate); err != nil {
+		return fmt.Errorf("%w: description_template: %v", ErrInvalidRule, err)
+	}
+	return nil
+}
+
+// evaluate reports whether the inspection failed the rule's check. ok is false
+// when the inspection says nothing about it, e.g. the checklist item was not
+// answered or no calibration was recorded.
+func (r *CorrectiveActionRule) evaluate(inspection *inspections.Inspection, results map[string]bool) (failed, ok bool) {
+	switch r.Trigger {
+	case TriggerChecklistFailed:
+		passed, answered := results[r.ChecklistKey]
+		return !passed, answered
+	case TriggerCalibrationOutOfRange:
+		if inspection.Calibration == 0 {
+			return false, false
+		}
+		v := float64(inspection.Calibration)
+		return (r.MinCalibration != nil && v < *r.MinCalibration) || (r.MaxCalibration != nil && v > *r.MaxCalibration), true
+	}
+	return false, false
+}
+
+func (r *CorrectiveActionRule) describe(data RuleTemplateData) (string, error) {
+	tmpl, err := template.New(r.Name).Parse(r.DescriptionTemplate)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// InspectionSaved applies the active corrective action rules to a saved
+// inspection. Drafts are ignored until they are submitted.
+func (s *TaskService) InspectionSaved(tx *gorm.DB, inspection *inspections.Inspection) error {
+	if inspection.Status == inspections.StatusDraft {
+		return nil
+	}
+	var rules []CorrectiveActionRule
+	if err := tx.Where("active = ?", true).Find(&rules).Error; err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	results, err := inspections.NewInspectionService(tx).CheckResults(inspection)
+	if err != nil {
+		return err
+	}
+
+	txService := NewTaskService(tx)
+	for i := range rules {
+		failed, ok := rules[i].evaluate(inspection, results)
+		if !ok {
+			continue
+		}
+		if failed {
+			err = txService.raiseRuleTask(&rules[i], inspection)
+		} else {
+			err = txService.resolveRuleTasks(&rules[i], inspection)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func openStatuses() []TaskStatus {
+	return []TaskStatus{TaskOpen, TaskInProgress}
+}
+
+// raiseRuleTask creates the rule's task for the inspected employee unless one
+// is already open or this inspection already raised it.
+func (s *TaskService) raiseRuleTask(rule *CorrectiveActionRule, inspection *inspections.Inspection) error {
+	var count int64
+	err := s.DB.Model(&Task{}).
+		Where("rule_id = ?", rule.ID).
+		Where(s.DB.Where("assignee_id = ? AND status IN ?", inspection.EmployeeID, openStatuses()).Or("inspection_id = ?", inspection.ID)).
+		Count(&count).Error
+	if err != nil || count > 0 {
+		return err
+	}
+
+	due := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, rule.DueInDays)
+	var names []string
+	if err := s.DB.Table("employees").Where("id = ?", inspection.EmployeeID).Pluck("common_name", &names).Error; err != nil {
+		return err
+	}
+	data := RuleTemplateData{
+		Rule:         rule.Name,
+		Employee:     strings.Join(names, ""),
+		InspectionID: inspection.ID.String(),
+		ChecklistKey: rule.ChecklistKey,
+		Calibration:  inspection.Calibration,
+		DueDate:      due.Format("2006-01-02"),
+	}
+	description, err := rule.describe(data)
+	if err != nil {
+		return fmt.Errorf("rule %s: %w", rule.Name, err)
+	}
+
+	task := &Task{
+		Description:  description,
+		InspectionID: &inspection.ID,
+		ChecklistKey: rule.ChecklistKey,
+		DueDate:      &due,
+		Priority:     rule.Priority,
+		Status:       TaskOpen,
+		RuleID:       &rule.ID,
+	}
+	if len(names) > 0 {
+		task.AssigneeID = &inspection.EmployeeID
+	}
+	return s.DB.Create(task).Error
+}
+
+// resolveRuleTasks closes the rule's open tasks for the inspected employee now
+// that an inspection has passed the check. Only tasks raised by earlier
+// inspections are closed; an older inspection passing, e.g. when it is
+// reviewed late, says nothing about a problem found since.
+func (s *TaskService) resolveRuleTasks(rule *CorrectiveActionRule, inspection *inspections.Inspection) error {
+	var open []Task
+	err := s.DB.Joins("JOIN inspections ON inspections.id = tasks.inspection_id").
+		Where("tasks.rule_id = ? AND tasks.assignee_id = ? AND tasks.status IN ?", rule.ID, inspection.EmployeeID, openStatuses()).
+		Where("inspections.created_at < (SELECT created_at FROM inspections WHERE id = ?)", inspection.ID).
+		Find(&open).Error
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, task := range open {
+		err := s.DB.Model(&task).Select("status", "closed_at").Updates(Task{Status: TaskClosed, ClosedAt: &now}).Error
+		if err != nil {
+			return err
+		}
+		comment := TaskComment{
+			TaskID:   task.ID,
+			AuthorID: uuid.Nil,
+			Body:     fmt.Sprintf("Closed automatically: inspection %s passed %q.", inspection.ID, rule.Name),
+		}
+		if err := s.DB.Create(&comment).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,39 +1,246 @@
 package tasks
 
 import (
-	"github.com/jmoiron/sqlx"
+	"errors"
+	"fmt"
+	"qc_api/internal/utils"
+	"slices"
+	"time"
 
 	"github.com/google/uuid"
-	"github.com/labstack/gommon/log"
+	"gorm.io/gorm"
 )
 
+var ErrInvalidTransition = errors.New("invalid status transition")
+var ErrUnknownAssignee = errors.New("assignee is not an existing employee")
+var ErrUnknownChecklistKey = errors.New("inspection has no answer for checklist key")
+
 type TaskService struct {
-	DB *sqlx.DB
+	DB *gorm.DB
 }
 
-func NewTaskService(db *sqlx.DB) *TaskService {
-	return &TaskService{
-		DB: db,
-	}
+func NewTaskService(db *gorm.DB) *TaskService {
+	return &TaskService{DB: db}
+}
+
+// exists reports whether a live row with the given id is in table. Tasks refer
+// to inspections and employees by table so those packages can depend on this one.
+func (s *TaskService) exists(table string, id uuid.UUID) (bool, error) {
+	var count int64
+	err := s.DB.Table(table).Where("id = ? AND deleted_at IS NULL", id).Count(&count).Error
+	return count > 0, err
 }
 
-func (s *TaskService) CreateTask(task Task) error {
-	query := `INSERT INTO tasks (id, description, notes, inpection_id) VALUES (?,?,?,?);`
-	_, err := s.DB.Exec(query, task.ID, task.Description, task.Notes, task.InspectionID)
+func (s *TaskService) checkAssignee(assigneeID *uuid.UUID) error {
+	if assigneeID == nil {
+		return nil
+	}
+	ok, err := s.exists("employees", *assigneeID)
 	if err != nil {
-		log.Errorf("Error inserting new task: %v", err)
 		return err
 	}
+	if !ok {
+		return ErrUnknownAssignee
+	}
+	return nil
+}
+
+// === Tasks ===
+func (s *TaskService) CreateTask(task *Task) error {
+	if task.InspectionID != nil {
+		ok, err := s.exists("inspections", *task.InspectionID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return gorm.ErrRecordNotFound
+		}
+		if task.ChecklistKey != "" {
+			var count int64
+			err := s.DB.Table("inspection_answers").
+				Where("inspection_id = ? AND key = ? AND deleted_at IS NULL", task.InspectionID, task.ChecklistKey).
+				Count(&count).Error
+			if err != nil {
+				return err
+			}
+			if count == 0 {
+				return fmt.Errorf("%w %q", ErrUnknownChecklistKey, task.ChecklistKey)
+			}
+		}
+	}
+	if err := s.checkAssignee(task.AssigneeID); err != nil {
+		return err
+	}
+	if task.Status == "" {
+		task.Status = TaskOpen
+	}
+	if task.Priority == "" {
+		task.Priority = PriorityMedium
+	}
+	return s.DB.Create(task).Error
+}
+
+func (s *TaskService) GetTaskByID(id uuid.UUID) (*Task, error) {
+	var task Task
+	result := s.DB.Preload("Comments", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at ASC")
+	}).Where("id = ?", id).First(&task)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &task, nil
+}
+
+// GetTasks returns a page of the tasks matching filter, by default soonest due
+// first. Overdue tasks
+// are those past their due date that have not been verified or closed.
+func (s *TaskService) GetTasks(filter TaskFilter) (*utils.Page[Task], error) {
+	query := utils.ApplyFilter(s.DB.Model(&Task{}), filter)
+	if filter.Overdue != nil {
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		overdue := s.DB.Where("due_date < ? AND status NOT IN ?", today, []TaskStatus{TaskVerified, TaskClosed})
+		if *filter.Overdue {
+			query = query.Where(overdue)
+		} else {
+			query = query.Not(overdue)
+		}
+	}
+	return utils.Paginate[Task](query, filter.PageParams, taskSorting)
+}
+
+func (s *TaskService) UpdateTask(id uuid.UUID, patch TaskPatch) (*Task, error) {
+	if err := s.checkAssignee(patch.AssigneeID); err != nil {
+		return nil, err
+	}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Task{}).Where("id = ?", id).Updates(patch)
+		if result.Error != nil {
+			return result.Error
+		}
+		if patch.DueDate != nil {
+			return tx.Model(&Task{}).Where("id = ?", id).Update("due_date", patch.DueDate.Time).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetTaskByID(id)
+}
+
+// TransitionTask moves a task to a new status, recording who verified it.
+func (s *TaskService) TransitionTask(id uuid.UUID, status TaskStatus, userID uuid.UUID) (*Task, error) {
+	task, err := s.GetTaskByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(taskStatusTransitions[task.Status], status) {
+		return nil, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, task.Status, status)
+	}
+
+	now := time.Now()
+	switch status {
+	case TaskVerified:
+		task.VerifiedAt = &now
+		task.VerifiedBy = &userID
+	case TaskClosed:
+		task.ClosedAt = &now
+	case TaskOpen, TaskInProgress:
+		task.VerifiedAt, task.VerifiedBy, task.ClosedAt = nil, nil, nil
+	}
+	task.Status = status
+
+	result := s.DB.Model(task).Select("status", "verified_at", "verified_by", "closed_at").Updates(task)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return task, nil
+}
+
+func (s *TaskService) DeleteTask(id uuid.UUID) error {
+	result := s.DB.Delete(&Task{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
 	return nil
 }
 
-func (s *TaskService) GetTaskByID(taskID uuid.UUID) {
-	query := `SELECT * FROM tasks WHERE taskID = ?;`
-	s.DB.QueryRowx(query, taskID)
+// === Comments ===
+func (s *TaskService) CreateComment(comment *TaskComment) error {
+	ok, err := s.exists("tasks", comment.TaskID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	return s.DB.Create(comment).Error
+}
+
+// === Rules ===
+func (s *TaskService) CreateRule(rule *CorrectiveActionRule) error {
+	if rule.Priority == "" {
+		rule.Priority = PriorityMedium
+	}
+	if err := rule.validate(); err != nil {
+		return err
+	}
+	return s.DB.Create(rule).Error
 }
 
-func (s *TaskService) GetTasks() {}
+func (s *TaskService) ReadRules(filter CorrectiveActionRuleFilter) (*utils.Page[CorrectiveActionRule], error) {
+	query := utils.ApplyFilter(s.DB.Model(&CorrectiveActionRule{}), filter)
+	return utils.Paginate[CorrectiveActionRule](query, filter.PageParams, ruleSorting)
+}
 
-func (s *TaskService) UpdateTask() {}
+func (s *TaskService) UpdateRule(id uuid.UUID, patch CorrectiveActionRulePatch) (*CorrectiveActionRule, error) {
+	var rule CorrectiveActionRule
+	if err := s.DB.Where("id = ?", id).First(&rule).Error; err != nil {
+		return nil, err
+	}
+	if patch.Name != nil {
+		rule.Name = *patch.Name
+	}
+	if patch.ChecklistKey != nil {
+		rule.ChecklistKey = *patch.ChecklistKey
+	}
+	if patch.MinCalibration != nil {
+		rule.MinCalibration = patch.MinCalibration
+	}
+	if patch.MaxCalibration != nil {
+		rule.MaxCalibration = patch.MaxCalibration
+	}
+	if patch.DescriptionTemplate != nil {
+		rule.DescriptionTemplate = *patch.DescriptionTemplate
+	}
+	if patch.DueInDays != nil {
+		rule.DueInDays = *patch.DueInDays
+	}
+	if patch.Priority != nil {
+		rule.Priority = *patch.Priority
+	}
+	if patch.Active != nil {
+		rule.Active = *patch.Active
+	}
+	if err := rule.validate(); err != nil {
+		return nil, err
+	}
+	if err := s.DB.Save(&rule).Error; err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
 
-func (s *TaskService) DeleteTask() {}
+func (s *TaskService) DeleteRule(id uuid.UUID) error {
+	result := s.DB.Delete(&CorrectiveActionRule{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,378 @@
+package tasks_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"qc_api/internal/employees"
+	inspectionproperties "qc_api/internal/inspectionProperties"
+	"qc_api/internal/inspections"
+	"qc_api/internal/tasks"
+	"qc_api/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB() *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		panic("failed to connect database")
+	}
+	var models []any
+	models = append(models, employees.Models()...)
+	models = append(models, inspections.Models()...)
+	models = append(models, inspectionproperties.Models()...)
+	models = append(models, tasks.Models()...)
+	if err := db.AutoMigrate(models...); err != nil {
+		panic("failed to migrate database")
+	}
+	if err := inspections.Migrate(db); err != nil {
+		panic("failed to run inspection migrations: " + err.Error())
+	}
+	return db
+}
+
+func newContext(method, url, body string, id string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	e.Validator = utils.NewValidator()
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	if id != "" {
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+	}
+	return c, rec
+}
+
+// seedInspection creates an employee and an inspection of them that failed the PPE check.
+func seedInspection(t *testing.T, db *gorm.DB) (*employees.Employee, *inspections.Inspection) {
+	employee := &employees.Employee{CommonName: "Sam", FirstName: "Sam", LastName: "Lee", EmployeeNumber: "42"}
+	require.NoError(t, db.Create(employee).Error)
+	service := inspections.NewInspectionService(db)
+	inspection := &inspections.Inspection{Report: "ok", EmployeeID: employee.ID}
+	require.NoError(t, service.ApplyAnswers(inspection, []inspections.AnswerDTO{{Key: inspections.KeyUniformPPEGood, Value: false}}))
+	inspection, err := service.CreateInspection(inspection)
+	require.NoError(t, err)
+	return employee, inspection
+}
+
+func TestPostInspectionTask(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	service := tasks.NewTaskService(db)
+	employee, inspection := seedInspection(t, db)
+
+	tests := []struct {
+		name         string
+		inspectionID string
+		body         string
+		expectedCode int
+	}{
+		{"corrective action", inspection.ID.String(), `{"description": "Replace hi-vis vest", "checklist_key": "uniform_ppe_good", "assignee_id": "` + employee.ID.String() + `", "due_date": "2025-10-01", "priority": "high"}`, http.StatusCreated},
+		{"defaults", inspection.ID.String(), `{"description": "Follow up"}`, http.StatusCreated},
+		{"missing description", inspection.ID.String(), `{"priority": "high"}`, http.StatusBadRequest},
+		{"unknown priority", inspection.ID.String(), `{"description": "x", "priority": "asap"}`, http.StatusBadRequest},
+		{"unknown assignee", inspection.ID.String(), `{"description": "x", "assignee_id": "` + uuid.New().String() + `"}`, http.StatusBadRequest},
+		{"unanswered checklist key", inspection.ID.String(), `{"description": "x", "checklist_key": "pic_present"}`, http.StatusBadRequest},
+		{"unknown inspection", uuid.New().String(), `{"description": "x"}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, rec := newContext(http.MethodPost, "/inspections/"+tt.inspectionID+"/tasks", tt.body, tt.inspectionID)
+
+			err := service.PostInspectionTaskHandler(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedCode, rec.Code, rec.Body.String())
+		})
+	}
+
+	// Assertions
+	page, err := service.GetTasks(tasks.TaskFilter{InspectionID: &inspection.ID})
+	require.NoError(t, err)
+	created := page.Data
+	require.Len(t, created, 2)
+	assert.Equal(t, "Replace hi-vis vest", created[0].Description)
+	assert.Equal(t, tasks.PriorityHigh, created[0].Priority)
+	assert.Equal(t, tasks.TaskOpen, created[0].Status)
+	assert.Equal(t, time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC), created[0].DueDate.UTC())
+	assert.Equal(t, tasks.PriorityMedium, created[1].Priority)
+	assert.Nil(t, created[1].DueDate)
+}
+
+func TestTaskLifecycle(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	service := tasks.NewTaskService(db)
+	_, inspection := seedInspection(t, db)
+	task := &tasks.Task{Description: "Restock spill kit", InspectionID: &inspection.ID}
+	require.NoError(t, service.CreateTask(task))
+	verifier := uuid.New()
+
+	steps := []struct {
+		name         string
+		status       string
+		expectedCode int
+	}{
+		{"open cannot be verified", "verified", http.StatusConflict},
+		{"start", "in_progress", http.StatusOK},
+		{"verify", "verified", http.StatusOK},
+		{"unknown status", "done", http.StatusBadRequest},
+		{"close", "closed", http.StatusOK},
+		{"reopen", "open", http.StatusOK},
+	}
+
+	for _, tt := range steps {
+		t.Run(tt.name, func(t *testing.T) {
+			c, rec := newContext(http.MethodPatch, "/tasks/"+task.ID.String()+"/status", `{"status": "`+tt.status+`"}`, task.ID.String())
+			c.Set("user_id", verifier)
+
+			err := service.PatchTaskStatusHandler(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedCode, rec.Code, rec.Body.String())
+		})
+	}
+
+	reopened, err := service.GetTaskByID(task.ID)
+	require.NoError(t, err)
+	assert.Equal(t, tasks.TaskOpen, reopened.Status)
+	assert.Nil(t, reopened.VerifiedBy)
+	assert.Nil(t, reopened.ClosedAt)
+
+	// Comments
+	c, rec := newContext(http.MethodPost, "/", `{"body": "Kit ordered"}`, task.ID.String())
+	c.Set("user_id", verifier)
+	require.NoError(t, service.PostTaskCommentHandler(c))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	c, rec = newContext(http.MethodPost, "/", `{"body": "Kit ordered"}`, uuid.New().String())
+	require.NoError(t, service.PostTaskCommentHandler(c))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	c, rec = newContext(http.MethodGet, "/", "", task.ID.String())
+	require.NoError(t, service.GetTaskHandler(c))
+	var detail tasks.Task
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &detail))
+	require.Len(t, detail.Comments, 1)
+	assert.Equal(t, verifier, detail.Comments[0].AuthorID)
+
+	// Patch and delete
+	c, rec = newContext(http.MethodPatch, "/", `{"priority": "urgent", "due_date": "2025-11-05"}`, task.ID.String())
+	require.NoError(t, service.PatchTaskHandler(c))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &detail))
+	assert.Equal(t, tasks.PriorityUrgent, detail.Priority)
+	assert.Equal(t, "2025-11-05", detail.DueDate.UTC().Format("2006-01-02"))
+
+	c, rec = newContext(http.MethodDelete, "/", "", task.ID.String())
+	require.NoError(t, service.DeleteTaskHandler(c))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
+
+func TestGetTasksFilters(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	service := tasks.NewTaskService(db)
+	employee, inspection := seedInspection(t, db)
+	past := time.Now().AddDate(0, 0, -3)
+	future := time.Now().AddDate(0, 0, 3)
+	seed := []tasks.Task{
+		{Description: "overdue", AssigneeID: &employee.ID, DueDate: &past},
+		{Description: "overdue but closed", DueDate: &past, Status: tasks.TaskClosed},
+		{Description: "upcoming", AssigneeID: &employee.ID, DueDate: &future, Priority: tasks.PriorityHigh},
+		{Description: "no due date"},
+	}
+	for _, task := range seed {
+		task.InspectionID = &inspection.ID
+		require.NoError(t, service.CreateTask(&task))
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{"all, soonest due first", "", []string{"overdue", "overdue but closed", "upcoming", "no due date"}},
+		{"overdue", "overdue=true", []string{"overdue"}},
+		{"assignee", "assignee_id=" + employee.ID.String(), []string{"overdue", "upcoming"}},
+		{"priority", "priority=high", []string{"upcoming"}},
+		{"status", "status=closed", []string{"overdue but closed"}},
+		{"due from", "due_from=" + time.Now().Format("2006-01-02"), []string{"upcoming"}},
+		{"latest due first", "status=open&sort=-due_date", []string{"no due date", "upcoming", "overdue"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, rec := newContext(http.MethodGet, "/tasks?"+tt.query, "", "")
+
+			err := service.GetTasksHandler(c)
+
+			assert.NoError(t, err)
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			var results utils.Page[tasks.Task]
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &results))
+			var descriptions []string
+			for _, r := range results.Data {
+				descriptions = append(descriptions, r.Description)
+			}
+			assert.Equal(t, tt.expected, descriptions)
+		})
+	}
+
+	c, rec := newContext(http.MethodGet, "/tasks?sort=priority", "", "")
+	require.NoError(t, service.GetTasksHandler(c))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestCorrectiveActionRules(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	require.NoError(t, tasks.Migrate(db))
+	service := tasks.NewTaskService(db)
+	inspectionService := inspections.NewInspectionService(db)
+	inspectionService.AddObserver(service)
+	employee, _ := seedInspection(t, db)
+
+	inspect := func(status inspections.InspectionStatus, calibration float32, ppe bool) *inspections.Inspection {
+		inspection := &inspections.Inspection{Report: "ok", EmployeeID: employee.ID, Status: status, Calibration: calibration}
+		require.NoError(t, inspectionService.ApplyAnswers(inspection, []inspections.AnswerDTO{{Key: inspections.KeyUniformPPEGood, Value: ppe}}))
+		inspection, err := inspectionService.CreateInspection(inspection)
+		require.NoError(t, err)
+		return inspection
+	}
+	assigned := func() []tasks.Task {
+		page, err := service.GetTasks(tasks.TaskFilter{AssigneeID: &employee.ID})
+		require.NoError(t, err)
+		return page.Data
+	}
+
+	// Drafts do not raise tasks, and the seeded draft above did not either
+	inspect(inspections.StatusDraft, 0, false)
+	assert.Empty(t, assigned())
+
+	// A submitted failure raises one task from the seeded PPE rule
+	failed := inspect(inspections.StatusSubmitted, 0, false)
+	raised := assigned()
+	require.Len(t, raised, 1)
+	assert.Equal(t, "Replace or repair uniform/PPE for Sam (inspection "+failed.ID.String()+")", raised[0].Description)
+	assert.Equal(t, inspections.KeyUniformPPEGood, raised[0].ChecklistKey)
+	assert.Equal(t, tasks.PriorityHigh, raised[0].Priority)
+	assert.NotNil(t, raised[0].RuleID)
+	assert.Equal(t, time.Now().UTC().AddDate(0, 0, 7).Format("2006-01-02"), raised[0].DueDate.UTC().Format("2006-01-02"))
+
+	// Editing the failed inspection or failing again does not duplicate it
+	report := "edited"
+	_, err := inspectionService.UpdateInspection(failed.ID, inspections.InspectionPatch{Report: &report})
+	require.NoError(t, err)
+	inspect(inspections.StatusSubmitted, 0, false)
+	assert.Len(t, assigned(), 1)
+
+	// Calibration rules
+	c, rec := newContext(http.MethodPost, "/taskrules", `{"name": "Calibration", "trigger": "calibration_out_of_range", "min_calibration": 2, "max_calibration": 5, "description_template": "Recalibrate spreader ({{.Calibration}})", "due_in_days": 1}`, "")
+	require.NoError(t, service.PostRuleHandler(c))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	inspect(inspections.StatusSubmitted, 7.5, false)
+	raised = assigned()
+	require.Len(t, raised, 2)
+	assert.Equal(t, "Recalibrate spreader (7.5)", raised[0].Description)
+
+	// A later passing inspection closes both
+	inspect(inspections.StatusSubmitted, 3, true)
+	closed := assigned()
+	require.Len(t, closed, 2)
+	for _, task := range closed {
+		assert.Equal(t, tasks.TaskClosed, task.Status)
+		detail, err := service.GetTaskByID(task.ID)
+		require.NoError(t, err)
+		require.Len(t, detail.Comments, 1)
+		assert.Contains(t, detail.Comments[0].Body, "Closed automatically")
+	}
+
+	// Invalid rules are rejected
+	invalid := []string{
+		`{"name": "x", "trigger": "checklist_failed", "description_template": "x"}`,
+		`{"name": "x", "trigger": "calibration_out_of_range", "min_calibration": 5, "max_calibration": 2, "description_template": "x"}`,
+		`{"name": "x", "trigger": "checklist_failed", "checklist_key": "pic_present", "description_template": "{{.Oops"}`,
+		`{"name": "x", "trigger": "sometimes", "description_template": "x"}`,
+	}
+	for _, body := range invalid {
+		c, rec := newContext(http.MethodPost, "/taskrules", body, "")
+		require.NoError(t, service.PostRuleHandler(c))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+	}
+
+	// Rules are listed a page at a time
+	c, rec = newContext(http.MethodGet, "/taskrules?trigger=calibration_out_of_range", "", "")
+	require.NoError(t, service.GetRulesHandler(c))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var rules utils.Page[tasks.CorrectiveActionRule]
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rules))
+	require.Len(t, rules.Data, 1)
+	assert.Equal(t, "Calibration", rules.Data[0].Name)
+	all, err := service.ReadRules(tasks.CorrectiveActionRuleFilter{})
+	require.NoError(t, err)
+	first, err := service.ReadRules(tasks.CorrectiveActionRuleFilter{PageParams: utils.PageParams{Limit: 1, Sort: "-trigger,name"}})
+	require.NoError(t, err)
+	require.Len(t, first.Data, 1)
+	assert.Equal(t, all.Total, first.Total)
+	rest, err := service.ReadRules(tasks.CorrectiveActionRuleFilter{PageParams: utils.PageParams{Limit: 200, Sort: "-trigger,name", Cursor: first.NextCursor}})
+	require.NoError(t, err)
+	assert.Len(t, rest.Data, int(all.Total)-1)
+	assert.NotContains(t, rest.Data, first.Data[0])
+	c, rec = newContext(http.MethodGet, "/taskrules?sort=description_template", "", "")
+	require.NoError(t, service.GetRulesHandler(c))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestCorrectiveActionRulesLateReview(t *testing.T) {
+	// Setup
+	db := setupTestDB()
+	require.NoError(t, tasks.Migrate(db))
+	service := tasks.NewTaskService(db)
+	inspectionService := inspections.NewInspectionService(db)
+	inspectionService.AddObserver(service)
+	employee, _ := seedInspection(t, db)
+
+	inspect := func(status inspections.InspectionStatus, ppe bool) *inspections.Inspection {
+		inspection := &inspections.Inspection{Report: "ok", EmployeeID: employee.ID, Status: status}
+		require.NoError(t, inspectionService.ApplyAnswers(inspection, []inspections.AnswerDTO{{Key: inspections.KeyUniformPPEGood, Value: ppe}}))
+		inspection, err := inspectionService.CreateInspection(inspection)
+		require.NoError(t, err)
+		return inspection
+	}
+	assigned := func() []tasks.Task {
+		page, err := service.GetTasks(tasks.TaskFilter{AssigneeID: &employee.ID})
+		require.NoError(t, err)
+		return page.Data
+	}
+
+	// A passing inspection still in draft, then a newer one that fails
+	older := inspect(inspections.StatusDraft, true)
+	inspect(inspections.StatusSubmitted, false)
+	require.Len(t, assigned(), 1)
+
+	// Submitting the older inspection leaves the newer problem open
+	_, err := inspectionService.TransitionInspection(older.ID, inspections.StatusSubmitted, uuid.New())
+	require.NoError(t, err)
+	raised := assigned()
+	require.Len(t, raised, 1)
+	assert.Equal(t, tasks.TaskOpen, raised[0].Status)
+
+	// An inspection after the failure closes it
+	inspect(inspections.StatusSubmitted, true)
+	raised = assigned()
+	require.Len(t, raised, 1)
+	assert.Equal(t, tasks.TaskClosed, raised[0].Status)
+}
@@ -0,0 +1,69 @@
+package transcription
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const maxLogSize = 64 << 10
+
+// CommandTranscriber runs an external program. The arguments may contain
+// {input}, replaced with the audio path, and {output}, replaced with a file
+// the program should write the transcript to. Without {output} the transcript
+// is read from stdout.
+type CommandTranscriber struct {
+	Command []string
+	Timeout time.Duration
+}
+
+func (t *CommandTranscriber) Transcribe(ctx context.Context, audioPath string) (Result, error) {
+	return withTimeout(ctx, t.Timeout, func(ctx context.Context) (Result, error) {
+		dir, err := os.MkdirTemp("", "transcript-*")
+		if err != nil {
+			return Result{}, err
+		}
+		defer os.RemoveAll(dir)
+		output := filepath.Join(dir, "transcript.txt")
+
+		args := make([]string, len(t.Command))
+		writesFile := false
+		for i, arg := range t.Command {
+			if strings.Contains(arg, "{output}") {
+				writesFile = true
+			}
+			arg = strings.ReplaceAll(arg, "{input}", audioPath)
+			args[i] = strings.ReplaceAll(arg, "{output}", output)
+		}
+
+		var stdout bytes.Buffer
+		stderr := &cappedBuffer{max: maxLogSize}
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Dir = filepath.Dir(audioPath)
+		cmd.Stdout = &stdout
+		cmd.Stderr = stderr
+		// Don't wait forever on children that inherited the pipes
+		cmd.WaitDelay = 5 * time.Second
+		err = cmd.Run()
+		result := Result{Log: stderr.String()}
+		if err != nil {
+			return result, fmt.Errorf("transcription command failed: %w", err)
+		}
+
+		if !writesFile {
+			result.Text = stdout.String()
+			return result, nil
+		}
+		text, err := os.ReadFile(output)
+		if err != nil {
+			return result, fmt.Errorf("transcription command wrote no transcript: %w", err)
+		}
+		result.Text = string(text)
+		return result, nil
+	})
+}
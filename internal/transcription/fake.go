@@ -0,0 +1,42 @@
+package transcription
+
+import (
+	"context"
+	"os"
+	"sync"
+)
+
+// Fake returns a fixed transcript without running anything. It records the
+// audio it was given so tests can check what was transcribed.
+type Fake struct {
+	Text     string
+	Log      string
+	Segments []Segment
+	Language string
+	Duration float64
+	Err      error
+
+	mu    sync.Mutex
+	audio [][]byte
+}
+
+func (f *Fake) Transcribe(ctx context.Context, audioPath string) (Result, error) {
+	data, err := os.ReadFile(audioPath)
+	if err != nil {
+		return Result{}, err
+	}
+	f.mu.Lock()
+	f.audio = append(f.audio, data)
+	f.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	return Result{Text: f.Text, Log: f.Log, Segments: f.Segments, Language: f.Language, Duration: f.Duration}, f.Err
+}
+
+// Calls returns the contents of every audio file transcribed so far.
+func (f *Fake) Calls() [][]byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]byte(nil), f.audio...)
+}
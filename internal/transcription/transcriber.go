@@ -0,0 +1,106 @@
+package transcription
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrTimeout = errors.New("transcription timed out")
+
+// DefaultTimeout bounds a single transcription unless configured otherwise.
+const DefaultTimeout = 30 * time.Minute
+
+// Result is a finished transcription. Log holds whatever diagnostics the
+// engine produced (stderr for commands), kept for troubleshooting. Engines
+// that only produce text leave Segments, Language and Duration empty.
+type Result struct {
+	Text     string
+	Log      string
+	Segments []Segment
+	Language string
+	Duration float64 // seconds of audio
+}
+
+// Segment is a stretch of speech, timed in seconds from the start of the
+// audio. Speaker is set by engines that can tell speakers apart.
+type Segment struct {
+	Start   float64
+	End     float64
+	Speaker string
+	Text    string
+}
+
+// Transcriber turns an audio file on local disk into text.
+type Transcriber interface {
+	Transcribe(ctx context.Context, audioPath string) (Result, error)
+}
+
+// Config selects and configures a transcription engine.
+type Config struct {
+	Engine  string        // "command", "whisper" or "fake"
+	Command []string      // program and arguments, see CommandTranscriber
+	URL     string        // whisper server base URL
+	Timeout time.Duration // zero uses DefaultTimeout
+}
+
+func New(cfg Config) (Transcriber, error) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	switch cfg.Engine {
+	case "command":
+		if len(cfg.Command) == 0 {
+			return nil, errors.New("transcription command is not configured")
+		}
+		return &CommandTranscriber{Command: cfg.Command, Timeout: cfg.Timeout}, nil
+	case "whisper":
+		if cfg.URL == "" {
+			return nil, errors.New("whisper server URL is not configured")
+		}
+		return NewWhisperTranscriber(cfg.URL, cfg.Timeout), nil
+	case "fake":
+		return &Fake{Text: "fake transcript"}, nil
+	}
+	return nil, fmt.Errorf("unknown transcription engine %q", cfg.Engine)
+}
+
+// withTimeout applies timeout to ctx and maps its expiry to ErrTimeout.
+func withTimeout(ctx context.Context, timeout time.Duration, run func(ctx context.Context) (Result, error)) (Result, error) {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	result, err := run(ctx)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return result, fmt.Errorf("%w after %s", ErrTimeout, timeout)
+	}
+	return result, err
+}
+
+// cappedBuffer keeps the first max bytes written to it and drops the rest, so
+// a chatty engine can't exhaust memory.
+type cappedBuffer struct {
+	buf       []byte
+	max       int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - len(b.buf); room > 0 {
+		b.buf = append(b.buf, p[:min(room, len(p))]...)
+	}
+	if len(b.buf)+len(p) > b.max {
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+func (b *cappedBuffer) String() string {
+	if b.truncated {
+		return string(b.buf) + "\n[truncated]"
+	}
+	return string(b.buf)
+}
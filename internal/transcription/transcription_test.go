@@ -0,0 +1,146 @@
+package transcription_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"qc_api/internal/transcription"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func audioFile(t *testing.T) string {
+	p := filepath.Join(t.TempDir(), "visit.wav")
+	require.NoError(t, os.WriteFile(p, []byte("RIFF fake audio"), 0o644))
+	return p
+}
+
+func TestCommandTranscriber(t *testing.T) {
+	audio := audioFile(t)
+
+	tests := []struct {
+		name        string
+		script      string
+		timeout     time.Duration
+		expected    string
+		expectedLog string
+		expectedErr error
+	}{
+		{"transcript on stdout", `echo "heard: $(cat "$1")"`, 0, "heard: RIFF fake audio\n", "", nil},
+		{"transcript in output file", `echo progress >&2; tr a-z A-Z < "$1" > "$2"`, 0, "RIFF FAKE AUDIO", "progress\n", nil},
+		{"failure keeps stderr", `echo "model not found" >&2; exit 3`, 0, "", "model not found\n", errors.New("exit status 3")},
+		{"timeout", `exec sleep 5`, 100 * time.Millisecond, "", "", transcription.ErrTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command := []string{"sh", "-c", tt.script, "sh", "{input}"}
+			if strings.Contains(tt.script, "$2") {
+				command = append(command, "{output}")
+			}
+			transcriber := &transcription.CommandTranscriber{Command: command, Timeout: tt.timeout}
+
+			result, err := transcriber.Transcribe(context.Background(), audio)
+
+			assert.Equal(t, tt.expectedLog, result.Log)
+			switch {
+			case tt.expectedErr == nil:
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, result.Text)
+			case errors.Is(tt.expectedErr, transcription.ErrTimeout):
+				assert.True(t, errors.Is(err, transcription.ErrTimeout), "%v", err)
+			default:
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedErr.Error())
+			}
+		})
+	}
+}
+
+func TestWhisperTranscriber(t *testing.T) {
+	audio := audioFile(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/inference" || r.FormValue("response_format") != "verbose_json" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "no file", http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(file)
+		if string(data) == "broken" {
+			json.NewEncoder(w).Encode(map[string]string{"error": "failed to read audio"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"text":     " " + header.Filename + ": " + string(data) + "\n",
+			"language": "en",
+			"duration": 4.5,
+			"segments": []map[string]any{
+				{"id": 0, "start": 0.0, "end": 2.0, "text": " " + header.Filename + ":"},
+				{"id": 1, "start": 2.0, "end": 2.1, "text": " "},
+				{"id": 2, "start": 2.1, "end": 4.5, "text": " " + string(data), "speaker": "SPEAKER_01"},
+			},
+		})
+	}))
+	defer server.Close()
+
+	transcriber := transcription.NewWhisperTranscriber(server.URL+"/", time.Second)
+	result, err := transcriber.Transcribe(context.Background(), audio)
+	require.NoError(t, err)
+	assert.Equal(t, "visit.wav: RIFF fake audio", result.Text)
+	assert.Equal(t, "en", result.Language)
+	assert.Equal(t, 4.5, result.Duration)
+	assert.Equal(t, []transcription.Segment{
+		{Start: 0, End: 2, Text: "visit.wav:"},
+		{Start: 2.1, End: 4.5, Speaker: "SPEAKER_01", Text: "RIFF fake audio"},
+	}, result.Segments)
+
+	require.NoError(t, os.WriteFile(audio, []byte("broken"), 0o644))
+	result, err = transcriber.Transcribe(context.Background(), audio)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to read audio")
+	assert.Contains(t, result.Log, "failed to read audio")
+
+	down := transcription.NewWhisperTranscriber("http://127.0.0.1:1", time.Second)
+	_, err = down.Transcribe(context.Background(), audio)
+	assert.Error(t, err)
+}
+
+func TestNewTranscriber(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     transcription.Config
+		wantErr bool
+	}{
+		{"command", transcription.Config{Engine: "command", Command: []string{"whisper", "{input}"}}, false},
+		{"command without program", transcription.Config{Engine: "command"}, true},
+		{"whisper", transcription.Config{Engine: "whisper", URL: "http://localhost:8080"}, false},
+		{"whisper without url", transcription.Config{Engine: "whisper"}, true},
+		{"fake", transcription.Config{Engine: "fake"}, false},
+		{"unknown", transcription.Config{Engine: "python"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transcriber, err := transcription.New(tt.cfg)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, transcriber)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package transcription
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// WhisperTranscriber posts audio to a whisper.cpp style server's /inference
+// endpoint and reads the verbose JSON transcript back, with its segments.
+type WhisperTranscriber struct {
+	URL     string
+	Timeout time.Duration
+	Client  *http.Client
+}
+
+func NewWhisperTranscriber(url string, timeout time.Duration) *WhisperTranscriber {
+	return &WhisperTranscriber{URL: strings.TrimSuffix(url, "/"), Timeout: timeout, Client: &http.Client{}}
+}
+
+func (t *WhisperTranscriber) Transcribe(ctx context.Context, audioPath string) (Result, error) {
+	return withTimeout(ctx, t.Timeout, func(ctx context.Context) (Result, error) {
+		audio, err := os.Open(audioPath)
+		if err != nil {
+			return Result{}, err
+		}
+		defer audio.Close()
+
+		// Stream the multipart body rather than buffering the recording
+		pr, pw := io.Pipe()
+		form := multipart.NewWriter(pw)
+		go func() {
+			err := func() error {
+				if err := form.WriteField("response_format", "verbose_json"); err != nil {
+					return err
+				}
+				part, err := form.CreateFormFile("file", filepath.Base(audioPath))
+				if err != nil {
+					return err
+				}
+				if _, err := io.Copy(part, audio); err != nil {
+					return err
+				}
+				return form.Close()
+			}()
+			pw.CloseWithError(err)
+		}()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL+"/inference", pr)
+		if err != nil {
+			pr.Close()
+			return Result{}, err
+		}
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		resp, err := t.Client.Do(req)
+		if err != nil {
+			return Result{}, fmt.Errorf("whisper request failed: %w", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+		if err != nil {
+			return Result{}, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return Result{Log: string(body)}, fmt.Errorf("whisper server returned %s", resp.Status)
+		}
+		var decoded struct {
+			Text     string  `json:"text"`
+			Language string  `json:"language"`
+			Duration float64 `json:"duration"`
+			Segments []struct {
+				Start   float64 `json:"start"`
+				End     float64 `json:"end"`
+				Speaker string  `json:"speaker"`
+				Text    string  `json:"text"`
+			} `json:"segments"`
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(bytes.NewReader(body)).Decode(&decoded); err != nil {
+			return Result{Log: string(body)}, fmt.Errorf("invalid whisper response: %w", err)
+		}
+		if decoded.Error != "" {
+			return Result{Log: string(body)}, fmt.Errorf("whisper server error: %s", decoded.Error)
+		}
+		result := Result{Text: strings.TrimSpace(decoded.Text), Language: decoded.Language, Duration: decoded.Duration}
+		for _, segment := range decoded.Segments {
+			text := strings.TrimSpace(segment.Text)
+			if text == "" {
+				continue
+			}
+			result.Segments = append(result.Segments, Segment{Start: segment.Start, End: segment.End, Speaker: segment.Speaker, Text: text})
+		}
+		return result, nil
+	})
+}
@@ -0,0 +1,130 @@
+package transcripts
+
+import (
+	"errors"
+	"net/http"
+
+	"qc_api/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// GetTranscriptsHandler godoc
+// @Summary Get transcripts
+// @Description Retrieve transcripts of uploaded recordings, by default newest first, without their segments
+// @Tags transcripts
+// @Produce json
+// @Param inspection_id query string false "Filter by inspection ID (UUID)"
+// @Param upload_id query string false "Filter by upload ID (UUID)"
+// @Param language query string false "Filter by language"
+// @Param q query string false "Only transcripts containing this text"
+// @Param limit query int false "Page size (default 50, at most 200)"
+// @Param offset query int false "Rows to skip"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort keys, - for descending (e.g. -created_at,language)"
+// @Param fields query string false "Comma separated fields to return (e.g. id,text)"
+// @Success 200 {object} utils.Page[Transcript]
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /transcripts [get]
+func (s *TranscriptService) GetTranscriptsHandler(c echo.Context) error {
+	var filter TranscriptFilter
+	if err := c.Bind(&filter); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	transcripts, err := s.GetTranscripts(filter)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidQuery) {
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: "failed to retrieve transcripts"})
+	}
+	return c.JSON(http.StatusOK, transcripts)
+}
+
+// GetTranscriptHandler godoc
+// @Summary Get transcript by ID
+// @Description Retrieve a transcript with its timed segments
+// @Tags transcripts
+// @Produce json
+// @Param id path string true "Transcript ID"
+// @Success 200 {object} Transcript
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /transcripts/{id} [get]
+func (s *TranscriptService) GetTranscriptHandler(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid transcript id"})
+	}
+	transcript, err := s.GetTranscriptByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: "transcript not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+	}
+	return c.JSON(http.StatusOK, transcript)
+}
+
+// GetTranscriptSegmentsHandler godoc
+// @Summary Search transcript segments
+// @Description Find where something was said. Each segment carries its upload and its start and end in seconds, to play the recording from that moment.
+// @Tags transcripts
+// @Produce json
+// @Param q query string false "Only segments containing this text"
+// @Param inspection_id query string false "Filter by inspection ID (UUID)"
+// @Param upload_id query string false "Filter by upload ID (UUID)"
+// @Param speaker query string false "Filter by speaker label"
+// @Param limit query int false "Page size (default 50, at most 200)"
+// @Param offset query int false "Rows to skip"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort keys, - for descending (e.g. -created_at,position)"
+// @Param fields query string false "Comma separated fields to return (e.g. upload_id,start,text)"
+// @Success 200 {object} utils.Page[SegmentMatch]
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /transcripts/segments [get]
+func (s *TranscriptService) GetTranscriptSegmentsHandler(c echo.Context) error {
+	var filter SegmentFilter
+	if err := c.Bind(&filter); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	matches, err := s.SearchSegments(filter)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidQuery) {
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: "failed to search transcripts"})
+	}
+	return c.JSON(http.StatusOK, matches)
+}
+
+// GetInspectionTranscriptsHandler godoc
+// @Summary Get transcripts of an inspection
+// @Description Retrieve the transcripts of the recordings uploaded for an inspection, with their segments
+// @Tags transcripts
+// @Produce json
+// @Param id path string true "Inspection ID"
+// @Success 200 {array} Transcript
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /inspections/{id}/transcripts [get]
+func (s *TranscriptService) GetInspectionTranscriptsHandler(c echo.Context) error {
+	inspectionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid inspection id"})
+	}
+	transcripts, err := s.GetInspectionTranscripts(inspectionID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: "failed to retrieve transcripts"})
+	}
+	return c.JSON(http.StatusOK, transcripts)
+}
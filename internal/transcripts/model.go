@@ -0,0 +1,93 @@
+package transcripts
+
+import (
+	"qc_api/internal/db"
+	"qc_api/internal/utils"
+
+	"github.com/google/uuid"
+)
+
+func Models() []any {
+	return []any{
+		&Transcript{},
+		&TranscriptSegment{},
+	}
+}
+
+// Transcript is the text of an uploaded recording. Segments keep when each
+// part was said, so a search hit can be played back from that moment.
+type Transcript struct {
+	db.BaseModel
+	UploadID     uuid.UUID           `gorm:"type:string;uniqueIndex;not null" json:"upload_id"`
+	InspectionID uuid.UUID           `gorm:"type:string;index;not null" json:"inspection_id"`
+	Text         string              `gorm:"not null" json:"text"`
+	Language     string              `gorm:"index" json:"language,omitempty"` //as reported by the transcription engine
+	Duration     float64             `json:"duration"`                        //seconds of audio, 0 if unknown
+	Segments     []TranscriptSegment `gorm:"foreignKey:TranscriptID" json:"segments,omitempty"`
+}
+
+// TranscriptSegment is a stretch of speech. Start and End are seconds from the
+// beginning of the recording; Speaker is empty unless the engine labels speakers.
+type TranscriptSegment struct {
+	db.BaseModel
+	TranscriptID uuid.UUID `gorm:"type:string;index;not null" json:"transcript_id"`
+	Position     int       `gorm:"not null" json:"position"`
+	Start        float64   `json:"start"`
+	End          float64   `json:"end"`
+	Speaker      string    `gorm:"index" json:"speaker,omitempty"`
+	Text         string    `gorm:"not null" json:"text"`
+}
+
+// SegmentMatch is a segment found by a search, with the upload to play it from.
+type SegmentMatch struct {
+	TranscriptSegment
+	UploadID     uuid.UUID `json:"upload_id"`
+	InspectionID uuid.UUID `json:"inspection_id"`
+}
+
+// TableName makes matches read from, and page over, the segments table.
+func (SegmentMatch) TableName() string {
+	return "transcript_segments"
+}
+
+type TranscriptFilter struct {
+	InspectionID *uuid.UUID `json:"inspection_id,omitempty" query:"inspection_id"`
+	UploadID     *uuid.UUID `json:"upload_id,omitempty" query:"upload_id"`
+	Language     *string    `json:"language,omitempty" query:"language"`
+	Query        *string    `json:"q,omitempty" query:"q" filter:"text,op=ilike"` //text contains, ignoring case
+	utils.PageParams
+}
+
+// transcriptSorting lists what transcripts can be sorted by.
+var transcriptSorting = utils.Sorting{
+	Columns: map[string]string{
+		"created_at":    "created_at",
+		"updated_at":    "updated_at",
+		"inspection_id": "inspection_id",
+		"upload_id":     "upload_id",
+		"language":      "language",
+		"duration":      "duration",
+	},
+	Default: "-created_at",
+}
+
+type SegmentFilter struct {
+	InspectionID *uuid.UUID `json:"inspection_id,omitempty" query:"inspection_id" filter:"transcripts.inspection_id"`
+	UploadID     *uuid.UUID `json:"upload_id,omitempty" query:"upload_id" filter:"transcripts.upload_id"`
+	Speaker      *string    `json:"speaker,omitempty" query:"speaker" filter:"transcript_segments.speaker"`
+	Query        *string    `json:"q,omitempty" query:"q" filter:"transcript_segments.text,op=ilike"` //text contains, ignoring case
+	utils.PageParams
+}
+
+// segmentSorting lists what segment matches can be sorted by. Segments are
+// saved together with their transcript, so created_at groups them by
+// transcript.
+var segmentSorting = utils.Sorting{
+	Columns: map[string]string{
+		"created_at": "created_at",
+		"position":   "position",
+		"start":      "start",
+		"speaker":    "speaker",
+	},
+	Default: "-created_at,position",
+}
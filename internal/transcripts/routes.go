@@ -0,0 +1,10 @@
+package transcripts
+
+import "github.com/labstack/echo/v4"
+
+func RegisterRoutes(g *echo.Group, transcriptService *TranscriptService) {
+	g.GET("/inspections/:id/transcripts", transcriptService.GetInspectionTranscriptsHandler)
+	g.GET("/transcripts", transcriptService.GetTranscriptsHandler)
+	g.GET("/transcripts/segments", transcriptService.GetTranscriptSegmentsHandler)
+	g.GET("/transcripts/:id", transcriptService.GetTranscriptHandler)
+}
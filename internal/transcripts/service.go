@@ -0,0 +1,81 @@
+package transcripts
+
+import (
+	"qc_api/internal/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type TranscriptService struct {
+	DB *gorm.DB
+}
+
+func NewTranscriptService(db *gorm.DB) *TranscriptService {
+	return &TranscriptService{DB: db}
+}
+
+func orderByPosition(db *gorm.DB) *gorm.DB {
+	return db.Order("position ASC")
+}
+
+// === Transcripts ===
+
+// SaveTranscript stores the transcript of an upload, numbering its segments
+// in order. A transcript saved earlier for the same upload, e.g. by an
+// attempt that failed later on, is replaced.
+func (s *TranscriptService) SaveTranscript(transcript *Transcript) error {
+	for i := range transcript.Segments {
+		transcript.Segments[i].Position = i
+	}
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		var earlier []uuid.UUID
+		if err := tx.Unscoped().Model(&Transcript{}).Where("upload_id = ?", transcript.UploadID).Pluck("id", &earlier).Error; err != nil {
+			return err
+		}
+		if len(earlier) > 0 {
+			if err := tx.Unscoped().Where("transcript_id IN ?", earlier).Delete(&TranscriptSegment{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Where("id IN ?", earlier).Delete(&Transcript{}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(transcript).Error
+	})
+}
+
+// GetTranscripts returns a page of the transcripts matching filter, by default
+// newest first, without their segments.
+func (s *TranscriptService) GetTranscripts(filter TranscriptFilter) (*utils.Page[Transcript], error) {
+	query := utils.ApplyFilter(s.DB.Model(&Transcript{}), filter)
+	return utils.Paginate[Transcript](query, filter.PageParams, transcriptSorting)
+}
+
+func (s *TranscriptService) GetTranscriptByID(id uuid.UUID) (*Transcript, error) {
+	var transcript Transcript
+	result := s.DB.Preload("Segments", orderByPosition).Where("id = ?", id).First(&transcript)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &transcript, nil
+}
+
+// GetInspectionTranscripts returns the transcripts of an inspection's
+// recordings, oldest first, with their segments.
+func (s *TranscriptService) GetInspectionTranscripts(inspectionID uuid.UUID) ([]Transcript, error) {
+	transcripts := []Transcript{}
+	result := s.DB.Preload("Segments", orderByPosition).Where("inspection_id = ?", inspectionID).Order("created_at ASC").Find(&transcripts)
+	return transcripts, result.Error
+}
+
+// SearchSegments returns a page of the segments matching filter, by default
+// from the newest transcripts first and in spoken order within each.
+func (s *TranscriptService) SearchSegments(filter SegmentFilter) (*utils.Page[SegmentMatch], error) {
+	query := s.DB.Model(&TranscriptSegment{}).
+		Joins("JOIN transcripts ON transcripts.id = transcript_segments.transcript_id AND transcripts.deleted_at IS NULL")
+	query = utils.ApplyFilter(query, filter)
+	return utils.Paginate[SegmentMatch](query, filter.PageParams, segmentSorting, func(db *gorm.DB) *gorm.DB {
+		return db.Select("transcript_segments.*, transcripts.upload_id, transcripts.inspection_id")
+	})
+}
@@ -0,0 +1,201 @@
+package transcripts_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"qc_api/internal/transcripts"
+	"qc_api/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB() *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		panic("failed to connect database")
+	}
+	if err := db.AutoMigrate(transcripts.Models()...); err != nil {
+		panic("failed to migrate database")
+	}
+	return db
+}
+
+func saveTranscript(t *testing.T, service *transcripts.TranscriptService, inspectionID uuid.UUID, segments ...string) *transcripts.Transcript {
+	transcript := &transcripts.Transcript{UploadID: uuid.New(), InspectionID: inspectionID, Language: "en"}
+	for i, text := range segments {
+		transcript.Text += text + " "
+		transcript.Segments = append(transcript.Segments, transcripts.TranscriptSegment{
+			Start: float64(i * 5),
+			End:   float64(i*5 + 5),
+			Text:  text,
+		})
+	}
+	require.NoError(t, service.SaveTranscript(transcript))
+	return transcript
+}
+
+func TestSaveTranscript(t *testing.T) {
+	// Setup
+	service := transcripts.NewTranscriptService(setupTestDB())
+	first := saveTranscript(t, service, uuid.New(), "first try")
+
+	// Saving the same upload again replaces the earlier transcript
+	second := &transcripts.Transcript{
+		UploadID:     first.UploadID,
+		InspectionID: first.InspectionID,
+		Text:         "spill kit present. PPE worn.",
+		Duration:     6,
+		Segments: []transcripts.TranscriptSegment{
+			{Start: 3, End: 6, Speaker: "SPEAKER_01", Text: "PPE worn."},
+			{Start: 0, End: 3, Speaker: "SPEAKER_00", Text: "spill kit present."},
+		},
+	}
+	require.NoError(t, service.SaveTranscript(second))
+
+	// Assertions
+	_, err := service.GetTranscriptByID(first.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	var segments int64
+	require.NoError(t, service.DB.Model(&transcripts.TranscriptSegment{}).Count(&segments).Error)
+	assert.Equal(t, int64(2), segments)
+
+	saved, err := service.GetTranscriptByID(second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 6.0, saved.Duration)
+	require.Len(t, saved.Segments, 2)
+	assert.Equal(t, []int{0, 1}, []int{saved.Segments[0].Position, saved.Segments[1].Position})
+	assert.Equal(t, "PPE worn.", saved.Segments[0].Text)
+	assert.Equal(t, "SPEAKER_00", saved.Segments[1].Speaker)
+}
+
+func TestGetTranscripts(t *testing.T) {
+	// Setup
+	service := transcripts.NewTranscriptService(setupTestDB())
+	inspectionID := uuid.New()
+	saveTranscript(t, service, inspectionID, "Spreader set to 100% output")
+	saveTranscript(t, service, inspectionID, "Spreader set to 1000 rpm")
+	saveTranscript(t, service, uuid.New(), "Spill kit missing")
+
+	query := func(s string) *string { return &s }
+	tests := []struct {
+		name     string
+		filter   transcripts.TranscriptFilter
+		expected int
+	}{
+		{"all", transcripts.TranscriptFilter{}, 3},
+		{"inspection", transcripts.TranscriptFilter{InspectionID: &inspectionID}, 2},
+		{"text ignores case", transcripts.TranscriptFilter{Query: query("spreader")}, 2},
+		{"wildcards are literal", transcripts.TranscriptFilter{Query: query("100%")}, 1},
+		{"text and inspection", transcripts.TranscriptFilter{InspectionID: &inspectionID, Query: query("spill")}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := service.GetTranscripts(tt.filter)
+
+			require.NoError(t, err)
+			assert.Len(t, found.Data, tt.expected)
+			assert.Equal(t, int64(tt.expected), found.Total)
+			for _, transcript := range found.Data {
+				assert.Empty(t, transcript.Segments)
+			}
+		})
+	}
+
+	_, err := service.GetTranscripts(transcripts.TranscriptFilter{PageParams: utils.PageParams{Sort: "text"}})
+	assert.ErrorIs(t, err, utils.ErrInvalidQuery)
+}
+
+func TestSearchSegments(t *testing.T) {
+	// Setup
+	service := transcripts.NewTranscriptService(setupTestDB())
+	e := echo.New()
+	inspectionID := uuid.New()
+	transcript := saveTranscript(t, service, inspectionID, "Arrived on site", "Spill kit present", "Spill cleaned up")
+	saveTranscript(t, service, uuid.New(), "No spill kit in truck")
+
+	// Execution
+	req := httptest.NewRequest(http.MethodGet, "/transcripts/segments?q=spill&inspection_id="+inspectionID.String(), nil)
+	rec := httptest.NewRecorder()
+	err := service.GetTranscriptSegmentsHandler(e.NewContext(req, rec))
+
+	// Assertions
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var page utils.Page[transcripts.SegmentMatch]
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	matches := page.Data
+	require.Len(t, matches, 2)
+	assert.Equal(t, "Spill kit present", matches[0].Text)
+	assert.Equal(t, 5.0, matches[0].Start)
+	assert.Equal(t, 10.0, matches[0].End)
+	assert.Equal(t, transcript.ID, matches[0].TranscriptID)
+	assert.Equal(t, transcript.UploadID, matches[0].UploadID)
+	assert.Equal(t, inspectionID, matches[0].InspectionID)
+	assert.Equal(t, "Spill cleaned up", matches[1].Text)
+
+	// Every transcript is searched without filters, a page at a time
+	found, err := service.SearchSegments(transcripts.SegmentFilter{PageParams: utils.PageParams{Limit: 3}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), found.Total)
+	require.Len(t, found.Data, 3)
+	assert.Equal(t, "No spill kit in truck", found.Data[0].Text)
+	found, err = service.SearchSegments(transcripts.SegmentFilter{PageParams: utils.PageParams{Limit: 3, Cursor: found.NextCursor}})
+	require.NoError(t, err)
+	require.Len(t, found.Data, 1)
+	assert.Equal(t, "Spill cleaned up", found.Data[0].Text)
+	assert.Equal(t, inspectionID, found.Data[0].InspectionID)
+
+	_, err = service.SearchSegments(transcripts.SegmentFilter{PageParams: utils.PageParams{Sort: "text"}})
+	assert.ErrorIs(t, err, utils.ErrInvalidQuery)
+}
+
+func TestGetTranscriptHandler(t *testing.T) {
+	// Setup
+	service := transcripts.NewTranscriptService(setupTestDB())
+	e := echo.New()
+	transcript := saveTranscript(t, service, uuid.New(), "one", "two")
+
+	tests := []struct {
+		name         string
+		id           string
+		expectedCode int
+	}{
+		{"found", transcript.ID.String(), http.StatusOK},
+		{"not found", uuid.New().String(), http.StatusNotFound},
+		{"invalid id", "nope", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+			c.SetParamNames("id")
+			c.SetParamValues(tt.id)
+
+			err := service.GetTranscriptHandler(c)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedCode, rec.Code, rec.Body.String())
+		})
+	}
+
+	// The inspection's transcripts come with their segments
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+	c.SetParamNames("id")
+	c.SetParamValues(transcript.InspectionID.String())
+	require.NoError(t, service.GetInspectionTranscriptsHandler(c))
+	var found []transcripts.Transcript
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &found))
+	require.Len(t, found, 1)
+	assert.Len(t, found[0].Segments, 2)
+}
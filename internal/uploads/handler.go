@@ -0,0 +1,211 @@
+package uploads
+
+import (
+	"errors"
+	"net/http"
+
+	"qc_api/internal/storage"
+	"qc_api/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+var ErrFileTooLarge = errors.New("file exceeds the upload size limit")
+
+func uploadErrorResponse(c echo.Context, err error, notFound string) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, storage.ErrNotFound):
+		return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: notFound})
+	case errors.Is(err, ErrFileTooLarge):
+		return c.JSON(http.StatusRequestEntityTooLarge, utils.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, ErrInvalidOption):
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, ErrUnsupportedType):
+		return c.JSON(http.StatusUnsupportedMediaType, utils.ErrorResponse{Error: err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+}
+
+// PostUploadHandler godoc
+// @Summary Upload an inspection recording
+// @Description Upload an audio recording for an inspection. It is transcribed and turned into a report in the background; poll the returned upload for its status.
+// @Tags uploads
+// @Accept multipart/form-data
+// @Produce json
+// @Param inspection_id formData string true "Inspection ID"
+// @Param file formData file true "Audio recording"
+// @Param lawn_service_id formData string false "Lawn service applied on the visit"
+// @Param prompt_template_id formData string false "Prompt template version for the report, defaults to the default template"
+// @Success 202 {object} Upload
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 413 {object} utils.ErrorResponse
+// @Failure 415 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /uploads [post]
+func (s *UploadService) PostUploadHandler(c echo.Context) error {
+	// Leave headroom for the multipart framing around the file itself.
+	req := c.Request()
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, s.MaxSize+1<<20)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return uploadErrorResponse(c, ErrFileTooLarge, "")
+		}
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "No file uploaded"})
+	}
+	if fileHeader.Size > s.MaxSize {
+		return uploadErrorResponse(c, ErrFileTooLarge, "")
+	}
+	inspectionID, err := uuid.Parse(c.FormValue("inspection_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid inspection_id"})
+	}
+	var opts UploadOptions
+	if opts.LawnServiceID, err = optionalUUID(c, "lawn_service_id"); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid lawn_service_id"})
+	}
+	if opts.PromptTemplateID, err = optionalUUID(c, "prompt_template_id"); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid prompt_template_id"})
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "failed to read upload"})
+	}
+	defer file.Close()
+	uploadedBy, _ := c.Get("user_id").(uuid.UUID)
+
+	upload, err := s.CreateUpload(req.Context(), inspectionID, fileHeader.Filename, file, uploadedBy, opts)
+	if err != nil {
+		return uploadErrorResponse(c, err, "inspection not found")
+	}
+	return c.JSON(http.StatusAccepted, upload)
+}
+
+func optionalUUID(c echo.Context, field string) (*uuid.UUID, error) {
+	value := c.FormValue(field)
+	if value == "" {
+		return nil, nil
+	}
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+// GetUploadsHandler godoc
+// @Summary Get uploads
+// @Description Retrieve recordings, by default newest first, with optional filtering
+// @Tags uploads
+// @Produce json
+// @Param inspection_id query string false "Filter by inspection ID (UUID)"
+// @Param uploaded_by query string false "Filter by uploading user ID (UUID)"
+// @Param status query string false "Filter by status (queued, transcribing, transcribed, generating, complete, failed)"
+// @Param limit query int false "Page size (default 50, at most 200)"
+// @Param offset query int false "Rows to skip"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort keys, - for descending (e.g. -created_at,filename)"
+// @Param fields query string false "Comma separated fields to return (e.g. id,status)"
+// @Success 200 {object} utils.Page[Upload]
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /uploads [get]
+func (s *UploadService) GetUploadsHandler(c echo.Context) error {
+	var filter UploadFilter
+	if err := c.Bind(&filter); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	uploads, err := s.GetUploads(filter)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidQuery) {
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: "failed to retrieve uploads"})
+	}
+	return c.JSON(http.StatusOK, uploads)
+}
+
+// GetUploadHandler godoc
+// @Summary Get upload by ID
+// @Description Retrieve a recording and the status of its processing
+// @Tags uploads
+// @Produce json
+// @Param id path string true "Upload ID"
+// @Success 200 {object} Upload
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /uploads/{id} [get]
+func (s *UploadService) GetUploadHandler(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid upload id"})
+	}
+	upload, err := s.GetUploadByID(id)
+	if err != nil {
+		return uploadErrorResponse(c, err, "upload not found")
+	}
+	return c.JSON(http.StatusOK, upload)
+}
+
+func (s *UploadService) serve(c echo.Context, report bool) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "invalid upload id"})
+	}
+	upload, err := s.GetUploadByID(id)
+	if err != nil {
+		return uploadErrorResponse(c, err, "upload not found")
+	}
+	content, err := s.OpenOutput(c.Request().Context(), upload, report)
+	if err != nil {
+		if report {
+			return uploadErrorResponse(c, err, "report not generated yet")
+		}
+		return uploadErrorResponse(c, err, "transcript not generated yet")
+	}
+	defer content.Close()
+	if report {
+		return c.Stream(http.StatusOK, echo.MIMEApplicationJSON, content)
+	}
+	return c.Stream(http.StatusOK, echo.MIMETextPlainCharsetUTF8, content)
+}
+
+// GetUploadTranscriptHandler godoc
+// @Summary Get upload transcript
+// @Description Download the transcript of a recording once transcription has finished
+// @Tags uploads
+// @Produce plain
+// @Param id path string true "Upload ID"
+// @Success 200 {string} string
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /uploads/{id}/transcript [get]
+func (s *UploadService) GetUploadTranscriptHandler(c echo.Context) error {
+	return s.serve(c, false)
+}
+
+// GetUploadReportHandler godoc
+// @Summary Get upload report
+// @Description Download the inspection extracted from a recording. The same values are saved to the upload's inspection while it is a draft.
+// @Tags uploads
+// @Produce json
+// @Param id path string true "Upload ID"
+// @Success 200 {object} inspections.InspectionDTO
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Security BearerAuth
+// @Router /uploads/{id}/report [get]
+func (s *UploadService) GetUploadReportHandler(c echo.Context) error {
+	return s.serve(c, true)
+}
@@ -0,0 +1,78 @@
+package uploads
+
+import (
+	"qc_api/internal/db"
+	"qc_api/internal/utils"
+
+	"github.com/google/uuid"
+)
+
+func Models() []any {
+	return []any{
+		&Upload{},
+	}
+}
+
+type UploadStatus string
+
+const (
+	UploadQueued       UploadStatus = "queued"
+	UploadTranscribing UploadStatus = "transcribing"
+	UploadTranscribed  UploadStatus = "transcribed"
+	UploadGenerating   UploadStatus = "generating"
+	UploadComplete     UploadStatus = "complete"
+	UploadFailed       UploadStatus = "failed"
+)
+
+// Upload is an audio recording made during an inspection. It is transcribed
+// and turned into a report in the background; JobID is the job currently
+// working on it and ReportJobID the report job waiting on the transcription.
+// The report is saved to the inspection while it is still a draft, for an
+// inspector to review and submit.
+type Upload struct {
+	db.BaseModel
+	InspectionID  uuid.UUID    `gorm:"type:string;index;not null" json:"inspection_id"`
+	UploadedBy    uuid.UUID    `gorm:"type:string;index" json:"uploaded_by"`
+	Filename      string       `json:"filename"`     //sanitized client filename, for display only
+	ContentType   string       `json:"content_type"` //sniffed from the content
+	Size          int64        `json:"size"`
+	Key           string       `gorm:"not null" json:"-"`
+	TranscriptKey string       `json:"-"`
+	ReportKey     string       `json:"-"`
+	Status        UploadStatus `gorm:"index;not null" json:"status"`
+	JobID         uuid.UUID    `gorm:"type:string" json:"job_id"`
+	ReportJobID   uuid.UUID    `gorm:"type:string" json:"report_job_id"`
+	Error         string       `json:"error,omitempty"`
+
+	LawnServiceID *uuid.UUID `gorm:"type:string" json:"lawn_service_id,omitempty"`
+	TranscriptID  *uuid.UUID `gorm:"type:string;index" json:"transcript_id,omitempty"`
+	// PromptTemplateID is the prompt template version the report was generated with
+	PromptTemplateID *uuid.UUID `gorm:"type:string;index" json:"prompt_template_id,omitempty"`
+}
+
+// UploadOptions are optional settings for processing an upload.
+type UploadOptions struct {
+	LawnServiceID    *uuid.UUID // the service applied on the visit, given to the prompt
+	PromptTemplateID *uuid.UUID // prompt template version for the report; nil uses the default
+}
+
+type UploadFilter struct {
+	InspectionID *uuid.UUID    `json:"inspection_id,omitempty" query:"inspection_id"`
+	UploadedBy   *uuid.UUID    `json:"uploaded_by,omitempty" query:"uploaded_by"`
+	Status       *UploadStatus `json:"status,omitempty" query:"status"`
+	utils.PageParams
+}
+
+// uploadSorting lists what uploads can be sorted by.
+var uploadSorting = utils.Sorting{
+	Columns: map[string]string{
+		"created_at":    "created_at",
+		"updated_at":    "updated_at",
+		"inspection_id": "inspection_id",
+		"uploaded_by":   "uploaded_by",
+		"filename":      "filename",
+		"size":          "size",
+		"status":        "status",
+	},
+	Default: "-created_at",
+}
@@ -0,0 +1,11 @@
+package uploads
+
+import "github.com/labstack/echo/v4"
+
+func RegisterRoutes(g *echo.Group, uploadService *UploadService) {
+	g.POST("/uploads", uploadService.PostUploadHandler)
+	g.GET("/uploads", uploadService.GetUploadsHandler)
+	g.GET("/uploads/:id", uploadService.GetUploadHandler)
+	g.GET("/uploads/:id/transcript", uploadService.GetUploadTranscriptHandler)
+	g.GET("/uploads/:id/report", uploadService.GetUploadReportHandler)
+}
@@ -0,0 +1,527 @@
+package uploads
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"qc_api/internal/ReportGenerator"
+	"qc_api/internal/inspections"
+	"qc_api/internal/jobqueue"
+	"qc_api/internal/storage"
+	"qc_api/internal/transcription"
+	"qc_api/internal/transcripts"
+	"qc_api/internal/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrUnsupportedType = errors.New("unsupported audio type")
+var ErrInvalidOption = errors.New("invalid upload option")
+var ErrNotDraft = errors.New("inspection is no longer a draft")
+
+// DefaultMaxSize is the largest recording accepted unless configured otherwise.
+const DefaultMaxSize = 200 << 20
+
+// allowedContentTypes are the sniffed types accepted as recordings. Phones
+// save m4a files, which sniff as MP4 containers.
+var allowedContentTypes = map[string]bool{
+	"audio/mpeg":      true,
+	"audio/wave":      true,
+	"audio/aiff":      true,
+	"audio/basic":     true,
+	"audio/ogg":       true,
+	"application/ogg": true,
+	"video/mp4":       true,
+	"video/webm":      true,
+}
+
+// UploadService stores uploaded audio and the transcripts and reports
+// generated from it.
+type UploadService struct {
+	DB          *gorm.DB
+	Store       storage.Storage
+	Transcriber transcription.Transcriber
+	Transcripts *transcripts.TranscriptService
+	Reports     *ReportGenerator.ReportService
+	Inspections *inspections.InspectionService
+	Jobs        *jobqueue.Queue
+	MaxSize     int64
+}
+
+// Retry policies for the upload jobs. Transcription is slow and usually fails
+// for reasons that take a while to fix, so it is retried less eagerly.
+var (
+	TranscriptionRetryPolicy = jobqueue.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Minute, MaxDelay: 30 * time.Minute}
+	ReportRetryPolicy        = jobqueue.RetryPolicy{MaxAttempts: 5, BaseDelay: 30 * time.Second, MaxDelay: 10 * time.Minute}
+)
+
+// NewUploadService registers the transcription and report jobs with queue.
+func NewUploadService(db *gorm.DB, store storage.Storage, transcriber transcription.Transcriber, transcriptService *transcripts.TranscriptService, reports *ReportGenerator.ReportService, inspectionService *inspections.InspectionService, queue *jobqueue.Queue) *UploadService {
+	s := &UploadService{
+		DB:          db,
+		Store:       store,
+		Transcriber: transcriber,
+		Transcripts: transcriptService,
+		Reports:     reports,
+		Inspections: inspectionService,
+		Jobs:        queue,
+		MaxSize:     DefaultMaxSize,
+	}
+	queue.Register(jobqueue.Transcription, s.processTranscription, TranscriptionRetryPolicy)
+	queue.Register(jobqueue.ReportGeneration, s.processReport, ReportRetryPolicy)
+	queue.OnDead(jobqueue.Transcription, s.jobDead)
+	queue.OnDead(jobqueue.ReportGeneration, s.jobDead)
+	queue.SetResultLoader(jobqueue.Transcription, s.jobResult)
+	queue.SetResultLoader(jobqueue.ReportGeneration, s.jobResult)
+	return s
+}
+
+// transcriptKey and reportKey derive output keys from an upload key, e.g.
+// uploads/<id>.m4a -> transcriptions/<id>.txt and reports/<id>.json
+func transcriptKey(uploadKey string) string {
+	return "transcriptions/" + strings.TrimSuffix(path.Base(uploadKey), path.Ext(uploadKey)) + ".txt"
+}
+
+func reportKey(uploadKey string) string {
+	return "reports/" + strings.TrimSuffix(path.Base(uploadKey), path.Ext(uploadKey)) + ".json"
+}
+
+func sniffContentType(head []byte) string {
+	contentType := http.DetectContentType(head)
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	return contentType
+}
+
+// sanitizeFilename keeps only the base name of a client supplied filename,
+// dropping control characters so it is safe to display.
+func sanitizeFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	if len(name) > 255 {
+		name = name[len(name)-255:]
+	}
+	if name == "" || name == "." || name == ".." || name == "/" {
+		return "recording"
+	}
+	return name
+}
+
+func (s *UploadService) checkInspection(id uuid.UUID) error {
+	return s.checkExists("inspections", id)
+}
+
+func (s *UploadService) checkExists(table string, id uuid.UUID) error {
+	var count int64
+	if err := s.DB.Table(table).Where("id = ? AND deleted_at IS NULL", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (s *UploadService) checkOptions(opts UploadOptions) error {
+	if opts.LawnServiceID != nil {
+		if err := s.checkExists("lawn_services", *opts.LawnServiceID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: lawn service %s not found", ErrInvalidOption, opts.LawnServiceID)
+			}
+			return err
+		}
+	}
+	if opts.PromptTemplateID != nil {
+		if err := s.checkExists("prompt_templates", *opts.PromptTemplateID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: prompt template %s not found", ErrInvalidOption, opts.PromptTemplateID)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// === Uploads ===
+
+// CreateUpload stores the recording against an inspection and queues its
+// transcription.
+func (s *UploadService) CreateUpload(ctx context.Context, inspectionID uuid.UUID, filename string, r io.Reader, uploadedBy uuid.UUID, opts UploadOptions) (*Upload, error) {
+	if err := s.checkInspection(inspectionID); err != nil {
+		return nil, err
+	}
+	if err := s.checkOptions(opts); err != nil {
+		return nil, err
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	head = head[:n]
+	contentType := sniffContentType(head)
+	if n == 0 || !allowedContentTypes[contentType] {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, contentType)
+	}
+
+	// The client's filename is only used for its extension
+	key := storage.NewKey("uploads", filename)
+	counter := &countingReader{r: io.MultiReader(bytes.NewReader(head), r)}
+	if err := s.Store.Put(ctx, key, counter); err != nil {
+		return nil, err
+	}
+
+	upload := &Upload{
+		InspectionID:  inspectionID,
+		UploadedBy:    uploadedBy,
+		Filename:      sanitizeFilename(filename),
+		ContentType:   contentType,
+		Size:          counter.n,
+		Key:           key,
+		Status:        UploadQueued,
+		LawnServiceID: opts.LawnServiceID,
+	}
+	// The upload is stored with its jobs, so it is never left without them.
+	// The report job waits for the transcription to succeed.
+	err = s.Jobs.Transaction(s.DB, func(tx *gorm.DB, jobs *jobqueue.Queue) error {
+		if err := tx.Create(upload).Error; err != nil {
+			return err
+		}
+		job, err := jobs.Enqueue(jobqueue.Transcription, "Transcribe "+upload.Filename, jobPayload{UploadID: upload.ID})
+		if err != nil {
+			return err
+		}
+		reportPayload := jobPayload{UploadID: upload.ID, PromptTemplateID: opts.PromptTemplateID}
+		reportJob, err := jobs.EnqueueAfter(job.ID, jobqueue.ReportGeneration, "Report for "+upload.Filename, reportPayload)
+		if err != nil {
+			return err
+		}
+		upload.JobID, upload.ReportJobID = job.ID, reportJob.ID
+		return tx.Model(upload).Updates(map[string]any{"job_id": job.ID, "report_job_id": reportJob.ID}).Error
+	})
+	if err != nil {
+		if err := s.Store.Delete(ctx, key); err != nil {
+			log.Printf("failed to remove upload %s: %v", key, err)
+		}
+		return nil, err
+	}
+	return upload, nil
+}
+
+func (s *UploadService) GetUploads(filter UploadFilter) (*utils.Page[Upload], error) {
+	query := utils.ApplyFilter(s.DB.Model(&Upload{}), filter)
+	return utils.Paginate[Upload](query, filter.PageParams, uploadSorting)
+}
+
+func (s *UploadService) GetUploadByID(id uuid.UUID) (*Upload, error) {
+	var upload Upload
+	if err := s.DB.Where("id = ?", id).First(&upload).Error; err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// OpenOutput returns the upload's transcript, or its report once generated.
+func (s *UploadService) OpenOutput(ctx context.Context, upload *Upload, report bool) (io.ReadCloser, error) {
+	key := upload.TranscriptKey
+	if report {
+		key = upload.ReportKey
+	}
+	if key == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return s.Store.Get(ctx, key)
+}
+
+// === Processing ===
+
+func (s *UploadService) setStatus(id uuid.UUID, updates map[string]any) error {
+	return s.DB.Model(&Upload{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// jobDead records why processing stopped once a job has no attempts left.
+func (s *UploadService) jobDead(job *jobqueue.Job) {
+	upload, err := s.jobUpload(job)
+	if err != nil {
+		log.Printf("failed to find upload for dead job %s: %v", job.ID, err)
+		return
+	}
+	if err := s.setStatus(upload.ID, map[string]any{"status": UploadFailed, "error": job.Error, "job_id": job.ID}); err != nil {
+		log.Printf("failed to record upload %s failure: %v", upload.ID, err)
+	}
+}
+
+// jobResult loads what a job stored, for the final event of its stream: the
+// transcript text, or the report as JSON.
+func (s *UploadService) jobResult(ctx context.Context, job *jobqueue.Job) (any, error) {
+	r, err := s.Store.Get(ctx, job.Result)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if job.Type == jobqueue.ReportGeneration {
+		return json.RawMessage(data), nil
+	}
+	return string(data), nil
+}
+
+// jobPayload is the input of both upload jobs. PromptTemplateID selects the
+// report's prompt template.
+type jobPayload struct {
+	UploadID         uuid.UUID  `json:"upload_id"`
+	PromptTemplateID *uuid.UUID `json:"prompt_template_id,omitempty"`
+}
+
+func decodePayload(job *jobqueue.Job) (jobPayload, error) {
+	var payload jobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return payload, jobqueue.Permanent(fmt.Errorf("invalid job payload: %w", err))
+	}
+	return payload, nil
+}
+
+// jobUpload loads the job's upload. Neither a bad payload nor a deleted upload
+// is fixed by retrying.
+func (s *UploadService) jobUpload(job *jobqueue.Job) (*Upload, error) {
+	payload, err := decodePayload(job)
+	if err != nil {
+		return nil, err
+	}
+	upload, err := s.GetUploadByID(payload.UploadID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, jobqueue.Permanent(err)
+	}
+	return upload, err
+}
+
+// promptNames looks up how the prompt refers to the inspected employee and
+// the upload's lawn service. Either is left empty when unknown.
+func (s *UploadService) promptNames(upload *Upload) (employee, lawnService string, err error) {
+	var person struct{ FirstName, LastName string }
+	err = s.DB.Table("employees").
+		Joins("JOIN inspections ON inspections.employee_id = employees.id").
+		Where("inspections.id = ?", upload.InspectionID).
+		Select("employees.first_name, employees.last_name").
+		Take(&person).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", "", err
+	}
+	employee = strings.TrimSpace(person.FirstName + " " + person.LastName)
+
+	if upload.LawnServiceID != nil {
+		var service struct{ Code, Description string }
+		err = s.DB.Table("lawn_services").Where("id = ?", upload.LawnServiceID).Select("code, description").Take(&service).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", "", err
+		}
+		if service.Code != "" {
+			lawnService = service.Code + " " + service.Description
+		}
+	}
+	return employee, lawnService, nil
+}
+
+func (s *UploadService) processTranscription(ctx context.Context, job *jobqueue.Job) (string, error) {
+	upload, err := s.jobUpload(job)
+	if err != nil {
+		return "", err
+	}
+	id := upload.ID
+	if err := s.setStatus(id, map[string]any{"status": UploadTranscribing, "error": "", "job_id": job.ID}); err != nil {
+		return "", err
+	}
+	output := transcriptKey(upload.Key)
+	result, err := s.transcribe(ctx, upload.Key, output)
+	job.Log = result.Log
+	var transcript *transcripts.Transcript
+	if err == nil {
+		transcript, err = s.saveTranscript(upload, result)
+	}
+	if err != nil {
+		if updateErr := s.setStatus(id, map[string]any{"error": err.Error()}); updateErr != nil {
+			log.Printf("failed to record upload %s error: %v", id, updateErr)
+		}
+		return "", err
+	}
+
+	// Point at the report job, which can run now
+	return output, s.setStatus(id, map[string]any{
+		"status":         UploadTranscribed,
+		"transcript_key": output,
+		"transcript_id":  transcript.ID,
+		"job_id":         upload.ReportJobID,
+	})
+}
+
+// saveTranscript records the transcription of upload, linked to its inspection.
+func (s *UploadService) saveTranscript(upload *Upload, result transcription.Result) (*transcripts.Transcript, error) {
+	transcript := &transcripts.Transcript{
+		UploadID:     upload.ID,
+		InspectionID: upload.InspectionID,
+		Text:         result.Text,
+		Language:     result.Language,
+		Duration:     result.Duration,
+	}
+	for _, segment := range result.Segments {
+		transcript.Segments = append(transcript.Segments, transcripts.TranscriptSegment{
+			Start:   segment.Start,
+			End:     segment.End,
+			Speaker: segment.Speaker,
+			Text:    segment.Text,
+		})
+	}
+	if err := s.Transcripts.SaveTranscript(transcript); err != nil {
+		return nil, fmt.Errorf("failed to save transcript: %w", err)
+	}
+	return transcript, nil
+}
+
+func (s *UploadService) processReport(ctx context.Context, job *jobqueue.Job) (string, error) {
+	upload, err := s.jobUpload(job)
+	if err != nil {
+		return "", err
+	}
+	id := upload.ID
+	if upload.TranscriptKey == "" {
+		return "", jobqueue.Permanent(errors.New("upload has no transcript"))
+	}
+	if err := s.setStatus(id, map[string]any{"status": UploadGenerating, "error": "", "job_id": job.ID}); err != nil {
+		return "", err
+	}
+	payload, err := decodePayload(job)
+	if err != nil {
+		return "", err
+	}
+	employee, lawnService, err := s.promptNames(upload)
+	if err != nil {
+		return "", err
+	}
+
+	output := reportKey(upload.Key)
+	report, err := s.Reports.ExtractInspection(ctx, ReportGenerator.ReportRequest{
+		TranscriptKey: upload.TranscriptKey,
+		OutputKey:     output,
+		TemplateID:    payload.PromptTemplateID,
+		Employee:      employee,
+		LawnService:   lawnService,
+		Output:        s.Jobs.Output(job.ID),
+	})
+	if err == nil {
+		err = s.applyReport(upload, report.Inspection)
+	}
+	if err != nil {
+		// None of these are fixed by retrying with the same template
+		if errors.Is(err, ReportGenerator.ErrEmptyTranscript) || errors.Is(err, ReportGenerator.ErrInvalidTemplate) || errors.Is(err, gorm.ErrRecordNotFound) {
+			err = jobqueue.Permanent(err)
+		}
+		if updateErr := s.setStatus(id, map[string]any{"error": err.Error()}); updateErr != nil {
+			log.Printf("failed to record upload %s error: %v", id, updateErr)
+		}
+		return "", err
+	}
+	return output, s.setStatus(id, map[string]any{
+		"status":             UploadComplete,
+		"report_key":         output,
+		"prompt_template_id": report.Template.ID,
+	})
+}
+
+// applyReport saves the extracted report, calibration and checklist answers
+// to the upload's inspection, which must still be a draft. The inspection is
+// rescored against its own checklist, so reapplying the same report on a retry
+// changes nothing.
+func (s *UploadService) applyReport(upload *Upload, extraction *inspections.InspectionDTO) error {
+	patch := inspections.InspectionPatch{
+		Report:      &extraction.Report,
+		Calibration: &extraction.Calibration,
+		Answers:     extraction.ChecklistAnswers(),
+	}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		service := s.Inspections.WithDB(tx)
+		inspection, err := service.GetInspectionByID(upload.InspectionID)
+		if err != nil {
+			return err
+		}
+		if inspection.Status != inspections.StatusDraft {
+			return fmt.Errorf("%w: inspection is %s", ErrNotDraft, inspection.Status)
+		}
+		_, err = service.UpdateInspection(inspection.ID, patch)
+		return err
+	})
+	if errors.Is(err, ErrNotDraft) || errors.Is(err, inspections.ErrInvalidAnswer) {
+		return jobqueue.Permanent(err)
+	}
+	return err
+}
+
+// transcribe copies the upload into a scratch directory for the transcriber
+// and stores the transcript text, returning the transcriber's result.
+func (s *UploadService) transcribe(ctx context.Context, key, outputKey string) (transcription.Result, error) {
+	dir, err := os.MkdirTemp("", "transcribe-*")
+	if err != nil {
+		return transcription.Result{}, err
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, path.Base(key))
+	if err := s.download(ctx, key, input); err != nil {
+		return transcription.Result{}, err
+	}
+	result, err := s.Transcriber.Transcribe(ctx, input)
+	if err != nil {
+		return result, err
+	}
+	return result, s.Store.Put(ctx, outputKey, strings.NewReader(result.Text))
+}
+
+func (s *UploadService) download(ctx context.Context, key, dst string) error {
+	src, err := s.Store.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, src); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,508 @@
+package uploads_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"qc_api/internal/ReportGenerator"
+	"qc_api/internal/calibration"
+	"qc_api/internal/employees"
+	inspectionproperties "qc_api/internal/inspectionProperties"
+	"qc_api/internal/inspections"
+	"qc_api/internal/jobqueue"
+	"qc_api/internal/llm"
+	"qc_api/internal/storage"
+	"qc_api/internal/transcription"
+	"qc_api/internal/transcripts"
+	"qc_api/internal/uploads"
+	"qc_api/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB() *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		panic("failed to connect database")
+	}
+	var models []any
+	models = append(models, inspections.Models()...)
+	models = append(models, inspectionproperties.Models()...)
+	models = append(models, uploads.Models()...)
+	models = append(models, transcripts.Models()...)
+	models = append(models, jobqueue.Models()...)
+	models = append(models, employees.Models()...)
+	models = append(models, calibration.Models()...)
+	models = append(models, ReportGenerator.Models()...)
+	if err := db.AutoMigrate(models...); err != nil {
+		panic("failed to migrate database")
+	}
+	if err := inspections.Migrate(db); err != nil {
+		panic("failed to run inspection migrations: " + err.Error())
+	}
+	if err := ReportGenerator.Migrate(db); err != nil {
+		panic("failed to run prompt template migrations: " + err.Error())
+	}
+	return db
+}
+
+// llmReply is the inspection the fake model gives for every prompt, wrapped
+// and malformed the way small models often answer.
+const llmReply = "```json\n" + `{"uniform_ppe_good": "yes", "pic_present": null, "motive_logged_in": true,
+	"podium_logged_in": false, "spill_adsorbtion_present": true, "calibration": 2.5, "report": "No drift",}` + "\n```"
+
+func setupService(t *testing.T) (*uploads.UploadService, storage.Storage) {
+	store, err := storage.NewLocal(t.TempDir())
+	require.NoError(t, err)
+	db := setupTestDB()
+	reports := ReportGenerator.NewReportService(db, &llm.Fake{Reply: llmReply}, store)
+	return uploads.NewUploadService(db, store, &transcription.Fake{Text: "all clear"}, transcripts.NewTranscriptService(db), reports, inspections.NewInspectionService(db), jobqueue.NewQueue(db)), store
+}
+
+func uploadContext(t *testing.T, fields map[string]string, filename string, content []byte, userID uuid.UUID) (echo.Context, *httptest.ResponseRecorder) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for name, value := range fields {
+		require.NoError(t, w.WriteField(name, value))
+	}
+	part, err := w.CreateFormFile("file", filename)
+	require.NoError(t, err)
+	_, err = part.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/uploads", &body)
+	req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("user_id", userID)
+	return c, rec
+}
+
+func idContext(target, id string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, target, nil), rec)
+	c.SetParamNames("id")
+	c.SetParamValues(id)
+	return c, rec
+}
+
+var mp3 = append([]byte("ID3\x03\x00\x00\x00\x00\x00\x00"), make([]byte, 256)...)
+
+func TestPostUpload(t *testing.T) {
+	// Setup
+	service, store := setupService(t)
+	service.MaxSize = 4 << 10
+	inspection, err := inspections.NewInspectionService(service.DB).CreateInspection(&inspections.Inspection{Report: "ok", EmployeeID: uuid.New()})
+	require.NoError(t, err)
+	userID := uuid.New()
+
+	fields := map[string]string{"inspection_id": inspection.ID.String()}
+
+	tests := []struct {
+		name         string
+		fields       map[string]string
+		filename     string
+		content      []byte
+		expectedCode int
+	}{
+		{"recording", fields, "../../etc/visit.mp3", mp3, http.StatusAccepted},
+		{"type is sniffed, not taken from the name", fields, "visit.mp3", []byte("#!/bin/sh\nrm -rf /\n"), http.StatusUnsupportedMediaType},
+		{"too large", fields, "long.mp3", append(mp3, make([]byte, 5<<10)...), http.StatusRequestEntityTooLarge},
+		{"missing inspection id", nil, "visit.mp3", mp3, http.StatusBadRequest},
+		{"unknown inspection", map[string]string{"inspection_id": uuid.New().String()}, "visit.mp3", mp3, http.StatusNotFound},
+		{"invalid prompt template id", map[string]string{"inspection_id": inspection.ID.String(), "prompt_template_id": "v2"}, "visit.mp3", mp3, http.StatusBadRequest},
+		{"unknown prompt template", map[string]string{"inspection_id": inspection.ID.String(), "prompt_template_id": uuid.New().String()}, "visit.mp3", mp3, http.StatusBadRequest},
+		{"unknown lawn service", map[string]string{"inspection_id": inspection.ID.String(), "lawn_service_id": uuid.New().String()}, "visit.mp3", mp3, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, rec := uploadContext(t, tt.fields, tt.filename, tt.content, userID)
+
+			err := service.PostUploadHandler(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedCode, rec.Code, rec.Body.String())
+		})
+	}
+
+	// Only the accepted recording is kept, under a generated key
+	list, err := service.GetUploads(uploads.UploadFilter{InspectionID: &inspection.ID})
+	require.NoError(t, err)
+	require.Len(t, list.Data, 1)
+	upload := list.Data[0]
+	assert.Equal(t, userID, upload.UploadedBy)
+	assert.Equal(t, "visit.mp3", upload.Filename)
+	assert.Equal(t, "audio/mpeg", upload.ContentType)
+	assert.Equal(t, int64(len(mp3)), upload.Size)
+	assert.Equal(t, uploads.UploadQueued, upload.Status)
+	assert.NotEqual(t, uuid.Nil, upload.JobID)
+
+	keys, err := store.List(context.Background(), "")
+	require.NoError(t, err)
+	require.Equal(t, []string{upload.Key}, keys)
+	assert.True(t, strings.HasPrefix(upload.Key, "uploads/"))
+	assert.True(t, strings.HasSuffix(upload.Key, ".mp3"))
+	assert.NotContains(t, upload.Key, "visit")
+}
+
+func TestPostUploadRollback(t *testing.T) {
+	// Setup: recording the jobs on the upload fails
+	service, store := setupService(t)
+	ctx := context.Background()
+	inspection, err := service.Inspections.CreateInspection(&inspections.Inspection{Report: "ok", EmployeeID: uuid.New()})
+	require.NoError(t, err)
+	require.NoError(t, service.DB.Callback().Update().Before("gorm:update").Register("test:fail_jobs", func(tx *gorm.DB) {
+		if updates, ok := tx.Statement.Dest.(map[string]any); ok && updates["report_job_id"] != nil {
+			tx.AddError(errors.New("database is locked"))
+		}
+	}))
+
+	_, err = service.CreateUpload(ctx, inspection.ID, "visit.mp3", bytes.NewReader(mp3), uuid.New(), uploads.UploadOptions{})
+
+	// Nothing is left behind
+	assert.EqualError(t, err, "database is locked")
+	for _, model := range []any{&uploads.Upload{}, &jobqueue.Job{}} {
+		var count int64
+		require.NoError(t, service.DB.Model(model).Count(&count).Error)
+		assert.Zero(t, count)
+	}
+	keys, err := store.List(ctx, "")
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func TestGetUpload(t *testing.T) {
+	// Setup
+	service, store := setupService(t)
+	inspection, err := inspections.NewInspectionService(service.DB).CreateInspection(&inspections.Inspection{Report: "ok", EmployeeID: uuid.New()})
+	require.NoError(t, err)
+	upload, err := service.CreateUpload(context.Background(), inspection.ID, "visit.mp3", bytes.NewReader(mp3), uuid.New(), uploads.UploadOptions{})
+	require.NoError(t, err)
+
+	// Polling shows the queued job
+	c, rec := idContext("/uploads/"+upload.ID.String(), upload.ID.String())
+	require.NoError(t, service.GetUploadHandler(c))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var polled map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &polled))
+	assert.Equal(t, "queued", polled["status"])
+	assert.Equal(t, upload.JobID.String(), polled["job_id"])
+	assert.NotContains(t, polled, "key")
+
+	// No transcript until transcription has run
+	c, rec = idContext("/", upload.ID.String())
+	require.NoError(t, service.GetUploadTranscriptHandler(c))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	require.NoError(t, store.Put(context.Background(), "transcriptions/visit.txt", strings.NewReader("all clear")))
+	require.NoError(t, service.DB.Model(upload).Update("transcript_key", "transcriptions/visit.txt").Error)
+	c, rec = idContext("/", upload.ID.String())
+	require.NoError(t, service.GetUploadTranscriptHandler(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "all clear", rec.Body.String())
+
+	c, rec = idContext("/", upload.ID.String())
+	require.NoError(t, service.GetUploadReportHandler(c))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	c, rec = idContext("/", uuid.New().String())
+	require.NoError(t, service.GetUploadHandler(c))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// Listing pages the uploads and only sorts by listed columns
+	c, rec = idContext("/uploads?inspection_id="+inspection.ID.String()+"&sort=-size", "")
+	require.NoError(t, service.GetUploadsHandler(c))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var page utils.Page[uploads.Upload]
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	assert.Equal(t, int64(1), page.Total)
+	require.Len(t, page.Data, 1)
+	assert.Equal(t, upload.ID, page.Data[0].ID)
+
+	c, rec = idContext("/uploads?sort=key", "")
+	require.NoError(t, service.GetUploadsHandler(c))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestUploadTranscriptionJob(t *testing.T) {
+	// Setup
+	service, store := setupService(t)
+	fake := &transcription.Fake{
+		Text:     "spreader calibrated, no drift",
+		Log:      "loaded model",
+		Language: "en",
+		Duration: 3.5,
+		Segments: []transcription.Segment{
+			{Start: 0, End: 1.5, Speaker: "inspector", Text: "spreader calibrated,"},
+			{Start: 1.5, End: 3.5, Speaker: "inspector", Text: "no drift"},
+		},
+	}
+	service.Transcriber = fake
+	ctx := context.Background()
+	inspection, err := inspections.NewInspectionService(service.DB).CreateInspection(&inspections.Inspection{Report: "ok", EmployeeID: uuid.New()})
+	require.NoError(t, err)
+	upload, err := service.CreateUpload(ctx, inspection.ID, "visit.mp3", bytes.NewReader(mp3), uuid.New(), uploads.UploadOptions{})
+	require.NoError(t, err)
+
+	// The job is stored, so it can be polled and survives restarts
+	job, err := service.Jobs.GetJobByID(upload.JobID)
+	require.NoError(t, err)
+	assert.Equal(t, jobqueue.Transcription, job.Type)
+	assert.Equal(t, jobqueue.JobQueued, job.Status)
+	assert.JSONEq(t, `{"upload_id":"`+upload.ID.String()+`"}`, string(job.Payload))
+
+	// Run transcription
+	job, err = service.Jobs.RunNext(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	assert.Equal(t, jobqueue.JobSucceeded, job.Status, job.Error)
+	assert.Equal(t, 1, job.Attempts)
+	assert.NotNil(t, job.FinishedAt)
+	assert.Equal(t, "loaded model", job.Log)
+	require.Len(t, fake.Calls(), 1)
+	assert.Equal(t, mp3, fake.Calls()[0])
+
+	r, err := store.Get(ctx, job.Result)
+	require.NoError(t, err)
+	transcript, _ := io.ReadAll(r)
+	r.Close()
+	assert.Equal(t, "spreader calibrated, no drift", string(transcript))
+
+	// Report generation was queued behind it and the upload now points at it
+	upload, err = service.GetUploadByID(upload.ID)
+	require.NoError(t, err)
+	assert.Equal(t, uploads.UploadTranscribed, upload.Status)
+	assert.Equal(t, upload.ReportJobID, upload.JobID)
+
+	// The transcript is recorded against the inspection with its segments
+	require.NotNil(t, upload.TranscriptID)
+	record, err := service.Transcripts.GetTranscriptByID(*upload.TranscriptID)
+	require.NoError(t, err)
+	assert.Equal(t, upload.ID, record.UploadID)
+	assert.Equal(t, inspection.ID, record.InspectionID)
+	assert.Equal(t, "spreader calibrated, no drift", record.Text)
+	assert.Equal(t, "en", record.Language)
+	assert.Equal(t, 3.5, record.Duration)
+	require.Len(t, record.Segments, 2)
+	assert.Equal(t, 1.5, record.Segments[1].Start)
+	assert.Equal(t, "no drift", record.Segments[1].Text)
+	assert.Equal(t, "inspector", record.Segments[1].Speaker)
+	next, err := service.Jobs.GetJobByID(upload.JobID)
+	require.NoError(t, err)
+	assert.Equal(t, jobqueue.ReportGeneration, next.Type)
+	assert.Equal(t, jobqueue.JobQueued, next.Status)
+	assert.Equal(t, job.ID, *next.DependsOnID)
+
+	// Run report generation
+	job, err = service.Jobs.RunNext(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	assert.Equal(t, jobqueue.JobSucceeded, job.Status, job.Error)
+	upload, err = service.GetUploadByID(upload.ID)
+	require.NoError(t, err)
+	assert.Equal(t, uploads.UploadComplete, upload.Status)
+	r, err = service.OpenOutput(ctx, upload, true)
+	require.NoError(t, err)
+	report, _ := io.ReadAll(r)
+	r.Close()
+	assert.JSONEq(t, `{"uniform_ppe_good": true, "pic_present": null, "motive_logged_in": true,
+		"podium_logged_in": false, "spill_adsorbtion_present": true, "calibration": 2.5, "report": "No drift"}`, string(report))
+
+	// The report records the template version that produced it
+	prompt, err := service.Reports.GetDefaultPromptTemplate()
+	require.NoError(t, err)
+	assert.Equal(t, &prompt.ID, upload.PromptTemplateID)
+
+	// The extracted values were saved to the upload's own draft inspection
+	draft, err := service.Inspections.GetInspectionByID(inspection.ID)
+	require.NoError(t, err)
+	assert.Equal(t, inspections.StatusDraft, draft.Status)
+	assert.Equal(t, inspection.EmployeeID, draft.EmployeeID)
+	assert.Equal(t, "No drift", draft.Report)
+	assert.Equal(t, float32(2.5), draft.Calibration)
+	answers := map[string]bool{}
+	for _, a := range draft.Answers {
+		answers[a.Key] = *a.BoolValue
+	}
+	assert.Equal(t, map[string]bool{
+		inspections.KeyUniformPPEGood:         true,
+		inspections.KeyMotiveLoggedIn:         true,
+		inspections.KeyPodiumLoggedIn:         false,
+		inspections.KeySpillAdsorbtionPresent: true,
+	}, answers)
+}
+
+func TestUploadReportTemplate(t *testing.T) {
+	// Setup
+	service, _ := setupService(t)
+	ctx := context.Background()
+	employee := &employees.Employee{CommonName: "Sam", FirstName: "Sam", LastName: "Lee", EmployeeNumber: "42"}
+	require.NoError(t, service.DB.Create(employee).Error)
+	lawnService := &calibration.LawnService{Code: "LS01", Description: "Spring Fertilizer", TargetCalibrationUnit: "kg"}
+	require.NoError(t, service.DB.Create(lawnService).Error)
+	prompt, err := service.Reports.CreatePromptTemplate(ReportGenerator.PromptTemplateDTO{
+		Name:         "with context",
+		UserTemplate: "{{.Employee}} / {{.LawnService}}\n{{.Transcript}}",
+		Model:        "lawnqc",
+	})
+	require.NoError(t, err)
+	inspection, err := service.Inspections.CreateInspection(&inspections.Inspection{Report: "ok", EmployeeID: employee.ID})
+	require.NoError(t, err)
+
+	// Execute
+	opts := uploads.UploadOptions{LawnServiceID: &lawnService.ID, PromptTemplateID: &prompt.ID}
+	upload, err := service.CreateUpload(ctx, inspection.ID, "visit.mp3", bytes.NewReader(mp3), uuid.New(), opts)
+	require.NoError(t, err)
+	for range 2 {
+		job, err := service.Jobs.RunNext(ctx)
+		require.NoError(t, err)
+		require.Equal(t, jobqueue.JobSucceeded, job.Status, job.Error)
+	}
+
+	// Assertions
+	upload, err = service.GetUploadByID(upload.ID)
+	require.NoError(t, err)
+	assert.Equal(t, &prompt.ID, upload.PromptTemplateID)
+	requests := service.Reports.LLM.(*llm.Fake).Requests()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "Sam Lee / LS01 Spring Fertilizer\nall clear", requests[0].Prompt)
+}
+
+func TestUploadReportRetry(t *testing.T) {
+	// Setup
+	service, _ := setupService(t)
+	ctx := context.Background()
+	inspection, err := service.Inspections.CreateInspection(&inspections.Inspection{Report: "ok", EmployeeID: uuid.New()})
+	require.NoError(t, err)
+	upload, err := service.CreateUpload(ctx, inspection.ID, "visit.mp3", bytes.NewReader(mp3), uuid.New(), uploads.UploadOptions{})
+	require.NoError(t, err)
+	job, err := service.Jobs.RunNext(ctx)
+	require.NoError(t, err)
+	require.Equal(t, jobqueue.JobSucceeded, job.Status, job.Error)
+
+	// Marking the upload complete fails once, after the report was saved
+	failed := false
+	require.NoError(t, service.DB.Callback().Update().Before("gorm:update").Register("test:fail_complete", func(tx *gorm.DB) {
+		if updates, ok := tx.Statement.Dest.(map[string]any); ok && updates["status"] == uploads.UploadComplete && !failed {
+			failed = true
+			tx.AddError(errors.New("database is locked"))
+		}
+	}))
+	job, err = service.Jobs.RunNext(ctx)
+	require.NoError(t, err)
+	require.Equal(t, jobqueue.JobFailed, job.Status)
+	saved, err := service.Inspections.GetInspectionByID(inspection.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "No drift", saved.Report)
+
+	// The retry completes the upload without creating another inspection
+	require.NoError(t, service.DB.Model(job).Update("run_at", time.Now()).Error)
+	job, err = service.Jobs.RunNext(ctx)
+	require.NoError(t, err)
+	require.Equal(t, jobqueue.JobSucceeded, job.Status, job.Error)
+	upload, err = service.GetUploadByID(upload.ID)
+	require.NoError(t, err)
+	assert.Equal(t, uploads.UploadComplete, upload.Status)
+	var count int64
+	require.NoError(t, service.DB.Model(&inspections.Inspection{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+	retried, err := service.Inspections.GetInspectionByID(inspection.ID)
+	require.NoError(t, err)
+	require.Len(t, retried.Answers, len(saved.Answers))
+	for i := range saved.Answers {
+		assert.Equal(t, saved.Answers[i].ID, retried.Answers[i].ID)
+	}
+}
+
+func TestUploadReportSubmittedInspection(t *testing.T) {
+	// Setup
+	service, _ := setupService(t)
+	ctx := context.Background()
+	inspection, err := service.Inspections.CreateInspection(&inspections.Inspection{Report: "ok", EmployeeID: uuid.New()})
+	require.NoError(t, err)
+	upload, err := service.CreateUpload(ctx, inspection.ID, "visit.mp3", bytes.NewReader(mp3), uuid.New(), uploads.UploadOptions{})
+	require.NoError(t, err)
+	job, err := service.Jobs.RunNext(ctx)
+	require.NoError(t, err)
+	require.Equal(t, jobqueue.JobSucceeded, job.Status, job.Error)
+	require.NoError(t, service.DB.Model(&inspections.Inspection{}).Where("id = ?", inspection.ID).Update("status", inspections.StatusSubmitted).Error)
+
+	// Execute
+	job, err = service.Jobs.RunNext(ctx)
+	require.NoError(t, err)
+
+	// Assertions
+	assert.Equal(t, jobqueue.JobDead, job.Status)
+	assert.Contains(t, job.Error, uploads.ErrNotDraft.Error())
+	saved, err := service.Inspections.GetInspectionByID(inspection.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", saved.Report)
+	var count int64
+	require.NoError(t, service.DB.Model(&inspections.Inspection{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+	upload, err = service.GetUploadByID(upload.ID)
+	require.NoError(t, err)
+	assert.Equal(t, uploads.UploadFailed, upload.Status)
+}
+
+func TestUploadTranscriptionFailure(t *testing.T) {
+	// Setup
+	service, _ := setupService(t)
+	service.Transcriber = &transcription.Fake{Err: errors.New("model not found"), Log: "loading ggml-base.bin: no such file"}
+	ctx := context.Background()
+	inspection, err := inspections.NewInspectionService(service.DB).CreateInspection(&inspections.Inspection{Report: "ok", EmployeeID: uuid.New()})
+	require.NoError(t, err)
+	upload, err := service.CreateUpload(ctx, inspection.ID, "visit.mp3", bytes.NewReader(mp3), uuid.New(), uploads.UploadOptions{})
+	require.NoError(t, err)
+
+	// The failure is recorded and a retry scheduled
+	job, err := service.Jobs.RunNext(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, jobqueue.JobFailed, job.Status)
+	assert.Equal(t, "model not found", job.Error)
+	assert.True(t, job.RunAt.After(time.Now()))
+	upload, err = service.GetUploadByID(upload.ID)
+	require.NoError(t, err)
+	assert.Equal(t, uploads.UploadTranscribing, upload.Status)
+	assert.Equal(t, "model not found", upload.Error)
+
+	// Run the remaining attempts without waiting for the backoff
+	for job.Status == jobqueue.JobFailed {
+		require.NoError(t, service.DB.Model(job).Update("run_at", time.Now()).Error)
+		job, err = service.Jobs.RunNext(ctx)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, jobqueue.JobDead, job.Status)
+	assert.Equal(t, uploads.TranscriptionRetryPolicy.MaxAttempts, job.Attempts)
+	assert.Equal(t, "loading ggml-base.bin: no such file", job.Log)
+
+	// The report never runs, and is not left queued
+	upload, err = service.GetUploadByID(upload.ID)
+	require.NoError(t, err)
+	assert.Equal(t, uploads.UploadFailed, upload.Status)
+	assert.Equal(t, "model not found", upload.Error)
+	job, err = service.Jobs.RunNext(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, job)
+	report, err := service.Jobs.GetJobByID(upload.ReportJobID)
+	require.NoError(t, err)
+	assert.Equal(t, jobqueue.JobBlocked, report.Status)
+}
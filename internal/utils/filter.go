@@ -1,15 +1,47 @@
 package utils
 
 import (
+	"errors"
+	"fmt"
 	"reflect"
+	"regexp"
 	"strings"
 	"time"
 	"unicode"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
 )
 
-// ApplyFilter applies filter conditions to a GORM query using reflection
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// Filter operators, chosen with the op option of the filter tag, e.g.
+// `filter:"score,op=gte"`. Without one the operator follows from the field:
+// From/Start and To/End fields bound dates, Min and Max fields bound
+// numbers, slices match any of their values and everything else must equal.
+const (
+	OpEq     = "eq"
+	OpNe     = "ne"
+	OpGt     = "gt"
+	OpGte    = "gte"
+	OpLt     = "lt"
+	OpLte    = "lte"
+	OpIn     = "in"     //a slice, or a string of comma separated values
+	OpNotIn  = "nin"    //a slice, or a string of comma separated values
+	OpLike   = "like"   //contains the text literally
+	OpILike  = "ilike"  //contains the text literally, ignoring case
+	OpIsNull = "isnull" //true for IS NULL, false for IS NOT NULL
+)
+
+var comparisons = map[string]string{OpEq: "=", OpNe: "<>", OpGt: ">", OpGte: ">=", OpLt: "<", OpLte: "<="}
+
+var columnPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// ApplyFilter applies filter conditions to a GORM query using reflection.
+// Columns must be identifiers; unqualified ones, or ones qualified with the
+// query model's table, must be columns of the model. Columns of related
+// records are joined in, see resolveColumn. A filter that breaks these rules
+// adds ErrInvalidFilter to the query.
 func ApplyFilter(query *gorm.DB, filter any) *gorm.DB {
 	v := reflect.ValueOf(filter)
 	t := reflect.TypeOf(filter)
@@ -25,19 +57,28 @@ func ApplyFilter(query *gorm.DB, filter any) *gorm.DB {
 		return query
 	}
 
+	model := modelSchema(query)
 	for i := 0; i < v.NumField(); i++ {
 		field := v.Field(i)
 		fieldType := t.Field(i)
 
-		// Skip unexported fields
-		if !field.CanInterface() {
+		// Skip unexported fields and fields marked filter:"-"
+		if !field.CanInterface() || fieldType.Tag.Get("filter") == "-" {
 			continue
 		}
 
-		// Skip nil pointers
+		// Paging parameters are applied by Paginate
+		if field.Type() == reflect.TypeFor[PageParams]() {
+			continue
+		}
+
+		// Skip nil pointers and empty lists
 		if field.Kind() == reflect.Ptr && field.IsNil() {
 			continue
 		}
+		if field.Kind() == reflect.Slice && field.Len() == 0 {
+			continue
+		}
 
 		// Get the actual value if it's a pointer
 		var value any
@@ -47,41 +88,162 @@ func ApplyFilter(query *gorm.DB, filter any) *gorm.DB {
 			value = field.Interface()
 		}
 
-		// Get column name from tags or field name
-		columnName := getColumnName(fieldType)
+		// Get column name and operator from tags or field name
+		op := getOperator(fieldType, value)
+		var columnName string
+		var err error
+		query, columnName, err = resolveColumn(query, model, getColumnName(fieldType))
+		if err != nil {
+			query.AddError(fmt.Errorf("%w: field %s: %w", ErrInvalidFilter, fieldType.Name, err))
+			return query
+		}
 
-		// Apply appropriate filter based on field name and type
-		fieldName := fieldType.Name
-		switch v := value.(type) {
-		case time.Time:
-			if strings.Contains(fieldName, "From") || strings.HasSuffix(fieldName, "Start") {
-				query = query.Where(columnName+" >= ?", v)
-			} else if strings.Contains(fieldName, "To") || strings.HasSuffix(fieldName, "End") {
-				query = query.Where(columnName+" <= ?", v)
-			} else {
-				query = query.Where(columnName+" = ?", v)
+		condition, args, err := filterCondition(columnName, op, value)
+		if err != nil {
+			query.AddError(fmt.Errorf("%w: field %s: %w", ErrInvalidFilter, fieldType.Name, err))
+			return query
+		}
+		if condition != "" {
+			query = query.Where(condition, args...)
+		}
+	}
+
+	return query
+}
+
+// filterCondition builds the WHERE clause comparing column to value with op,
+// or none for an empty list.
+func filterCondition(column, op string, value any) (string, []any, error) {
+	switch op {
+	case OpIn, OpNotIn:
+		values, err := listValues(value)
+		if err != nil || reflect.ValueOf(values).Len() == 0 {
+			return "", nil, err
+		}
+		if op == OpNotIn {
+			return column + " NOT IN ?", []any{values}, nil
+		}
+		return column + " IN ?", []any{values}, nil
+	case OpLike, OpILike:
+		text := reflect.ValueOf(value)
+		if text.Kind() != reflect.String {
+			return "", nil, fmt.Errorf("%s needs text, got %T", op, value)
+		}
+		if op == OpILike {
+			return "LOWER(" + column + `) LIKE LOWER(?) ESCAPE '\'`, []any{ContainsPattern(text.String())}, nil
+		}
+		return column + ` LIKE ? ESCAPE '\'`, []any{ContainsPattern(text.String())}, nil
+	case OpIsNull:
+		isNull, ok := value.(bool)
+		if !ok {
+			return "", nil, fmt.Errorf("%s needs a bool, got %T", op, value)
+		}
+		if isNull {
+			return column + " IS NULL", nil, nil
+		}
+		return column + " IS NOT NULL", nil, nil
+	}
+
+	comparison, ok := comparisons[op]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown operator %q", op)
+	}
+	date, ok := value.(SimpleDate)
+	if !ok {
+		return column + " " + comparison + " ?", []any{value}, nil
+	}
+
+	// A date covers its whole day
+	startOfDay := date.Time
+	endOfDay := date.Time.AddDate(0, 0, 1).Add(-1 * time.Nanosecond)
+	switch op {
+	case OpEq:
+		return column + " >= ? AND " + column + " <= ?", []any{startOfDay, endOfDay}, nil
+	case OpNe:
+		return "(" + column + " < ? OR " + column + " > ?)", []any{startOfDay, endOfDay}, nil
+	case OpGt, OpLte:
+		return column + " " + comparison + " ?", []any{endOfDay}, nil
+	default:
+		return column + " " + comparison + " ?", []any{startOfDay}, nil
+	}
+}
+
+// listValues returns the values of a slice, or of a string of comma
+// separated values, for an IN clause.
+func listValues(value any) (any, error) {
+	v := reflect.ValueOf(value)
+	switch {
+	case v.Kind() == reflect.String:
+		var values []string
+		for item := range strings.SplitSeq(v.String(), ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
 			}
-		case SimpleDate:
-			if strings.Contains(fieldName, "From") || strings.HasSuffix(fieldName, "Start") {
-				// Start of day: 00:00:00
-				startOfDay := v.Time
-				query = query.Where(columnName+" >= ?", startOfDay)
-			} else if strings.Contains(fieldName, "To") || strings.HasSuffix(fieldName, "End") {
-				// End of day: 23:59:59.999
-				endOfDay := v.Time.AddDate(0, 0, 1).Add(-1 * time.Nanosecond)
-				query = query.Where(columnName+" <= ?", endOfDay)
-			} else {
-				// Exact date match (start to end of day)
-				startOfDay := v.Time
-				endOfDay := v.Time.AddDate(0, 0, 1).Add(-1 * time.Nanosecond)
-				query = query.Where(columnName+" >= ? AND "+columnName+" <= ?", startOfDay, endOfDay)
+		}
+		return values, nil
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String && v.Len() == 1:
+		// A single query parameter may list several values
+		values := reflect.MakeSlice(v.Type(), 0, 1)
+		for item := range strings.SplitSeq(v.Index(0).String(), ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = reflect.Append(values, reflect.ValueOf(item).Convert(v.Type().Elem()))
 			}
-		default:
-			query = query.Where(columnName+" = ?", v)
 		}
+		return values.Interface(), nil
+	case v.Kind() == reflect.Slice:
+		return value, nil
 	}
+	return nil, fmt.Errorf("a list is needed, got %T", value)
+}
 
-	return query
+// ContainsPattern matches text containing query literally in a LIKE clause
+// escaped with '\'.
+func ContainsPattern(query string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + replacer.Replace(query) + "%"
+}
+
+// modelSchema parses the model of query, if it has one.
+func modelSchema(query *gorm.DB) *schema.Schema {
+	if query.Statement == nil || query.Statement.Model == nil {
+		return nil
+	}
+	stmt := &gorm.Statement{DB: query}
+	if err := stmt.Parse(query.Statement.Model); err != nil {
+		return nil
+	}
+	return stmt.Schema
+}
+
+// getOperator returns the operator of the op option in the filter tag,
+// falling back on the field name and value type.
+func getOperator(field reflect.StructField, value any) string {
+	_, options, _ := strings.Cut(field.Tag.Get("filter"), ",")
+	for option := range strings.SplitSeq(options, ",") {
+		if op, ok := strings.CutPrefix(strings.TrimSpace(option), "op="); ok {
+			return op
+		}
+	}
+
+	fieldName := field.Name
+	switch value.(type) {
+	case time.Time, SimpleDate:
+		if strings.Contains(fieldName, "From") || strings.HasSuffix(fieldName, "Start") {
+			return OpGte
+		} else if strings.Contains(fieldName, "To") || strings.HasSuffix(fieldName, "End") {
+			return OpLte
+		}
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		if strings.HasPrefix(fieldName, "Min") {
+			return OpGte
+		} else if strings.HasPrefix(fieldName, "Max") {
+			return OpLte
+		}
+	}
+	if reflect.TypeOf(value).Kind() == reflect.Slice {
+		return OpIn
+	}
+	return OpEq
 }
 
 // getColumnName extracts the database column name from struct field tags
@@ -91,14 +253,15 @@ func getColumnName(field reflect.StructField) string {
 		// Parse gorm tag for column name
 		for part := range strings.SplitSeq(gormTag, ";") {
 			part = strings.TrimSpace(part)
-			result, _ := strings.CutPrefix(part, "column:")
-			return result
+			if column, ok := strings.CutPrefix(part, "column:"); ok {
+				return column
+			}
 		}
 	}
 
 	// Check filter tag for explicit column mapping
-	if filterTag := field.Tag.Get("filter"); filterTag != "" {
-		return filterTag
+	if column, _, _ := strings.Cut(field.Tag.Get("filter"), ","); column != "" {
+		return column
 	}
 
 	// Check json tag
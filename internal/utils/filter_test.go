@@ -1,9 +1,11 @@
 package utils
 
 import (
+	"reflect"
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -98,3 +100,299 @@ func TestApplyFilter_SimpleDate(t *testing.T) {
 		})
 	}
 }
+
+type ScoredRecord struct {
+	ID    uint `gorm:"primaryKey"`
+	Score float64
+}
+
+type ScoredFilter struct {
+	MinScore *float64 `query:"min_score" filter:"score"`
+	MaxScore *float64 `query:"max_score" filter:"score"`
+	Sort     string   `query:"sort" filter:"-"`
+}
+
+func TestApplyFilter_MinMax(t *testing.T) {
+	// Setup in-memory database
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&ScoredRecord{}))
+	for i, score := range []float64{10, 50, 90} {
+		assert.NoError(t, db.Create(&ScoredRecord{ID: uint(i + 1), Score: score}).Error)
+	}
+
+	min, max := 40.0, 60.0
+	tests := []struct {
+		name        string
+		filter      ScoredFilter
+		expectedIDs []uint
+	}{
+		{"Min only", ScoredFilter{MinScore: &min}, []uint{2, 3}},
+		{"Max only", ScoredFilter{MaxScore: &max}, []uint{1, 2}},
+		{"Range", ScoredFilter{MinScore: &min, MaxScore: &max}, []uint{2}},
+		{"Skipped field is ignored", ScoredFilter{Sort: "-score"}, []uint{1, 2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var results []ScoredRecord
+			err := ApplyFilter(db.Model(&ScoredRecord{}), tt.filter).Find(&results).Error
+			assert.NoError(t, err)
+
+			var actualIDs []uint
+			for _, result := range results {
+				actualIDs = append(actualIDs, result.ID)
+			}
+			assert.ElementsMatch(t, tt.expectedIDs, actualIDs)
+		})
+	}
+}
+
+type TaggedRecord struct {
+	ID        uint `gorm:"primaryKey"`
+	Name      string
+	Status    string
+	Score     *float64
+	CreatedAt time.Time
+}
+
+type TaggedFilter struct {
+	Statuses      []string    `query:"status" filter:"status"`
+	NotStatuses   *string     `query:"not_status" filter:"status,op=nin"`
+	NotName       *string     `query:"not_name" filter:"name,op=ne"`
+	NameContains  *string     `query:"name_like" filter:"name,op=like"`
+	NameIContains *string     `query:"name_ilike" filter:"name,op=ilike"`
+	Unscored      *bool       `query:"unscored" filter:"score,op=isnull"`
+	Above         *float64    `query:"above" filter:"score,op=gt"`
+	Below         *float64    `query:"below" filter:"score,op=lt"`
+	After         *SimpleDate `query:"after" filter:"created_at,op=gt"`
+	Before        *SimpleDate `query:"before" filter:"created_at,op=lt"`
+	NotOn         *SimpleDate `query:"not_on" filter:"created_at,op=ne"`
+}
+
+func TestApplyFilter_Operators(t *testing.T) {
+	// Setup in-memory database
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&TaggedRecord{}))
+	score := func(f float64) *float64 { return &f }
+	records := []TaggedRecord{
+		{ID: 1, Name: "Spring_Fert", Status: "draft", Score: score(10), CreatedAt: time.Date(2025, 9, 1, 12, 0, 0, 0, time.UTC)},
+		{ID: 2, Name: "spring 100%", Status: "submitted", Score: score(50), CreatedAt: time.Date(2025, 9, 2, 12, 0, 0, 0, time.UTC)},
+		{ID: 3, Name: "Fall", Status: "closed", Score: nil, CreatedAt: time.Date(2025, 9, 3, 12, 0, 0, 0, time.UTC)},
+	}
+	for _, record := range records {
+		assert.NoError(t, db.Create(&record).Error)
+	}
+
+	text := func(s string) *string { return &s }
+	yes, no := true, false
+	day := &SimpleDate{Time: time.Date(2025, 9, 2, 0, 0, 0, 0, time.UTC)}
+	tests := []struct {
+		name        string
+		filter      TaggedFilter
+		expectedIDs []uint
+	}{
+		{"In list", TaggedFilter{Statuses: []string{"draft", "closed"}}, []uint{1, 3}},
+		{"In comma separated list", TaggedFilter{Statuses: []string{"draft, submitted"}}, []uint{1, 2}},
+		{"Not in", TaggedFilter{NotStatuses: text("draft,closed")}, []uint{2}},
+		{"Empty list is ignored", TaggedFilter{NotStatuses: text(",")}, []uint{1, 2, 3}},
+		{"Not equal", TaggedFilter{NotName: text("Fall")}, []uint{1, 2}},
+		{"Like", TaggedFilter{NameContains: text("ring")}, []uint{1, 2}},
+		{"Like wildcards are literal", TaggedFilter{NameContains: text("_")}, []uint{1}},
+		{"Ilike ignores case", TaggedFilter{NameIContains: text("SPRING 100%")}, []uint{2}},
+		{"Is null", TaggedFilter{Unscored: &yes}, []uint{3}},
+		{"Is not null", TaggedFilter{Unscored: &no}, []uint{1, 2}},
+		{"Numeric range", TaggedFilter{Above: score(5), Below: score(50)}, []uint{1}},
+		{"After day", TaggedFilter{After: day}, []uint{3}},
+		{"Before day", TaggedFilter{Before: day}, []uint{1}},
+		{"Not on day", TaggedFilter{NotOn: day}, []uint{1, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var results []TaggedRecord
+			err := ApplyFilter(db.Model(&TaggedRecord{}), tt.filter).Find(&results).Error
+			assert.NoError(t, err)
+
+			var actualIDs []uint
+			for _, result := range results {
+				actualIDs = append(actualIDs, result.ID)
+			}
+			assert.ElementsMatch(t, tt.expectedIDs, actualIDs)
+		})
+	}
+}
+
+func TestApplyFilter_InvalidFilter(t *testing.T) {
+	// Setup in-memory database
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&TaggedRecord{}))
+	value := "x"
+
+	tests := []struct {
+		name   string
+		filter any
+	}{
+		{"Not an identifier", struct {
+			Name *string `filter:"name = name OR 1"`
+		}{&value}},
+		{"Unknown column", struct {
+			Password *string
+		}{&value}},
+		{"Unknown column of the model's table", struct {
+			Name *string `filter:"tagged_records.nickname"`
+		}{&value}},
+		{"Unknown operator", struct {
+			Name *string `filter:"name,op=regexp"`
+		}{&value}},
+		{"Like on a number", struct {
+			Score *float64 `filter:"score,op=like"`
+		}{new(float64)}},
+		{"Is null without a bool", struct {
+			Score *float64 `filter:"score,op=isnull"`
+		}{new(float64)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var results []TaggedRecord
+			err := ApplyFilter(db.Model(&TaggedRecord{}), tt.filter).Find(&results).Error
+			assert.ErrorIs(t, err, ErrInvalidFilter)
+		})
+	}
+
+	// Columns of other tables are left to the joins of the query
+	var results []TaggedRecord
+	err = ApplyFilter(db.Model(&TaggedRecord{}).Joins("JOIN tagged_records AS others ON others.id = tagged_records.id"), struct {
+		Name *string `filter:"others.name"`
+	}{&value}).Find(&results).Error
+	assert.NoError(t, err)
+}
+
+func TestGetColumnName(t *testing.T) {
+	type columns struct {
+		EmployeeID uuid.UUID `gorm:"type:string;index" json:"employee_id"`
+		Renamed    string    `gorm:"index;column:other_name"`
+		MinScore   *float64  `filter:"score,op=gte"`
+		Options    *string   `filter:",op=like" json:"notes"`
+		FieldName  string
+	}
+	expected := []string{"employee_id", "other_name", "score", "notes", "field_name"}
+
+	typ := reflect.TypeFor[columns]()
+	for i, name := range expected {
+		assert.Equal(t, name, getColumnName(typ.Field(i)))
+	}
+}
+
+type Crew struct {
+	ID        uint `gorm:"primaryKey"`
+	Name      string
+	DeletedAt gorm.DeletedAt
+}
+
+type Truck struct {
+	ID     uint `gorm:"primaryKey"`
+	CrewID uint
+	Crew   Crew
+	Plate  string
+}
+
+type Owner struct {
+	ID     uint `gorm:"primaryKey"`
+	Active bool
+}
+
+type Job struct {
+	ID       uint `gorm:"primaryKey"`
+	TruckID  uint
+	Truck    Truck
+	OwnerID  uint   //no association, like a model of another package
+	Stops    []Stop //has many
+	Customer string
+}
+
+type Stop struct {
+	ID    uint `gorm:"primaryKey"`
+	JobID uint
+}
+
+type JobFilter struct {
+	ID          *uint   `filter:"id"`
+	CrewName    *string `filter:"truck.crew.name"`
+	CrewNameNot *string `filter:"truck.crew.name,op=ne"`
+	Plate       *string `filter:"truck.plate,op=like"`
+	OwnerActive *bool   `filter:"owner.active"`
+}
+
+func TestApplyFilter_Relations(t *testing.T) {
+	// Setup in-memory database
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&Crew{}, &Truck{}, &Owner{}, &Job{}, &Stop{}))
+	assert.NoError(t, db.Create([]Crew{{ID: 1, Name: "north"}, {ID: 2, Name: "south"}, {ID: 3, Name: "gone"}}).Error)
+	assert.NoError(t, db.Delete(&Crew{}, 3).Error)
+	assert.NoError(t, db.Create([]Truck{{ID: 1, CrewID: 1, Plate: "LS-01"}, {ID: 2, CrewID: 2, Plate: "LS-02"}, {ID: 3, CrewID: 3, Plate: "LS-03"}}).Error)
+	assert.NoError(t, db.Create([]Owner{{ID: 1, Active: true}, {ID: 2, Active: false}}).Error)
+	assert.NoError(t, db.Create([]Job{{ID: 1, TruckID: 1, OwnerID: 1}, {ID: 2, TruckID: 2, OwnerID: 2}, {ID: 3, TruckID: 3, OwnerID: 1}, {ID: 4, TruckID: 1, OwnerID: 2}}).Error)
+
+	text := func(s string) *string { return &s }
+	yes, no := true, false
+	one := uint(1)
+	tests := []struct {
+		name        string
+		filter      JobFilter
+		expectedIDs []uint
+	}{
+		{"Belongs to, two levels deep", JobFilter{CrewName: text("north")}, []uint{1, 4}},
+		{"Soft deleted relations are left out", JobFilter{CrewNameNot: text("north")}, []uint{2}},
+		{"Relation joined once", JobFilter{CrewName: text("north"), CrewNameNot: text("south"), Plate: text("LS")}, []uint{1, 4}},
+		{"Relation by column name", JobFilter{OwnerActive: &no}, []uint{2, 4}},
+		{"Own column next to joins", JobFilter{ID: &one, OwnerActive: &yes, CrewName: text("north")}, []uint{1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var results []Job
+			query := ApplyFilter(db.Model(&Job{}), tt.filter)
+			var count int64
+			assert.NoError(t, query.Session(&gorm.Session{}).Count(&count).Error)
+			err := query.Find(&results).Error
+			assert.NoError(t, err)
+
+			var actualIDs []uint
+			for _, result := range results {
+				actualIDs = append(actualIDs, result.ID)
+			}
+			assert.ElementsMatch(t, tt.expectedIDs, actualIDs)
+			assert.Equal(t, int64(len(tt.expectedIDs)), count)
+		})
+	}
+
+	invalid := []struct {
+		name   string
+		filter any
+	}{
+		{"Has many", struct {
+			Stop *uint `filter:"stops.id"`
+		}{&one}},
+		{"Unknown relation", struct {
+			Name *string `filter:"truck.driver.name"`
+		}{text("x")}},
+		{"Unknown column of a relation", struct {
+			Name *string `filter:"truck.crew.nickname"`
+		}{text("x")}},
+		{"Past a relation by column name", struct {
+			Name *string `filter:"owner.team.name"`
+		}{text("x")}},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			var results []Job
+			err := ApplyFilter(db.Model(&Job{}), tt.filter).Find(&results).Error
+			assert.ErrorIs(t, err, ErrInvalidFilter)
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidQuery = errors.New("invalid list query")
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 200
+)
+
+// PageParams are the paging, sorting and field selection parameters shared by
+// list endpoints. Embed it in a filter struct; ApplyFilter skips it.
+type PageParams struct {
+	Limit  int    `json:"limit,omitempty" query:"limit"`   //defaults to DefaultPageLimit, at most MaxPageLimit
+	Offset int    `json:"offset,omitempty" query:"offset"` //rows to skip; not allowed with a cursor
+	Cursor string `json:"cursor,omitempty" query:"cursor"` //next_cursor of the previous page
+	Sort   string `json:"sort,omitempty" query:"sort"`     //e.g. -created_at,employee_id
+	Fields string `json:"fields,omitempty" query:"fields"` //e.g. id,first_name; all fields when empty
+}
+
+// Sorting lists the keys a list can be sorted by and the columns they order.
+// NULLs sort before every value, unless the key is in NullsLast.
+type Sorting struct {
+	Columns   map[string]string
+	Default   string   // used when no sort is given, e.g. "-created_at"
+	NullsLast []string // keys whose NULLs sort after every value, before them when descending
+}
+
+// Page is one page of a list. NextCursor is set when there are more rows;
+// pass it as cursor with the same sort to get them.
+type Page[T any] struct {
+	Data       []T    `json:"data"`
+	Total      int64  `json:"total"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+
+	fields []string
+}
+
+// MarshalJSON leaves out every field of the items that was not asked for.
+func (p Page[T]) MarshalJSON() ([]byte, error) {
+	type page Page[T]
+	if len(p.fields) == 0 {
+		return json.Marshal(page(p))
+	}
+	data := make([]map[string]json.RawMessage, len(p.Data))
+	for i, item := range p.Data {
+		encoded, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		var all map[string]json.RawMessage
+		if err := json.Unmarshal(encoded, &all); err != nil {
+			return nil, err
+		}
+		data[i] = map[string]json.RawMessage{}
+		for _, field := range p.fields {
+			if value, ok := all[field]; ok {
+				data[i][field] = value
+			}
+		}
+	}
+	return json.Marshal(struct {
+		page
+		Data []map[string]json.RawMessage `json:"data"`
+	}{page(p), data})
+}
+
+type sortKey struct {
+	column    string
+	desc      bool
+	nullsLast bool
+}
+
+// pageCursor points after a row. Sort is kept so a cursor is only used with
+// the order it was made for.
+type pageCursor struct {
+	ID   uuid.UUID `json:"id"`
+	Sort string    `json:"sort"`
+}
+
+// Paginate loads one page of the rows query matches, ordered and cut as
+// params ask. Scopes, e.g. preloads, apply to loading the page but not to
+// counting the rows.
+func Paginate[T any](query *gorm.DB, params PageParams, sorting Sorting, scopes ...func(*gorm.DB) *gorm.DB) (*Page[T], error) {
+	limit := params.Limit
+	switch {
+	case limit < 0 || limit > MaxPageLimit:
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidQuery, MaxPageLimit)
+	case limit == 0:
+		limit = DefaultPageLimit
+	}
+	if params.Offset < 0 {
+		return nil, fmt.Errorf("%w: offset must not be negative", ErrInvalidQuery)
+	}
+	if params.Offset > 0 && params.Cursor != "" {
+		return nil, fmt.Errorf("%w: use either offset or cursor", ErrInvalidQuery)
+	}
+	fields, err := parseFields[T](params.Fields)
+	if err != nil {
+		return nil, err
+	}
+	table, err := tableName[T](query)
+	if err != nil {
+		return nil, err
+	}
+	sort := params.Sort
+	if sort == "" {
+		sort = sorting.Default
+	}
+	keys, err := parseSort(sort, sorting)
+	if err != nil {
+		return nil, err
+	}
+	keys = append(keys, sortKey{column: "id"})
+
+	page := &Page[T]{Data: []T{}, Limit: limit, Offset: params.Offset, fields: fields}
+	if err := query.Session(&gorm.Session{}).Count(&page.Total).Error; err != nil {
+		return nil, err
+	}
+
+	rows := query.Session(&gorm.Session{}).Scopes(scopes...)
+	if params.Cursor != "" {
+		cursor, err := decodeCursor(params.Cursor, sort)
+		if err != nil {
+			return nil, err
+		}
+		condition, vars := afterCursor(table, keys, cursor.ID)
+		rows = rows.Where(condition, vars...)
+	}
+	for _, key := range keys {
+		direction := " ASC"
+		if key.desc {
+			direction = " DESC"
+		}
+		if key.nullsLast {
+			rows = rows.Order(table + "." + key.column + " IS NULL" + direction)
+		}
+		rows = rows.Order(table + "." + key.column + direction)
+	}
+	// One row more than asked for tells whether there is a next page
+	if err := rows.Offset(params.Offset).Limit(limit + 1).Find(&page.Data).Error; err != nil {
+		return nil, err
+	}
+	if len(page.Data) > limit {
+		page.Data = page.Data[:limit]
+		last := reflect.ValueOf(page.Data[limit-1])
+		for last.Kind() == reflect.Pointer {
+			last = last.Elem()
+		}
+		id, ok := last.FieldByName("ID").Interface().(uuid.UUID)
+		if !ok {
+			return nil, fmt.Errorf("%T has no UUID ID to page by", page.Data[0])
+		}
+		page.NextCursor = encodeCursor(pageCursor{ID: id, Sort: sort})
+	}
+	return page, nil
+}
+
+// parseSort turns "-created_at,name" into sort keys, rejecting keys that are
+// not in sorting.
+func parseSort(sort string, sorting Sorting) ([]sortKey, error) {
+	var keys []sortKey
+	for part := range strings.SplitSeq(sort, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, desc := strings.CutPrefix(part, "-")
+		column, ok := sorting.Columns[name]
+		if !ok {
+			allowed := make([]string, 0, len(sorting.Columns))
+			for key := range sorting.Columns {
+				allowed = append(allowed, key)
+			}
+			slices.Sort(allowed)
+			return nil, fmt.Errorf("%w: cannot sort by %q, use one of %s", ErrInvalidQuery, name, strings.Join(allowed, ", "))
+		}
+		keys = append(keys, sortKey{column: column, desc: desc, nullsLast: slices.Contains(sorting.NullsLast, name)})
+	}
+	return keys, nil
+}
+
+// afterCursor is the condition for rows that come after the row with id in
+// the order of keys. The row's values are read in subqueries, so the cursor
+// only needs its ID. NULLs sort first, as they do in SQLite, unless the key
+// puts them last.
+func afterCursor(table string, keys []sortKey, id uuid.UUID) (string, []any) {
+	var alternatives []string
+	var vars []any
+	for i, key := range keys {
+		var terms []string
+		for _, previous := range keys[:i] {
+			terms = append(terms, fmt.Sprintf("%s.%s IS (SELECT %s FROM %s WHERE id = ?)", table, previous.column, previous.column, table))
+			vars = append(vars, id)
+		}
+		value := fmt.Sprintf("(SELECT %s FROM %s WHERE id = ?)", key.column, table)
+		column := table + "." + key.column
+		comparison := ">"
+		if key.desc {
+			comparison = "<"
+		}
+		if key.desc != key.nullsLast {
+			// NULLs come after the values
+			terms = append(terms, fmt.Sprintf("(%s %s %s OR (%s IS NULL AND %s IS NOT NULL))", column, comparison, value, column, value))
+		} else {
+			terms = append(terms, fmt.Sprintf("(%s %s %s OR (%s IS NULL AND %s IS NOT NULL))", column, comparison, value, value, column))
+		}
+		vars = append(vars, id, id)
+		alternatives = append(alternatives, "("+strings.Join(terms, " AND ")+")")
+	}
+	return strings.Join(alternatives, " OR "), vars
+}
+
+func encodeCursor(cursor pageCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(encoded, sort string) (pageCursor, error) {
+	var cursor pageCursor
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err == nil {
+		err = json.Unmarshal(data, &cursor)
+	}
+	if err != nil {
+		return cursor, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	if cursor.Sort != sort {
+		return cursor, fmt.Errorf("%w: cursor was made for sort %q", ErrInvalidQuery, cursor.Sort)
+	}
+	return cursor, nil
+}
+
+// parseFields splits a fields parameter, rejecting names T does not have.
+func parseFields[T any](fields string) ([]string, error) {
+	if strings.TrimSpace(fields) == "" {
+		return nil, nil
+	}
+	known := jsonFields(reflect.TypeFor[T]())
+	var selected []string
+	for field := range strings.SplitSeq(fields, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !slices.Contains(known, field) {
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidQuery, field)
+		}
+		selected = append(selected, field)
+	}
+	return selected, nil
+}
+
+// jsonFields returns the JSON names of the fields of struct type t, including
+// those of embedded structs.
+func jsonFields(t reflect.Type) []string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			names = append(names, jsonFields(field.Type)...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func tableName[T any](query *gorm.DB) (string, error) {
+	stmt := &gorm.Statement{DB: query}
+	if err := stmt.Parse(new(T)); err != nil {
+		return "", err
+	}
+	return stmt.Schema.Table, nil
+}
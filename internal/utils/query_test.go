@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type PagedRecord struct {
+	ID    uuid.UUID `gorm:"type:string;primaryKey" json:"id"`
+	Name  string    `json:"name"`
+	Score *float64  `json:"score"`
+}
+
+type PagedFilter struct {
+	Name *string `query:"name"`
+	PageParams
+}
+
+var pagedSorting = Sorting{
+	Columns:   map[string]string{"name": "name", "score": "score", "score_last": "score"},
+	Default:   "name",
+	NullsLast: []string{"score_last"},
+}
+
+func setupPagedRecords(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&PagedRecord{}))
+	// Ties and NULLs, so paging has to fall back on later sort keys
+	scores := []*float64{nil, new(float64), nil, new(float64), new(float64), nil, new(float64)}
+	for i, score := range scores {
+		if score != nil {
+			*score = float64(i % 3)
+		}
+		name := fmt.Sprintf("record%d", i%4)
+		require.NoError(t, db.Create(&PagedRecord{ID: uuid.New(), Name: name, Score: score}).Error)
+	}
+	return db
+}
+
+func names(records []PagedRecord) []string {
+	var result []string
+	for _, record := range records {
+		result = append(result, record.Name)
+	}
+	return result
+}
+
+func TestPaginate_Cursor(t *testing.T) {
+	db := setupPagedRecords(t)
+
+	for _, sort := range []string{"", "name", "-name", "score,name", "-score,-name", "-score,name", "score_last,name", "-score_last,-name"} {
+		t.Run("sort "+sort, func(t *testing.T) {
+			// Walking the pages yields every row once, in the order of a single page
+			all, err := Paginate[PagedRecord](db.Model(&PagedRecord{}), PageParams{Sort: sort, Limit: MaxPageLimit}, pagedSorting)
+			require.NoError(t, err)
+			require.Len(t, all.Data, 7)
+			assert.Empty(t, all.NextCursor)
+
+			var walked []PagedRecord
+			params := PageParams{Sort: sort, Limit: 2}
+			for range 10 {
+				page, err := Paginate[PagedRecord](db.Model(&PagedRecord{}), params, pagedSorting)
+				require.NoError(t, err)
+				assert.Equal(t, int64(7), page.Total)
+				walked = append(walked, page.Data...)
+				if page.NextCursor == "" {
+					break
+				}
+				params.Cursor = page.NextCursor
+			}
+			assert.Equal(t, all.Data, walked)
+		})
+	}
+}
+
+func TestPaginate_NullsLast(t *testing.T) {
+	db := setupPagedRecords(t)
+
+	page, err := Paginate[PagedRecord](db.Model(&PagedRecord{}), PageParams{Sort: "score_last"}, pagedSorting)
+	require.NoError(t, err)
+	require.Len(t, page.Data, 7)
+	assert.NotNil(t, page.Data[3].Score)
+	assert.Nil(t, page.Data[4].Score)
+
+	page, err = Paginate[PagedRecord](db.Model(&PagedRecord{}), PageParams{Sort: "-score_last"}, pagedSorting)
+	require.NoError(t, err)
+	assert.Nil(t, page.Data[2].Score)
+	assert.NotNil(t, page.Data[3].Score)
+}
+
+func TestPaginate_Offset(t *testing.T) {
+	db := setupPagedRecords(t)
+	name := "record1"
+
+	query := ApplyFilter(db.Model(&PagedRecord{}), PagedFilter{Name: &name, PageParams: PageParams{Offset: 1, Limit: 1}})
+	page, err := Paginate[PagedRecord](query, PageParams{Offset: 1, Limit: 1}, pagedSorting)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), page.Total)
+	assert.Equal(t, []string{"record1"}, names(page.Data))
+	assert.Equal(t, 1, page.Offset)
+	assert.Empty(t, page.NextCursor)
+}
+
+func TestPaginate_Fields(t *testing.T) {
+	db := setupPagedRecords(t)
+
+	page, err := Paginate[PagedRecord](db.Model(&PagedRecord{}), PageParams{Fields: "name", Limit: 1}, pagedSorting)
+	require.NoError(t, err)
+	encoded, err := json.Marshal(page)
+	require.NoError(t, err)
+
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+	assert.Equal(t, []any{map[string]any{"name": "record0"}}, decoded["data"])
+	assert.Equal(t, float64(7), decoded["total"])
+	assert.Equal(t, float64(1), decoded["limit"])
+	assert.NotEmpty(t, decoded["next_cursor"])
+}
+
+func TestPaginate_InvalidQuery(t *testing.T) {
+	db := setupPagedRecords(t)
+	first, err := Paginate[PagedRecord](db.Model(&PagedRecord{}), PageParams{Limit: 1}, pagedSorting)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		params PageParams
+	}{
+		{"Unknown sort key", PageParams{Sort: "-id"}},
+		{"Unknown field", PageParams{Fields: "id,password"}},
+		{"Limit too high", PageParams{Limit: MaxPageLimit + 1}},
+		{"Negative offset", PageParams{Offset: -1}},
+		{"Offset and cursor", PageParams{Offset: 1, Cursor: first.NextCursor}},
+		{"Malformed cursor", PageParams{Cursor: "not a cursor"}},
+		{"Cursor for another sort", PageParams{Sort: "-name", Cursor: first.NextCursor}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Paginate[PagedRecord](db.Model(&PagedRecord{}), tt.params, pagedSorting)
+			assert.ErrorIs(t, err, ErrInvalidQuery)
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// resolveColumn qualifies column for a query on model. A column can name a
+// field of the model (score), of a table the query joins itself
+// (transcripts.inspection_id), or of a related record
+// (lawn_service.formulation.name). Related records are reached through
+// belongs to and has one associations of the model or, for tables in other
+// packages, a <name>_id column pointing at the <name>s table; the tables on
+// the way are joined once, named after their path (lawn_service__formulation).
+func resolveColumn(query *gorm.DB, model *schema.Schema, column string) (*gorm.DB, string, error) {
+	if !columnPattern.MatchString(column) {
+		return query, "", fmt.Errorf("%q is not a column name", column)
+	}
+	path := strings.Split(column, ".")
+	if model == nil {
+		if len(path) > 2 {
+			return query, "", fmt.Errorf("%s needs a query with a model", column)
+		}
+		return query, column, nil
+	}
+	table := query.Statement.Table
+	if table == "" {
+		table = model.Table
+	}
+
+	switch {
+	case len(path) == 1:
+		return query, table + "." + column, checkField(model, column)
+	case len(path) == 2 && (path[0] == table || path[0] == model.Table):
+		return query, table + "." + path[1], checkField(model, path[1])
+	case len(path) == 2 && !isRelation(query, model, path[0]):
+		// A table the query joins itself
+		return query, column, nil
+	}
+
+	current, alias := model, table
+	for i, name := range path[:len(path)-1] {
+		if current == nil {
+			return query, "", fmt.Errorf("cannot follow %s past %s", column, strings.Join(path[:i], "."))
+		}
+		join, next, err := relationJoin(query, current, alias, name, strings.Join(path[:i+1], "__"))
+		if err != nil {
+			return query, "", err
+		}
+		if !hasJoin(query, join) {
+			query = query.Joins(join)
+		}
+		current, alias = next, strings.Join(path[:i+1], "__")
+	}
+	field := path[len(path)-1]
+	if current != nil {
+		if err := checkField(current, field); err != nil {
+			return query, "", err
+		}
+	}
+	return query, alias + "." + field, nil
+}
+
+// relationJoin returns the JOIN from the table aliased from to the relation
+// called name of model, and the schema of the joined table if it is known.
+func relationJoin(query *gorm.DB, model *schema.Schema, from, name, alias string) (string, *schema.Schema, error) {
+	if relation := findRelation(query, model, name); relation != nil {
+		var on []string
+		switch relation.Type {
+		case schema.BelongsTo, schema.HasOne:
+			for _, reference := range relation.References {
+				if reference.OwnPrimaryKey {
+					on = append(on, fmt.Sprintf("%s.%s = %s.%s", alias, reference.ForeignKey.DBName, from, reference.PrimaryKey.DBName))
+				} else {
+					on = append(on, fmt.Sprintf("%s.%s = %s.%s", alias, reference.PrimaryKey.DBName, from, reference.ForeignKey.DBName))
+				}
+			}
+		default:
+			return "", nil, fmt.Errorf("%s of %s is a %s relation, only belongs to and has one can be filtered on", name, model.Table, relation.Type)
+		}
+		target := relation.FieldSchema
+		if slices.Contains(target.DBNames, "deleted_at") {
+			on = append(on, alias+".deleted_at IS NULL")
+		}
+		return fmt.Sprintf("JOIN %s AS %s ON %s", target.Table, alias, strings.Join(on, " AND ")), target, nil
+	}
+
+	if slices.Contains(model.DBNames, name+"_id") {
+		table := query.NamingStrategy.TableName(name)
+		on := fmt.Sprintf("%s.id = %s.%s_id", alias, from, name)
+		if query.Session(&gorm.Session{NewDB: true}).Migrator().HasColumn(table, "deleted_at") {
+			on += " AND " + alias + ".deleted_at IS NULL"
+		}
+		return fmt.Sprintf("JOIN %s AS %s ON %s", table, alias, on), nil, nil
+	}
+	return "", nil, fmt.Errorf("%s has no relation %q", model.Table, name)
+}
+
+func findRelation(query *gorm.DB, model *schema.Schema, name string) *schema.Relationship {
+	for fieldName, relation := range model.Relationships.Relations {
+		if query.NamingStrategy.ColumnName("", fieldName) == name {
+			return relation
+		}
+	}
+	return nil
+}
+
+func isRelation(query *gorm.DB, model *schema.Schema, name string) bool {
+	return findRelation(query, model, name) != nil || slices.Contains(model.DBNames, name+"_id")
+}
+
+func hasJoin(query *gorm.DB, sql string) bool {
+	for _, join := range query.Statement.Joins {
+		if join.Name == sql {
+			return true
+		}
+	}
+	return false
+}
+
+func checkField(model *schema.Schema, column string) error {
+	if !slices.Contains(model.DBNames, column) {
+		return fmt.Errorf("%s has no column %q", model.Table, column)
+	}
+	return nil
+}